@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/linesmerrill/police-cad-api/models"
 
@@ -13,41 +16,112 @@ import (
 	"github.com/linesmerrill/police-cad-api/api"
 	"github.com/linesmerrill/police-cad-api/config"
 	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/metrics"
 )
 
+// readyPingTimeout bounds the mongo ping of the readiness check
+const readyPingTimeout = 2 * time.Second
+
+// ensureIndexesTimeout bounds index creation at startup
+const ensureIndexesTimeout = time.Minute
+
+// startedAt is when the process started, for the uptime in the health check
+var startedAt = time.Now()
+
 // App stores the router and db connection, so it can be reused
 type App struct {
 	Router   *mux.Router
 	DB       databases.CollectionHelper
 	Config   config.Config
 	dbHelper databases.DatabaseHelper
+	// Version is the build of the api, reported by the health check
+	Version string
+
+	maintenance *Maintenance
+	selfCheck   *SelfCheck
+	presence    *Presence
 }
 
 // New creates a new mux router and all the routes
 func (a *App) New() *mux.Router {
 	r := mux.NewRouter()
+	api.SetQueryTimeout(a.Config.DBQueryTimeout)
+	api.SetJWTSecret(a.Config.JWTSecret)
+	api.SetProxySecret(a.Config.ProxySecret)
+	api.SetAdminUserIDs(a.Config.AdminUserIDs)
 
 	u := User{DB: databases.NewUserDatabase(a.dbHelper)}
-	c := Community{DB: databases.NewCommunityDatabase(a.dbHelper)}
-	civ := Civilian{DB: databases.NewCivilianDatabase(a.dbHelper)}
+	c := Community{DB: databases.NewCommunityDatabase(a.dbHelper), AuditLogDB: databases.NewAuditLogDatabase(a.dbHelper)}
 	v := Vehicle{DB: databases.NewVehicleDatabase(a.dbHelper)}
 	f := Firearm{DB: databases.NewFirearmDatabase(a.dbHelper)}
 	l := License{DB: databases.NewLicenseDatabase(a.dbHelper)}
+	w := Warrant{DB: databases.NewWarrantDatabase(a.dbHelper)}
+	civ := Civilian{DB: databases.NewCivilianDatabase(a.dbHelper), VehicleDB: v.DB, LicenseDB: l.DB, WarrantDB: w.DB}
 	e := Ems{DB: databases.NewEmsDatabase(a.dbHelper)}
 	ev := EmsVehicle{DB: databases.NewEmsVehicleDatabase(a.dbHelper)}
-	w := Warrant{DB: databases.NewWarrantDatabase(a.dbHelper)}
 	call := Call{DB: databases.NewCallDatabase(a.dbHelper)}
+	al := AuditLog{DB: c.AuditLogDB, CommunityDB: c.DB}
+	k := APIKey{DB: databases.NewAPIKeyDatabase(a.dbHelper), CommunityDB: c.DB, AuditLogDB: c.AuditLogDB}
+	cit := Citation{DB: databases.NewCitationDatabase(a.dbHelper), CivilianDB: civ.DB, CommunityDB: c.DB, UserDB: u.DB}
+	ce := CommunityExport{DB: c.DB, UserDB: u.DB, AuditLogDB: c.AuditLogDB}
+	rs := RegistrationSearch{VehicleDB: v.DB, FirearmDB: f.DB, CivilianDB: civ.DB, WarrantDB: w.DB}
+	cw := CivilianWarrant{DB: w.DB, CivilianDB: civ.DB, CommunityDB: c.DB, UserDB: u.DB}
+	idem := &Idempotency{DB: databases.NewIdempotencyDatabase(a.dbHelper)}
+	cb := CivilianBundle{DB: civ.DB, CommunityDB: c.DB, VehicleDB: v.DB, FirearmDB: f.DB, LicenseDB: l.DB, WarrantDB: w.DB, CitationDB: cit.DB, AuditLogDB: c.AuditLogDB, Tx: databases.NewTransactioner(a.dbHelper), ConfirmSecret: a.Config.JWTSecret}
+	a.maintenance = &Maintenance{DB: databases.NewMaintenanceDatabase(a.dbHelper), ForceEnabled: a.Config.MaintenanceMode}
+
+	a.presence = &Presence{DB: u.DB, Timeout: a.Config.PresenceTimeout}
+
+	a.selfCheck = &SelfCheck{}
+	a.selfCheck.Register("mongo", MongoSelfCheck(a.dbHelper))
+	a.selfCheck.Register("indexes", IndexSelfCheck(a.dbHelper))
+	a.selfCheck.Register("config", ConfigSelfCheck(a.Config))
+
+	cors := &CORS{AllowedOrigins: a.Config.CORSAllowedOrigins, AllowedMethods: a.Config.CORSAllowedMethods, AllowCredentials: a.Config.CORSAllowCredentials}
+
+	r.Use(metrics.Middleware)
+	r.Use(cors.Middleware)
+	r.Use(a.maintenance.Middleware)
+	r.Use(GzipMiddleware)
 
 	// healthchex
-	r.HandleFunc("/health", healthCheckHandler)
+	r.Handle("/health", api.Public(http.HandlerFunc(a.healthCheckHandler)))
+	r.Handle("/ready", api.Public(http.HandlerFunc(a.readyHandler)))
+	r.Handle("/metrics", metrics.DefaultRegistry.Handler())
 
 	apiCreate := r.PathPrefix("/api/v1").Subrouter()
 
+	apiCreate.Handle("/openapi.json", api.Public(http.HandlerFunc(OpenAPIHandler))).Methods("GET")
+
+	apiCreate.Handle("/community/by-slug/{slug}", api.Middleware(http.HandlerFunc(c.CommunityBySlugHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/slug", api.Middleware(http.HandlerFunc(c.UpdateCommunitySlugHandler))).Methods("PUT")
+	apiCreate.Handle("/community/{community_id}/api-keys", api.Middleware(http.HandlerFunc(k.CreateAPIKeyHandler))).Methods("POST")
+	apiCreate.Handle("/community/{community_id}/api-keys", api.Middleware(http.HandlerFunc(k.APIKeysHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/api-keys/{api_key_id}", api.Middleware(http.HandlerFunc(k.RevokeAPIKeyHandler))).Methods("DELETE")
+	apiCreate.Handle("/community/{community_id}/broadcast", api.Middleware(http.HandlerFunc(c.CreateBroadcastHandler))).Methods("POST")
+	apiCreate.Handle("/community/{community_id}/broadcast", api.Middleware(http.HandlerFunc(c.BroadcastHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/broadcast", api.Middleware(http.HandlerFunc(c.DeleteBroadcastHandler))).Methods("DELETE")
+	apiCreate.Handle("/community/{community_id}/audit-log", api.Middleware(http.HandlerFunc(al.AuditLogHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/export", api.Middleware(http.HandlerFunc(ce.CommunityExportHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/civilians/{civilian_id}/bundle", api.Middleware(http.HandlerFunc(cb.CivilianBundleHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/civilians/{civilian_id}/bundle", api.Middleware(http.HandlerFunc(cb.DeleteCivilianBundleHandler))).Methods("DELETE")
+	apiCreate.Handle("/community/{community_id}/civilians/search", k.Middleware(ScopeReadCivilians, http.HandlerFunc(civ.CommunityCivilianSearchHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/vehicles/search", k.Middleware(ScopeReadCivilians, http.HandlerFunc(rs.CommunityVehicleSearchHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/firearms/search", k.Middleware(ScopeReadCivilians, http.HandlerFunc(rs.CommunityFirearmSearchHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/civilians/{civilian_id}/warrants", api.Middleware(idem.Middleware(http.HandlerFunc(cw.CreateCivilianWarrantHandler)))).Methods("POST")
+	apiCreate.Handle("/community/{community_id}/civilians/{civilian_id}/warrants", k.Middleware(ScopeReadCivilians, http.HandlerFunc(cw.CivilianWarrantsHandler))).Methods("GET")
+	apiCreate.Handle("/community/{community_id}/civilians/{civilian_id}/warrants/{warrant_id}", api.Middleware(http.HandlerFunc(cw.UpdateCivilianWarrantStatusHandler))).Methods("PATCH")
+	apiCreate.Handle("/community/{community_id}/citations", api.Middleware(idem.Middleware(http.HandlerFunc(cit.CreateCitationHandler)))).Methods("POST")
+	apiCreate.Handle("/community/{community_id}/civilian/{civilian_id}/citations", k.Middleware(ScopeReadCivilians, http.HandlerFunc(cit.CivilianCitationsHandler))).Methods("GET")
 	apiCreate.Handle("/community/{community_id}", api.Middleware(http.HandlerFunc(c.CommunityHandler))).Methods("GET")
 	apiCreate.Handle("/community/{community_id}/{owner_id}", api.Middleware(http.HandlerFunc(c.CommunityByCommunityAndOwnerIDHandler))).Methods("GET")
+	apiCreate.Handle("/communities/search", api.Middleware(http.HandlerFunc(c.CommunitySearchHandler))).Methods("GET")
 	apiCreate.Handle("/communities/{owner_id}", api.Middleware(http.HandlerFunc(c.CommunitiesByOwnerIDHandler))).Methods("GET")
 	apiCreate.Handle("/user/{user_id}", api.Middleware(http.HandlerFunc(u.UserHandler))).Methods("GET")
-	apiCreate.Handle("/users/{active_community_id}", api.Middleware(http.HandlerFunc(u.UsersFindAllHandler))).Methods("GET")
+	apiCreate.Handle("/users/{user_id}/heartbeat", api.Middleware(http.HandlerFunc(u.HeartbeatHandler))).Methods("POST")
+	apiCreate.Handle("/users/{user_id}/offline", api.Middleware(http.HandlerFunc(u.OfflineHandler))).Methods("POST")
+	apiCreate.Handle("/users/batch", api.Middleware(http.HandlerFunc(u.UsersBatchHandler))).Methods("POST")
+	apiCreate.Handle("/users/{active_community_id}", k.Middleware(ScopeReadMembers, http.HandlerFunc(u.UsersFindAllHandler))).Methods("GET")
 	apiCreate.Handle("/civilian/{civilian_id}", api.Middleware(http.HandlerFunc(civ.CivilianByIDHandler))).Methods("GET")
 	apiCreate.Handle("/civilians", api.Middleware(http.HandlerFunc(civ.CivilianHandler))).Methods("GET")
 	apiCreate.Handle("/civilians/user/{user_id}", api.Middleware(http.HandlerFunc(civ.CiviliansByUserIDHandler))).Methods("GET")
@@ -76,10 +150,19 @@ func (a *App) New() *mux.Router {
 	apiCreate.Handle("/emsVehicle/{ems_vehicle_id}", api.Middleware(http.HandlerFunc(ev.EmsVehicleByIDHandler))).Methods("GET")
 	apiCreate.Handle("/emsVehicles", api.Middleware(http.HandlerFunc(ev.EmsVehicleHandler))).Methods("GET")
 	apiCreate.Handle("/emsVehicles/user/{user_id}", api.Middleware(http.HandlerFunc(ev.EmsVehiclesByUserIDHandler))).Methods("GET")
+	apiCreate.Handle("/admin/migrations/community-owner-id", api.Admin(http.HandlerFunc(c.MigrateLegacyOwnerIDHandler))).Methods("POST")
+	apiCreate.Handle("/admin/maintenance", api.Admin(http.HandlerFunc(a.maintenance.MaintenanceHandler))).Methods("POST")
+	apiCreate.Handle("/admin/selfcheck", api.Admin(http.HandlerFunc(a.selfCheck.SelfCheckHandler))).Methods("POST")
+	apiCreate.Handle("/citations/{citation_id}/status", api.Middleware(http.HandlerFunc(cit.UpdateCitationStatusHandler))).Methods("PATCH")
 	apiCreate.Handle("/call/{call_id}", api.Middleware(http.HandlerFunc(call.CallByIDHandler))).Methods("GET")
 	apiCreate.Handle("/calls", api.Middleware(http.HandlerFunc(call.CallHandler))).Methods("GET")
 	apiCreate.Handle("/calls/community/{community_id}", api.Middleware(http.HandlerFunc(call.CallsByCommunityIDHandler))).Methods("GET")
 
+	if cors.Enabled() {
+		// registered after every other api route so it only answers OPTIONS requests they do not accept
+		r.PathPrefix("/").Methods(http.MethodOptions).HandlerFunc(preflightHandler)
+	}
+
 	// swagger docs hosted at "/"
 	r.PathPrefix("/").Handler(http.StripPrefix("/", http.FileServer(http.Dir("./docs/"))))
 	return r
@@ -87,7 +170,43 @@ func (a *App) New() *mux.Router {
 
 // Initialize is invoked by main to connect with the database and create a router
 func (a *App) Initialize() error {
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	if a.Config.EnsureIndexes {
+		// missing indexes only make queries slower, so they never stop the api from starting
+		ctx, cancel := context.WithTimeout(context.Background(), ensureIndexesTimeout)
+		if err := databases.EnsureIndexes(ctx, a.dbHelper); err != nil {
+			zap.S().With(err).Error("failed to ensure indexes")
+		}
+		cancel()
+	}
+
+	// initialize api router
+	a.initializeRoutes()
+
+	// keep this instance's maintenance state in sync with the other instances
+	go a.maintenance.Poll(context.Background(), a.Config.MaintenancePollInterval)
+	// mark users offline once they stop sending heartbeats
+	go a.presence.Run(context.Background(), a.Config.PresenceSweepInterval)
+	return nil
+
+}
+
+// InitializeSelfCheck connects to the database and creates the router like Initialize, but
+// creates no indexes and starts no background work, so the -selfcheck flag leaves the database
+// as it found it
+func (a *App) InitializeSelfCheck() error {
+	if err := a.connect(); err != nil {
+		return err
+	}
+	a.initializeRoutes()
+	return nil
+}
 
+// connect creates the database client and connects to it
+func (a *App) connect() error {
 	client, err := databases.NewClient(&a.Config)
 	if err != nil {
 		// if we fail to create a new database client, then kill the pod
@@ -103,22 +222,76 @@ func (a *App) Initialize() error {
 		return err
 	}
 	zap.S().Info("police-cad-api has connected to the database")
-
-	// initialize api router
-	a.initializeRoutes()
 	return nil
+}
 
+// RunSelfCheck runs every registered self check against the initialized app
+func (a *App) RunSelfCheck(ctx context.Context) models.SelfCheckReport {
+	return a.selfCheck.Run(ctx)
 }
 
 func (a *App) initializeRoutes() {
 	a.Router = a.New()
 }
 
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
+func (a *App) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	b, _ := json.Marshal(models.HealthCheckResponse{
-		Alive: true,
+		Alive:   true,
+		Version: a.Version,
+		Uptime:  time.Since(startedAt).Round(time.Second).String(),
 	})
 	_, _ = io.WriteString(w, string(b))
 }
+
+// readyHandler reports 503 until every dependency the api needs to serve traffic is available,
+// so the load balancer keeps broken instances out of rotation
+func (a *App) readyHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"mongo": "ok", "router": "ok"}
+	ready := true
+	if err := a.pingMongo(r.Context()); err != nil {
+		zap.S().With(err).Warn("readiness check failed to ping mongo")
+		checks["mongo"] = err.Error()
+		ready = false
+	}
+	if a.Router == nil {
+		checks["router"] = "not initialized"
+		ready = false
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	b, _ := json.Marshal(models.ReadyResponse{
+		Ready:       ready,
+		Checks:      checks,
+		Maintenance: a.maintenance.State(),
+	})
+	_, _ = io.WriteString(w, string(b))
+}
+
+// pingMongo pings the database, giving up after readyPingTimeout
+func (a *App) pingMongo(ctx context.Context) error {
+	if a.dbHelper == nil {
+		return errors.New("not connected")
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyPingTimeout)
+	defer cancel()
+	return a.dbHelper.Client().Ping(ctx)
+}
+
+// getUserIDFromRequest returns the ID of the user making the request. The user named by the
+// bearer token wins, the X-User-ID header is only believed when it came through the frontend proxy.
+func getUserIDFromRequest(r *http.Request) string {
+	if userID := api.UserIDFromContext(r.Context()); userID != "" {
+		return userID
+	}
+	if !api.ProxyHeaderTrusted(r) {
+		return ""
+	}
+	return r.Header.Get("X-User-ID")
+}
@@ -2,13 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
 )
 
 var a App
@@ -46,6 +54,9 @@ func TestHealthCheckRoute(t *testing.T) {
 	if !strings.Contains(response.Body.String(), "alive") {
 		t.Errorf("Expected 'alive' in the reponse. Got '%s'", response.Body.String())
 	}
+	if !strings.Contains(response.Body.String(), "uptime") {
+		t.Errorf("Expected 'uptime' in the reponse. Got '%s'", response.Body.String())
+	}
 }
 
 func TestApp_CommunityHandlerInvalidRoute(t *testing.T) {
@@ -70,12 +81,12 @@ func TestApp_CommunityHandlerInvalidToken(t *testing.T) {
 	req.Header.Add("Authorization", "Bearer asdfasdf")
 	response := executeRequest(req)
 
-	checkResponseCode(t, http.StatusInternalServerError, response.Code)
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
 
 	var m map[string]string
 	json.Unmarshal(response.Body.Bytes(), &m)
-	if m["error"] != "failed to parse token, token contains an invalid number of segments" {
-		t.Errorf("Expected the 'error' key of the reponse to be set to 'failed to parse token, token contains an invalid number of segments'. Got '%s'", m["error"])
+	if m["error"] != "invalid token, token contains an invalid number of segments" {
+		t.Errorf("Expected the 'error' key of the reponse to be set to 'invalid token, token contains an invalid number of segments'. Got '%s'", m["error"])
 	}
 }
 
@@ -101,3 +112,125 @@ func TestApp_InitializeRoutes(t *testing.T) {
 	assert.NotEmpty(t, a.Router)
 
 }
+
+func readyDB(pingErr error) *mocks.DatabaseHelper {
+	client := &mocks.ClientHelper{}
+	client.On("Ping", mock.Anything).Return(pingErr)
+	db := &mocks.DatabaseHelper{}
+	db.On("Client").Return(client)
+	return db
+}
+
+func TestReadyRoute(t *testing.T) {
+	a.dbHelper = readyDB(nil)
+	a.Router = a.New()
+	req, _ := http.NewRequest("GET", "/ready", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var got models.ReadyResponse
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &got))
+	assert.True(t, got.Ready)
+	assert.Equal(t, map[string]string{"mongo": "ok", "router": "ok"}, got.Checks)
+	if !strings.Contains(response.Body.String(), "maintenance") {
+		t.Errorf("Expected 'maintenance' in the reponse. Got '%s'", response.Body.String())
+	}
+}
+
+func TestReadyRouteMongoUnreachable(t *testing.T) {
+	a.dbHelper = readyDB(errors.New("server selection timeout"))
+	a.Router = a.New()
+	req, _ := http.NewRequest("GET", "/ready", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusServiceUnavailable, response.Code)
+
+	var got models.ReadyResponse
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &got))
+	assert.False(t, got.Ready)
+	assert.Equal(t, "server selection timeout", got.Checks["mongo"])
+}
+
+func TestPreflightRoute(t *testing.T) {
+	a.Config.CORSAllowedOrigins = []string{"https://www.linespolice-cad.com"}
+	a.Config.CORSAllowedMethods = []string{"GET"}
+	defer func() { a.Config.CORSAllowedOrigins, a.Config.CORSAllowedMethods = nil, nil }()
+	a.Router = a.New()
+	req, _ := http.NewRequest("OPTIONS", "/api/v1/community/1234", nil)
+	req.Header.Set("Origin", "https://www.linespolice-cad.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusNoContent, response.Code)
+	assert.Equal(t, "https://www.linespolice-cad.com", response.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestGetUserIDFromRequest(t *testing.T) {
+	defer api.SetProxySecret("")
+	os.Setenv("SECRET_KEY", "test-secret")
+	defer os.Unsetenv("SECRET_KEY")
+
+	req, _ := http.NewRequest("GET", "/api/v1/community/1234", nil)
+	req.Header.Set("X-User-ID", "forged-user")
+
+	// without a proxy secret configured the header is ignored
+	assert.Equal(t, "", getUserIDFromRequest(req))
+	req.Header.Set(api.ProxySecretHeader, "anything")
+	assert.Equal(t, "", getUserIDFromRequest(req))
+
+	api.SetProxySecret("proxy-secret")
+	assert.Equal(t, "", getUserIDFromRequest(req))
+	req.Header.Set(api.ProxySecretHeader, "proxy-secret")
+	assert.Equal(t, "forged-user", getUserIDFromRequest(req))
+
+	// the user named by the token wins over the header
+	token, _ := api.NewToken("token-user", time.Hour)
+	req.Header.Set("Authorization", "Bearer "+token)
+	var got string
+	api.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = getUserIDFromRequest(r)
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "token-user", got)
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	os.Setenv("SECRET_KEY", "test-secret")
+	defer os.Unsetenv("SECRET_KEY")
+	a.Router = a.New()
+	token, _ := api.NewToken("not-an-admin", time.Hour)
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/maintenance", strings.NewReader(`{"enabled": true}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+	assert.Contains(t, response.Body.String(), "admin access required")
+}
+
+func TestSelfCheckRouteRequiresAdmin(t *testing.T) {
+	os.Setenv("SECRET_KEY", "test-secret")
+	defer os.Unsetenv("SECRET_KEY")
+	a.Router = a.New()
+
+	// no token at all
+	req, _ := http.NewRequest("POST", "/api/v1/admin/selfcheck", nil)
+	checkResponseCode(t, http.StatusUnauthorized, executeRequest(req).Code)
+
+	token, _ := api.NewToken("not-an-admin", time.Hour)
+	req.Header.Set("Authorization", "Bearer "+token)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+	assert.NotContains(t, response.Body.String(), "checks")
+}
+
+func TestMigrationRouteRequiresAdmin(t *testing.T) {
+	os.Setenv("SECRET_KEY", "test-secret")
+	defer os.Unsetenv("SECRET_KEY")
+	a.Router = a.New()
+	token, _ := api.NewToken("not-an-admin", time.Hour)
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/migrations/community-owner-id", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	checkResponseCode(t, http.StatusForbidden, executeRequest(req).Code)
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	// apiKeyScheme is the Authorization scheme used to present an api key, e.g. "ApiKey lpc_abc123"
+	apiKeyScheme = "ApiKey "
+	// apiKeyPrefix makes keys recognizable when they get pasted somewhere they shouldn't be
+	apiKeyPrefix           = "lpc_"
+	apiKeyDisplayLength    = 12
+	maxAPIKeysPerCommunity = 10
+)
+
+// Scopes an api key can be granted
+const (
+	ScopeReadMembers   = "read:members"
+	ScopeReadCivilians = "read:civilians"
+)
+
+// validAPIKeyScopes are the scopes a community owner may grant to a key. Only add a scope here
+// once a route is behind Middleware with it, otherwise the key grants nothing.
+var validAPIKeyScopes = map[string]bool{
+	ScopeReadMembers:   true,
+	ScopeReadCivilians: true,
+}
+
+// APIKey exported for testing purposes
+type APIKey struct {
+	DB          databases.APIKeyDatabase
+	CommunityDB databases.CommunityDatabase
+	AuditLogDB  databases.AuditLogDatabase
+}
+
+// apiKeyRequest is the body accepted by CreateAPIKeyHandler
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// hashAPIKey returns the hex encoded sha256 of the key, which is all we ever store
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPIKey returns a new random api key
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// findOwnedCommunity loads the community and makes sure userID owns it
+func findOwnedCommunity(ctx context.Context, db databases.CommunityDatabase, commID, userID string) (*models.Community, int, error) {
+	cID, err := primitive.ObjectIDFromHex(commID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	community, err := db.FindOne(ctx, bson.M{"_id": cID})
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	if userID == "" || community.Details.OwnerID != userID {
+		return nil, http.StatusForbidden, errors.New("user is not the community owner")
+	}
+	return community, http.StatusOK, nil
+}
+
+// findMemberCommunity loads the community and makes sure userID owns it or has it as their
+// active community
+func findMemberCommunity(ctx context.Context, db databases.CommunityDatabase, userDB databases.UserDatabase, commID, userID string) (*models.Community, int, error) {
+	cID, err := primitive.ObjectIDFromHex(commID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	community, err := db.FindOne(ctx, bson.M{"_id": cID})
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	if userID != "" && community.Details.OwnerID == userID {
+		return community, http.StatusOK, nil
+	}
+	uID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, http.StatusForbidden, errors.New("user is not a member of the community")
+	}
+	_, err = userDB.FindOne(ctx, bson.M{"_id": uID, "user.activeCommunity": commID})
+	if err == mongo.ErrNoDocuments {
+		return nil, http.StatusForbidden, errors.New("user is not a member of the community")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return community, http.StatusOK, nil
+}
+
+// CreateAPIKeyHandler creates a read-only api key for a community. Only the community owner can
+// create keys, and the plaintext key is only ever returned in this response.
+//
+// The limit of maxAPIKeysPerCommunity is best-effort: the keys are counted before the insert, so
+// two requests racing each other can both get under the limit. Keys are created by hand by the
+// owner, so going one or two over is accepted rather than locking the community.
+func (k APIKey) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, user_id: %v", commID, userID)
+
+	var req apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		config.ErrorStatus("failed to decode request body", http.StatusBadRequest, w, err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		config.ErrorStatus("name is required", http.StatusBadRequest, w, errors.New("missing name"))
+		return
+	}
+	if len(req.Scopes) == 0 {
+		config.ErrorStatus("at least one scope is required", http.StatusBadRequest, w, errors.New("missing scopes"))
+		return
+	}
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := map[string]bool{}
+	for _, scope := range req.Scopes {
+		if !validAPIKeyScopes[scope] {
+			config.ErrorStatus("invalid scope", http.StatusBadRequest, w, fmt.Errorf("unknown scope '%s'", scope))
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	if _, status, err := findOwnedCommunity(ctx, k.CommunityDB, commID, userID); err != nil {
+		config.ErrorStatus("failed to get community owned by user", status, w, err)
+		return
+	}
+
+	existing, err := k.DB.Find(ctx, bson.M{"communityId": commID, "revoked": false})
+	if err != nil {
+		config.ErrorStatus("failed to get api keys", http.StatusInternalServerError, w, err)
+		return
+	}
+	if len(existing) >= maxAPIKeysPerCommunity {
+		config.ErrorStatus("api key limit reached", http.StatusConflict, w, fmt.Errorf("a community can have at most %d api keys", maxAPIKeysPerCommunity))
+		return
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		config.ErrorStatus("failed to generate api key", http.StatusInternalServerError, w, err)
+		return
+	}
+	apiKey := models.APIKey{
+		ID:          primitive.NewObjectID(),
+		CommunityID: commID,
+		Name:        req.Name,
+		Prefix:      key[:apiKeyDisplayLength],
+		KeyHash:     hashAPIKey(key),
+		Scopes:      scopes,
+		CreatedBy:   userID,
+		CreatedAt:   primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if _, err := k.DB.InsertOne(ctx, apiKey); err != nil {
+		config.ErrorStatus("failed to create api key", http.StatusInternalServerError, w, err)
+		return
+	}
+	recordAudit(k.AuditLogDB, models.AuditLogEntry{
+		CommunityID: commID,
+		ActorUserID: userID,
+		Action:      auditAPIKeyCreate,
+		TargetType:  "apiKey",
+		TargetID:    apiKey.ID.Hex(),
+		Details:     map[string]interface{}{"name": apiKey.Name, "scopes": apiKey.Scopes},
+	})
+
+	respondJSON(w, http.StatusCreated, models.APIKeyCreatedResponse{Key: key, APIKey: apiKey})
+}
+
+// APIKeysHandler returns all api keys of a community, without the keys themselves
+func (k APIKey) APIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, user_id: %v", commID, userID)
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	if _, status, err := findOwnedCommunity(ctx, k.CommunityDB, commID, userID); err != nil {
+		config.ErrorStatus("failed to get community owned by user", status, w, err)
+		return
+	}
+
+	dbResp, err := k.DB.Find(ctx, bson.M{"communityId": commID})
+	if err != nil {
+		config.ErrorStatus("failed to get api keys", http.StatusNotFound, w, err)
+		return
+	}
+	// Because the frontend requires that the data elements inside models.APIKey exist, if
+	// len == 0 then we will just return an empty data object
+	if len(dbResp) == 0 {
+		dbResp = []models.APIKey{}
+	}
+	respondJSON(w, http.StatusOK, dbResp)
+}
+
+// RevokeAPIKeyHandler revokes an api key. Revoked keys are kept for their usage history.
+func (k APIKey) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	keyID := mux.Vars(r)["api_key_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, api_key_id: %v, user_id: %v", commID, keyID, userID)
+
+	kID, err := primitive.ObjectIDFromHex(keyID)
+	if err != nil {
+		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
+		return
+	}
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	if _, status, err := findOwnedCommunity(ctx, k.CommunityDB, commID, userID); err != nil {
+		config.ErrorStatus("failed to get community owned by user", status, w, err)
+		return
+	}
+
+	res, err := k.DB.UpdateOne(ctx,
+		bson.M{"_id": kID, "communityId": commID},
+		bson.M{"$set": bson.M{"revoked": true, "revokedAt": primitive.NewDateTimeFromTime(time.Now())}},
+	)
+	if err != nil {
+		config.ErrorStatus("failed to revoke api key", http.StatusInternalServerError, w, err)
+		return
+	}
+	if res.MatchedCount == 0 {
+		config.ErrorStatus("failed to get api key by ID", http.StatusNotFound, w, errors.New("api key not found"))
+		return
+	}
+	recordAudit(k.AuditLogDB, models.AuditLogEntry{
+		CommunityID: commID,
+		ActorUserID: userID,
+		Action:      auditAPIKeyRevoke,
+		TargetType:  "apiKey",
+		TargetID:    keyID,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Middleware lets a community api key with the given scope read the wrapped route. Requests
+// without an api key fall through to the regular token authentication in api.Middleware.
+// The route must carry the community in a community_id or active_community_id path variable.
+func (k APIKey) Middleware(scope string, next http.Handler) http.Handler {
+	authenticated := api.Middleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authHeader, apiKeyScheme) {
+			authenticated.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		ctx, cancel := api.WithQueryTimeout(r.Context())
+		defer cancel()
+
+		apiKey, err := k.DB.FindOne(ctx, bson.M{"keyHash": hashAPIKey(strings.TrimPrefix(authHeader, apiKeyScheme))})
+		if err != nil || apiKey.Revoked {
+			if err == nil {
+				err = errors.New("api key has been revoked")
+			}
+			config.ErrorStatus("invalid api key", http.StatusUnauthorized, w, err)
+			return
+		}
+		if isMutatingMethod(r.Method) {
+			config.ErrorStatus("api keys are read-only", http.StatusForbidden, w, fmt.Errorf("method %s not allowed with an api key", r.Method))
+			return
+		}
+		vars := mux.Vars(r)
+		commID := vars["community_id"]
+		if commID == "" {
+			commID = vars["active_community_id"]
+		}
+		if commID == "" || commID != apiKey.CommunityID {
+			config.ErrorStatus("api key does not belong to this community", http.StatusForbidden, w, errors.New("community mismatch"))
+			return
+		}
+		if !hasScope(apiKey.Scopes, scope) {
+			config.ErrorStatus(fmt.Sprintf("api key is missing the %s scope", scope), http.StatusForbidden, w, errors.New("missing scope"))
+			return
+		}
+
+		// usage tracking is best effort, it should never fail the request
+		_, err = k.DB.UpdateOne(ctx,
+			bson.M{"_id": apiKey.ID},
+			bson.M{
+				"$set": bson.M{"lastUsedAt": primitive.NewDateTimeFromTime(time.Now())},
+				"$inc": bson.M{"requestCount": 1},
+			},
+		)
+		if err != nil {
+			zap.S().With(err).Warnw("failed to track api key usage", "api_key_id", apiKey.ID.Hex())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
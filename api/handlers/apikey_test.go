@@ -0,0 +1,260 @@
+package handlers_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	apiKeyCommunityID = "608cafe595eb9dc05379b7f4"
+	apiKeyOwnerID     = "owner-user"
+	testAPIKey        = "lpc_0123456789abcdef"
+)
+
+func testAPIKeyHash() string {
+	sum := sha256.Sum256([]byte(testAPIKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func ownedCommunityDB() *mocks.CommunityDatabase {
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(&models.Community{ID: apiKeyCommunityID, Details: models.CommunityDetails{OwnerID: apiKeyOwnerID}}, nil)
+	return db
+}
+
+func apiKeyDBWithKey(key *models.APIKey) *mocks.APIKeyDatabase {
+	db := &mocks.APIKeyDatabase{}
+	if key == nil {
+		db.On("FindOne", mock.Anything, bson.M{"keyHash": testAPIKeyHash()}).Return(nil, mongo.ErrNoDocuments)
+	} else {
+		db.On("FindOne", mock.Anything, bson.M{"keyHash": testAPIKeyHash()}).Return(key, nil)
+	}
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	return db
+}
+
+func serveWithAPIKey(k handlers.APIKey, scope, method string, vars map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/api/v1/some/route", nil)
+	req.Header.Set("Authorization", "ApiKey "+testAPIKey)
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	k.Middleware(scope, okHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAPIKey_MiddlewareMembersRead(t *testing.T) {
+	db := apiKeyDBWithKey(&models.APIKey{ID: primitive.NewObjectID(), CommunityID: apiKeyCommunityID, Scopes: []string{handlers.ScopeReadMembers}})
+	k := handlers.APIKey{DB: db}
+
+	rr := serveWithAPIKey(k, handlers.ScopeReadMembers, "GET", map[string]string{"active_community_id": apiKeyCommunityID})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	// usage is tracked on every accepted request
+	db.AssertNumberOfCalls(t, "UpdateOne", 1)
+	// both the lookup and the usage tracking run with the query timeout
+	for _, call := range db.Calls {
+		_, ok := call.Arguments.Get(0).(context.Context).Deadline()
+		assert.True(t, ok, call.Method)
+	}
+}
+
+func TestAPIKey_MiddlewareCrossCommunityRejected(t *testing.T) {
+	db := apiKeyDBWithKey(&models.APIKey{ID: primitive.NewObjectID(), CommunityID: apiKeyCommunityID, Scopes: []string{handlers.ScopeReadMembers}})
+	k := handlers.APIKey{DB: db}
+
+	rr := serveWithAPIKey(k, handlers.ScopeReadMembers, "GET", map[string]string{"active_community_id": "608cafe595eb9dc05379ffff"})
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "does not belong to this community")
+	db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAPIKey_MiddlewareMissingScope(t *testing.T) {
+	db := apiKeyDBWithKey(&models.APIKey{ID: primitive.NewObjectID(), CommunityID: apiKeyCommunityID, Scopes: []string{handlers.ScopeReadMembers}})
+	k := handlers.APIKey{DB: db}
+
+	rr := serveWithAPIKey(k, handlers.ScopeReadCivilians, "GET", map[string]string{"community_id": apiKeyCommunityID})
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "read:civilians")
+}
+
+func TestAPIKey_MiddlewareRejectsWrites(t *testing.T) {
+	db := apiKeyDBWithKey(&models.APIKey{ID: primitive.NewObjectID(), CommunityID: apiKeyCommunityID, Scopes: []string{handlers.ScopeReadCivilians}})
+	k := handlers.APIKey{DB: db}
+
+	rr := serveWithAPIKey(k, handlers.ScopeReadCivilians, "POST", map[string]string{"community_id": apiKeyCommunityID})
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "read-only")
+}
+
+func TestAPIKey_MiddlewareRevokedKey(t *testing.T) {
+	db := apiKeyDBWithKey(&models.APIKey{ID: primitive.NewObjectID(), CommunityID: apiKeyCommunityID, Scopes: []string{handlers.ScopeReadMembers}, Revoked: true})
+	k := handlers.APIKey{DB: db}
+
+	rr := serveWithAPIKey(k, handlers.ScopeReadMembers, "GET", map[string]string{"active_community_id": apiKeyCommunityID})
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAPIKey_MiddlewareUnknownKey(t *testing.T) {
+	k := handlers.APIKey{DB: apiKeyDBWithKey(nil)}
+
+	rr := serveWithAPIKey(k, handlers.ScopeReadMembers, "GET", map[string]string{"active_community_id": apiKeyCommunityID})
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAPIKey_MiddlewareFallsBackToToken(t *testing.T) {
+	db := &mocks.APIKeyDatabase{}
+	k := handlers.APIKey{DB: db}
+
+	req, _ := http.NewRequest("GET", "/api/v1/users/"+apiKeyCommunityID, nil)
+	rr := httptest.NewRecorder()
+	k.Middleware(handlers.ScopeReadMembers, okHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "malformed authorization token")
+	db.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+}
+
+func newAPIKeyRequest(method, userID, body string, vars map[string]string) *http.Request {
+	req, _ := http.NewRequest(method, "/api/v1/community/"+apiKeyCommunityID+"/api-keys", strings.NewReader(body))
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	return mux.SetURLVars(req, vars)
+}
+
+func TestAPIKey_CreateAPIKeyHandler(t *testing.T) {
+	db := &mocks.APIKeyDatabase{}
+	db.On("Find", mock.Anything, mock.Anything).Return([]models.APIKey{}, nil)
+	db.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+	k := handlers.APIKey{DB: db, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	req := newAPIKeyRequest("POST", apiKeyOwnerID, `{"name": "stats bot", "scopes": ["read:members", "read:members"]}`, map[string]string{"community_id": apiKeyCommunityID})
+	http.HandlerFunc(k.CreateAPIKeyHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var got models.APIKeyCreatedResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.True(t, strings.HasPrefix(got.Key, "lpc_"))
+	assert.Equal(t, []string{handlers.ScopeReadMembers}, got.APIKey.Scopes)
+	assert.NotContains(t, rr.Body.String(), "keyHash")
+
+	// only the hash of the key is stored
+	stored := db.Calls[1].Arguments.Get(1).(models.APIKey)
+	sum := sha256.Sum256([]byte(got.Key))
+	assert.Equal(t, hex.EncodeToString(sum[:]), stored.KeyHash)
+	assert.Equal(t, apiKeyCommunityID, stored.CommunityID)
+}
+
+func TestAPIKey_MiddlewareCiviliansRead(t *testing.T) {
+	db := apiKeyDBWithKey(&models.APIKey{ID: primitive.NewObjectID(), CommunityID: apiKeyCommunityID, Scopes: []string{handlers.ScopeReadCivilians}})
+	k := handlers.APIKey{DB: db}
+
+	rr := serveWithAPIKey(k, handlers.ScopeReadCivilians, "GET", map[string]string{"community_id": apiKeyCommunityID})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// a civilians key can not read members
+	rr = serveWithAPIKey(k, handlers.ScopeReadMembers, "GET", map[string]string{"active_community_id": apiKeyCommunityID})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestAPIKey_CreateAPIKeyHandlerNotOwner(t *testing.T) {
+	db := &mocks.APIKeyDatabase{}
+	k := handlers.APIKey{DB: db, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	req := newAPIKeyRequest("POST", "someone-else", `{"name": "stats bot", "scopes": ["read:members"]}`, map[string]string{"community_id": apiKeyCommunityID})
+	http.HandlerFunc(k.CreateAPIKeyHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	db.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestAPIKey_CreateAPIKeyHandlerInvalidScope(t *testing.T) {
+	// read:panic has no route behind it, so it can not be granted
+	for _, scope := range []string{"write:members", "read:panic"} {
+		t.Run(scope, func(t *testing.T) {
+			db := &mocks.APIKeyDatabase{}
+			k := handlers.APIKey{DB: db, CommunityDB: ownedCommunityDB()}
+
+			rr := httptest.NewRecorder()
+			req := newAPIKeyRequest("POST", apiKeyOwnerID, `{"name": "stats bot", "scopes": ["`+scope+`"]}`, map[string]string{"community_id": apiKeyCommunityID})
+			http.HandlerFunc(k.CreateAPIKeyHandler).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			db.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAPIKey_CreateAPIKeyHandlerLimitReached(t *testing.T) {
+	db := &mocks.APIKeyDatabase{}
+	db.On("Find", mock.Anything, mock.Anything).Return(make([]models.APIKey, 10), nil)
+	k := handlers.APIKey{DB: db, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	req := newAPIKeyRequest("POST", apiKeyOwnerID, `{"name": "stats bot", "scopes": ["read:members"]}`, map[string]string{"community_id": apiKeyCommunityID})
+	http.HandlerFunc(k.CreateAPIKeyHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	db.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestAPIKey_APIKeysHandler(t *testing.T) {
+	db := &mocks.APIKeyDatabase{}
+	db.On("Find", mock.Anything, bson.M{"communityId": apiKeyCommunityID}).Return([]models.APIKey{{Name: "stats bot", KeyHash: "secret-hash"}}, nil)
+	k := handlers.APIKey{DB: db, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	req := newAPIKeyRequest("GET", apiKeyOwnerID, "", map[string]string{"community_id": apiKeyCommunityID})
+	http.HandlerFunc(k.APIKeysHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "stats bot")
+	assert.NotContains(t, rr.Body.String(), "secret-hash")
+}
+
+func TestAPIKey_RevokeAPIKeyHandler(t *testing.T) {
+	db := &mocks.APIKeyDatabase{}
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	k := handlers.APIKey{DB: db, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	req := newAPIKeyRequest("DELETE", apiKeyOwnerID, "", map[string]string{"community_id": apiKeyCommunityID, "api_key_id": "608cafe595eb9dc05379aaaa"})
+	http.HandlerFunc(k.RevokeAPIKeyHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+}
+
+func TestAPIKey_RevokeAPIKeyHandlerNotFound(t *testing.T) {
+	db := &mocks.APIKeyDatabase{}
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	k := handlers.APIKey{DB: db, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	req := newAPIKeyRequest("DELETE", apiKeyOwnerID, "", map[string]string{"community_id": apiKeyCommunityID, "api_key_id": "608cafe595eb9dc05379aaaa"})
+	http.HandlerFunc(k.RevokeAPIKeyHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+// Audit log actions, these are the values the action filter accepts
+const (
+	auditSlugUpdate      = "community.slug.update"
+	auditAPIKeyCreate    = "apikey.create"
+	auditAPIKeyRevoke    = "apikey.revoke"
+	auditCivilianDelete  = "civilian.bundle.delete"
+	auditCommunityExport = "community.export"
+	auditBroadcastCreate = "community.broadcast.create"
+	auditBroadcastDelete = "community.broadcast.delete"
+)
+
+const (
+	defaultAuditLogLimit = 20
+	maxAuditLogLimit     = 100
+)
+
+// AuditLog exported for testing purposes
+type AuditLog struct {
+	DB          databases.AuditLogDatabase
+	CommunityDB databases.CommunityDatabase
+}
+
+// recordAudit writes an audit log entry. It is best effort, a failed write is logged
+// and never fails the action being audited. A nil db disables auditing.
+func recordAudit(db databases.AuditLogDatabase, entry models.AuditLogEntry) {
+	if db == nil {
+		return
+	}
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	// the action already happened, so the entry is written even if the client has gone away
+	ctx, cancel := api.WithQueryTimeout(context.Background())
+	defer cancel()
+	if _, err := db.InsertOne(ctx, entry); err != nil {
+		zap.S().With(err).Warnw("failed to write audit log entry",
+			"community_id", entry.CommunityID,
+			"action", entry.Action,
+			"target_id", entry.TargetID,
+		)
+	}
+}
+
+// AuditLogHandler returns the audit log of a community, newest first. Only the owner can read it.
+// It can be filtered by action and actorUserId and is paginated with page and limit.
+func (a AuditLog) AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	userID := getUserIDFromRequest(r)
+	action := r.URL.Query().Get("action")
+	actorUserID := r.URL.Query().Get("actorUserId")
+
+	zap.S().Debugf("community_id: %v, user_id: %v, action: %v, actorUserId: %v", commID, userID, action, actorUserID)
+
+	p := parsePageLimit(r, pageDefaults{Limit: defaultAuditLogLimit, MaxLimit: maxAuditLogLimit})
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	if _, status, err := findOwnedCommunity(ctx, a.CommunityDB, commID, userID); err != nil {
+		config.ErrorStatus("failed to get community owned by user", status, w, err)
+		return
+	}
+
+	filter := bson.M{"communityId": commID}
+	if action != "" {
+		filter["action"] = action
+	}
+	if actorUserID != "" {
+		filter["actorUserId"] = actorUserID
+	}
+	opts := p.findOptions().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+	dbResp, err := a.DB.Find(ctx, filter, opts)
+	if err != nil {
+		config.ErrorStatus("failed to get audit log", http.StatusNotFound, w, err)
+		return
+	}
+	// Because the frontend requires that the data elements inside models.AuditLogEntry exist, if
+	// len == 0 then we will just return an empty data object
+	if len(dbResp) == 0 {
+		dbResp = []models.AuditLogEntry{}
+	}
+	respondJSON(w, http.StatusOK, dbResp)
+}
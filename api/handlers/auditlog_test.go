@@ -0,0 +1,112 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func auditLogRequest(userID, query string) *http.Request {
+	req, _ := http.NewRequest("GET", "/api/v1/community/"+apiKeyCommunityID+"/audit-log?"+query, nil)
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	return mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID})
+}
+
+func TestAuditLog_SlugChangeCanBeReadBack(t *testing.T) {
+	cID, _ := primitive.ObjectIDFromHex(apiKeyCommunityID)
+	communityDB := &mocks.CommunityDatabase{}
+	communityDB.On("FindOne", mock.Anything, bson.M{"_id": cID}).Return(&models.Community{ID: apiKeyCommunityID, Details: models.CommunityDetails{OwnerID: apiKeyOwnerID, Slug: "old-slug"}}, nil)
+	communityDB.On("FindOne", mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+	communityDB.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	var written models.AuditLogEntry
+	auditDB := &mocks.AuditLogDatabase{}
+	auditDB.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil).Run(func(args mock.Arguments) {
+		written = args.Get(1).(models.AuditLogEntry)
+	})
+
+	c := handlers.Community{DB: communityDB, AuditLogDB: auditDB}
+	req, _ := http.NewRequest("PUT", "/api/v1/community/"+apiKeyCommunityID+"/slug", strings.NewReader(`{"slug": "new-slug"}`))
+	req = req.WithContext(api.WithUserID(req.Context(), apiKeyOwnerID))
+	req = mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.UpdateCommunitySlugHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// read the entry back through the audit log endpoint
+	auditDB.On("Find", mock.Anything, bson.M{"communityId": apiKeyCommunityID}, mock.Anything).Return([]models.AuditLogEntry{written}, nil)
+	a := handlers.AuditLog{DB: auditDB, CommunityDB: communityDB}
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(a.AuditLogHandler).ServeHTTP(rr, auditLogRequest(apiKeyOwnerID, ""))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got []models.AuditLogEntry
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Len(t, got, 1)
+	assert.Equal(t, apiKeyOwnerID, got[0].ActorUserID)
+	assert.Equal(t, "community.slug.update", got[0].Action)
+	assert.Equal(t, apiKeyCommunityID, got[0].TargetID)
+	assert.Equal(t, "new-slug", got[0].Details["to"])
+	assert.Equal(t, "old-slug", got[0].Details["from"])
+	assert.False(t, got[0].ID.IsZero())
+}
+
+func TestAuditLog_WriteFailureDoesNotFailRequest(t *testing.T) {
+	db := &mocks.APIKeyDatabase{}
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	auditDB := &mocks.AuditLogDatabase{}
+	auditDB.On("InsertOne", mock.Anything, mock.Anything).Return(nil, errors.New("mocked-error"))
+	k := handlers.APIKey{DB: db, CommunityDB: ownedCommunityDB(), AuditLogDB: auditDB}
+
+	rr := httptest.NewRecorder()
+	req := newAPIKeyRequest("DELETE", apiKeyOwnerID, "", map[string]string{"community_id": apiKeyCommunityID, "api_key_id": "608cafe595eb9dc05379aaaa"})
+	http.HandlerFunc(k.RevokeAPIKeyHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	auditDB.AssertNumberOfCalls(t, "InsertOne", 1)
+}
+
+func TestAuditLog_AuditLogHandlerFilters(t *testing.T) {
+	auditDB := &mocks.AuditLogDatabase{}
+	auditDB.On("Find", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	a := handlers.AuditLog{DB: auditDB, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(a.AuditLogHandler).ServeHTTP(rr, auditLogRequest(apiKeyOwnerID, "action=apikey.revoke&actorUserId=someone&page=2&limit=500"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]", rr.Body.String())
+	filter := auditDB.Calls[0].Arguments.Get(1).(bson.M)
+	assert.Equal(t, bson.M{"communityId": apiKeyCommunityID, "action": "apikey.revoke", "actorUserId": "someone"}, filter)
+	opts := auditDB.Calls[0].Arguments.Get(2).(*options.FindOptions)
+	assert.Equal(t, int64(100), *opts.Limit)
+	assert.Equal(t, int64(200), *opts.Skip)
+	assert.Equal(t, bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}, opts.Sort)
+}
+
+func TestAuditLog_AuditLogHandlerNotOwner(t *testing.T) {
+	auditDB := &mocks.AuditLogDatabase{}
+	a := handlers.AuditLog{DB: auditDB, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(a.AuditLogHandler).ServeHTTP(rr, auditLogRequest("someone-else", ""))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	auditDB.AssertNotCalled(t, "Find", mock.Anything, mock.Anything, mock.Anything)
+}
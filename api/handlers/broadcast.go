@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	maxBroadcastLength      = 500
+	defaultBroadcastMinutes = 60
+	maxBroadcastMinutes     = 24 * 60
+)
+
+// validBroadcastLevels are the levels a broadcast can be sent with
+var validBroadcastLevels = map[string]bool{
+	models.BroadcastInfo:     true,
+	models.BroadcastWarning:  true,
+	models.BroadcastCritical: true,
+}
+
+// broadcastRequest is the body accepted by CreateBroadcastHandler
+type broadcastRequest struct {
+	Message          string `json:"message"`
+	Level            string `json:"level"`
+	ExpiresInMinutes int    `json:"expiresInMinutes"`
+}
+
+// activeBroadcast returns the broadcast of the community, or nil once it has expired
+func activeBroadcast(community *models.Community, now time.Time) *models.Broadcast {
+	b := community.Details.Broadcast
+	if b == nil || !now.Before(b.ExpiresAt.Time()) {
+		return nil
+	}
+	return b
+}
+
+// CreateBroadcastHandler lets the owner push an urgent banner to every member of the community.
+// It replaces any broadcast that is still active and expires after expiresInMinutes, 60 by default.
+func (c Community) CreateBroadcastHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, user_id: %v", commID, userID)
+
+	var req broadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		config.ErrorStatus("failed to decode request body", http.StatusBadRequest, w, err)
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		config.ErrorStatus("message is required", http.StatusBadRequest, w, errors.New("missing message"))
+		return
+	}
+	if utf8.RuneCountInString(req.Message) > maxBroadcastLength {
+		config.ErrorStatus("message is too long", http.StatusBadRequest, w, fmt.Errorf("message must be at most %d characters", maxBroadcastLength))
+		return
+	}
+	if req.Level == "" {
+		req.Level = models.BroadcastInfo
+	}
+	if !validBroadcastLevels[req.Level] {
+		config.ErrorStatus("invalid level", http.StatusBadRequest, w, fmt.Errorf("unknown level '%s'", req.Level))
+		return
+	}
+	if req.ExpiresInMinutes == 0 {
+		req.ExpiresInMinutes = defaultBroadcastMinutes
+	}
+	if req.ExpiresInMinutes < 0 || req.ExpiresInMinutes > maxBroadcastMinutes {
+		config.ErrorStatus("invalid expiresInMinutes", http.StatusBadRequest, w, fmt.Errorf("expiresInMinutes must be between 1 and %d", maxBroadcastMinutes))
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	if _, status, err := findOwnedCommunity(ctx, c.DB, commID, userID); err != nil {
+		config.ErrorStatus("failed to get community owned by user", status, w, err)
+		return
+	}
+
+	now := time.Now()
+	broadcast := models.Broadcast{
+		Message:   req.Message,
+		Level:     req.Level,
+		CreatedBy: userID,
+		CreatedAt: primitive.NewDateTimeFromTime(now),
+		ExpiresAt: primitive.NewDateTimeFromTime(now.Add(time.Duration(req.ExpiresInMinutes) * time.Minute)),
+	}
+	// findOwnedCommunity already checked the ID is valid
+	cID, _ := primitive.ObjectIDFromHex(commID)
+	if _, err := c.DB.UpdateOne(ctx, bson.M{"_id": cID}, bson.M{"$set": bson.M{"community.broadcast": broadcast}}); err != nil {
+		config.ErrorStatus("failed to save broadcast", http.StatusInternalServerError, w, err)
+		return
+	}
+	recordAudit(c.AuditLogDB, models.AuditLogEntry{
+		CommunityID: commID,
+		ActorUserID: userID,
+		Action:      auditBroadcastCreate,
+		TargetType:  "community",
+		TargetID:    commID,
+		Details:     map[string]interface{}{"level": broadcast.Level, "message": broadcast.Message},
+	})
+
+	respondJSON(w, http.StatusCreated, broadcast)
+}
+
+// BroadcastHandler returns the active broadcast of a community, or 204 when there is none
+func (c Community) BroadcastHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+
+	zap.S().Debugf("community_id: %v", commID)
+
+	cID, err := primitive.ObjectIDFromHex(commID)
+	if err != nil {
+		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	community, err := c.DB.FindOne(ctx, bson.M{"_id": cID})
+	if err != nil {
+		config.ErrorStatus("failed to get community by ID", http.StatusNotFound, w, err)
+		return
+	}
+	broadcast := activeBroadcast(community, time.Now())
+	if broadcast == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	respondJSON(w, http.StatusOK, broadcast)
+}
+
+// DeleteBroadcastHandler lets the owner clear the broadcast of the community before it expires
+func (c Community) DeleteBroadcastHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, user_id: %v", commID, userID)
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	if _, status, err := findOwnedCommunity(ctx, c.DB, commID, userID); err != nil {
+		config.ErrorStatus("failed to get community owned by user", status, w, err)
+		return
+	}
+
+	cID, _ := primitive.ObjectIDFromHex(commID)
+	if _, err := c.DB.UpdateOne(ctx, bson.M{"_id": cID}, bson.M{"$unset": bson.M{"community.broadcast": ""}}); err != nil {
+		config.ErrorStatus("failed to clear broadcast", http.StatusInternalServerError, w, err)
+		return
+	}
+	recordAudit(c.AuditLogDB, models.AuditLogEntry{
+		CommunityID: commID,
+		ActorUserID: userID,
+		Action:      auditBroadcastDelete,
+		TargetType:  "community",
+		TargetID:    commID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,163 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func broadcastRequest(method, userID, body string) *http.Request {
+	req, _ := http.NewRequest(method, "/api/v1/community/"+apiKeyCommunityID+"/broadcast", strings.NewReader(body))
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	return mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID})
+}
+
+// broadcastCommunityDB returns an owned community that has the given broadcast
+func broadcastCommunityDB(broadcast *models.Broadcast) *mocks.CommunityDatabase {
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(&models.Community{ID: apiKeyCommunityID, Details: models.CommunityDetails{OwnerID: apiKeyOwnerID, Broadcast: broadcast}}, nil)
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	return db
+}
+
+func TestCommunity_CreateBroadcastHandler(t *testing.T) {
+	db := broadcastCommunityDB(nil)
+	auditDB := &mocks.AuditLogDatabase{}
+	auditDB.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+	c := handlers.Community{DB: db, AuditLogDB: auditDB}
+
+	rr := httptest.NewRecorder()
+	before := time.Now()
+	http.HandlerFunc(c.CreateBroadcastHandler).ServeHTTP(rr, broadcastRequest("POST", apiKeyOwnerID, `{"message": " server restarting ", "level": "warning", "expiresInMinutes": 15}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var got models.Broadcast
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "server restarting", got.Message)
+	assert.Equal(t, models.BroadcastWarning, got.Level)
+	assert.Equal(t, apiKeyOwnerID, got.CreatedBy)
+	assert.WithinDuration(t, before.Add(15*time.Minute), got.ExpiresAt.Time(), time.Minute)
+
+	update := db.Calls[1].Arguments.Get(2).(bson.M)
+	assert.Equal(t, got, update["$set"].(bson.M)["community.broadcast"])
+	entry := auditDB.Calls[0].Arguments.Get(1).(models.AuditLogEntry)
+	assert.Equal(t, "community.broadcast.create", entry.Action)
+}
+
+func TestCommunity_CreateBroadcastHandlerReplaces(t *testing.T) {
+	old := &models.Broadcast{Message: "priority scene", Level: models.BroadcastCritical, ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(time.Hour))}
+	db := broadcastCommunityDB(old)
+	c := handlers.Community{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CreateBroadcastHandler).ServeHTTP(rr, broadcastRequest("POST", apiKeyOwnerID, `{"message": "scene clear"}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	// the whole broadcast is overwritten, nothing of the old one survives
+	update := db.Calls[1].Arguments.Get(2).(bson.M)
+	stored := update["$set"].(bson.M)["community.broadcast"].(models.Broadcast)
+	assert.Equal(t, "scene clear", stored.Message)
+	assert.Equal(t, models.BroadcastInfo, stored.Level)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt.Time(), time.Minute)
+}
+
+func TestCommunity_CreateBroadcastHandlerInvalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"empty message":  `{"message": "   "}`,
+		"long message":   `{"message": "` + strings.Repeat("a", 501) + `"}`,
+		"unknown level":  `{"message": "hi", "level": "panic"}`,
+		"negative time":  `{"message": "hi", "expiresInMinutes": -5}`,
+		"too long lived": `{"message": "hi", "expiresInMinutes": 1441}`,
+		"bad json":       `{"message":`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			db := broadcastCommunityDB(nil)
+			c := handlers.Community{DB: db}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(c.CreateBroadcastHandler).ServeHTTP(rr, broadcastRequest("POST", apiKeyOwnerID, body))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCommunity_CreateBroadcastHandlerNotOwner(t *testing.T) {
+	db := broadcastCommunityDB(nil)
+	c := handlers.Community{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CreateBroadcastHandler).ServeHTTP(rr, broadcastRequest("POST", "someone-else", `{"message": "hi"}`))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCommunity_BroadcastHandler(t *testing.T) {
+	active := &models.Broadcast{Message: "hold traffic", Level: models.BroadcastCritical, ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(time.Minute))}
+	expired := &models.Broadcast{Message: "hold traffic", Level: models.BroadcastCritical, ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(-time.Second))}
+
+	tests := []struct {
+		name      string
+		broadcast *models.Broadcast
+		want      int
+	}{
+		{"active", active, http.StatusOK},
+		{"expired", expired, http.StatusNoContent},
+		{"none", nil, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := handlers.Community{DB: broadcastCommunityDB(tt.broadcast)}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(c.BroadcastHandler).ServeHTTP(rr, broadcastRequest("GET", "member-user", ""))
+
+			assert.Equal(t, tt.want, rr.Code)
+			if tt.want == http.StatusOK {
+				assert.Contains(t, rr.Body.String(), `"message":"hold traffic"`)
+			} else {
+				assert.Empty(t, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestCommunity_DeleteBroadcastHandler(t *testing.T) {
+	db := broadcastCommunityDB(&models.Broadcast{Message: "hi", ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(time.Hour))})
+	c := handlers.Community{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.DeleteBroadcastHandler).ServeHTTP(rr, broadcastRequest("DELETE", apiKeyOwnerID, ""))
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	update := db.Calls[1].Arguments.Get(2).(bson.M)
+	assert.Equal(t, bson.M{"community.broadcast": ""}, update["$unset"])
+}
+
+func TestCommunity_DeleteBroadcastHandlerNotOwner(t *testing.T) {
+	db := broadcastCommunityDB(nil)
+	c := handlers.Community{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.DeleteBroadcastHandler).ServeHTTP(rr, broadcastRequest("DELETE", "someone-else", ""))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+// Bundle sections, used as keys for counts and deletion receipts
+const (
+	bundleVehicles  = "vehicles"
+	bundleFirearms  = "firearms"
+	bundleLicenses  = "licenses"
+	bundleWarrants  = "warrants"
+	bundleCitations = "citations"
+	bundleReports   = "reports"
+	bundlePhotos    = "photos"
+)
+
+// CivilianBundle exported for testing purposes. A nil database means the feature is
+// not available and its section of the bundle is left empty.
+type CivilianBundle struct {
+	DB          databases.CivilianDatabase
+	CommunityDB databases.CommunityDatabase
+	VehicleDB   databases.VehicleDatabase
+	FirearmDB   databases.FirearmDatabase
+	LicenseDB   databases.LicenseDatabase
+	WarrantDB   databases.WarrantDatabase
+	CitationDB  databases.CitationDatabase
+	AuditLogDB  databases.AuditLogDatabase
+	// Tx makes the erasure atomic, without it the records are deleted one collection at a time
+	Tx databases.Transactioner
+	// ConfirmSecret signs the confirm tokens of deletions, which are refused while it is empty
+	ConfirmSecret string
+}
+
+// findCommunityCivilian loads a civilian that belongs to the community and has not been erased
+func (b CivilianBundle) findCommunityCivilian(ctx context.Context, commID, civID string) (*models.Civilian, int, error) {
+	cID, err := primitive.ObjectIDFromHex(civID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	civilian, err := b.DB.FindOne(ctx, bson.M{"_id": cID, "civilian.activeCommunityID": commID})
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	if civilian.Details.DeletedAt != nil {
+		return nil, http.StatusNotFound, errors.New("civilian has been deleted")
+	}
+	return civilian, http.StatusOK, nil
+}
+
+// civilianRefs are the values other collections use to reference the civilian. Older
+// records store the reference as an ObjectID, newer ones as its hex string.
+func civilianRefs(civilian *models.Civilian) []interface{} {
+	ids := []interface{}{civilian.ID}
+	if oID, err := primitive.ObjectIDFromHex(civilian.ID); err == nil {
+		ids = append(ids, oID)
+	}
+	return ids
+}
+
+// linkedIDs matches any reference to the civilian, see civilianRefs
+func linkedIDs(civilian *models.Civilian) bson.M {
+	return bson.M{"$in": civilianRefs(civilian)}
+}
+
+// assemble loads every record linked to the civilian with a single query per collection
+func (b CivilianBundle) assemble(ctx context.Context, civilian *models.Civilian) (*models.CivilianBundle, error) {
+	ids := linkedIDs(civilian)
+	bundle := &models.CivilianBundle{
+		Civilian:    *civilian,
+		Vehicles:    []models.Vehicle{},
+		Firearms:    []models.Firearm{},
+		Licenses:    []models.License{},
+		Warrants:    []models.Warrant{},
+		Citations:   []models.Citation{},
+		Reports:     []interface{}{},
+		Photos:      []string{},
+		GeneratedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if b.VehicleDB != nil {
+		vehicles, err := b.VehicleDB.Find(ctx, bson.M{"vehicle.registeredOwnerID": ids})
+		if err != nil {
+			return nil, err
+		}
+		if len(vehicles) > 0 {
+			bundle.Vehicles = vehicles
+		}
+	}
+	if b.FirearmDB != nil {
+		firearms, err := b.FirearmDB.Find(ctx, bson.M{"firearm.registeredOwnerID": ids})
+		if err != nil {
+			return nil, err
+		}
+		if len(firearms) > 0 {
+			bundle.Firearms = firearms
+		}
+	}
+	if b.LicenseDB != nil {
+		licenses, err := b.LicenseDB.Find(ctx, bson.M{"license.ownerID": ids})
+		if err != nil {
+			return nil, err
+		}
+		if len(licenses) > 0 {
+			bundle.Licenses = licenses
+		}
+	}
+	if b.WarrantDB != nil {
+		warrants, err := b.WarrantDB.Find(ctx, bson.M{"warrant.accusedID": ids})
+		if err != nil {
+			return nil, err
+		}
+		if len(warrants) > 0 {
+			bundle.Warrants = warrants
+		}
+	}
+	if b.CitationDB != nil {
+		citations, err := b.CitationDB.Find(ctx, bson.M{"civilianId": ids})
+		if err != nil {
+			return nil, err
+		}
+		if len(citations) > 0 {
+			bundle.Citations = citations
+		}
+	}
+	if civilian.Details.Image != "" {
+		bundle.Photos = append(bundle.Photos, civilian.Details.Image)
+	}
+	bundle.Counts = map[string]int{
+		bundleVehicles:  len(bundle.Vehicles),
+		bundleFirearms:  len(bundle.Firearms),
+		bundleLicenses:  len(bundle.Licenses),
+		bundleWarrants:  len(bundle.Warrants),
+		bundleCitations: len(bundle.Citations),
+		bundleReports:   len(bundle.Reports),
+		bundlePhotos:    len(bundle.Photos),
+	}
+	return bundle, nil
+}
+
+// CivilianBundleHandler returns a civilian together with every record linked to it. The
+// civilian's owner and the community owner can request it.
+func (b CivilianBundle) CivilianBundleHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	civID := mux.Vars(r)["civilian_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, civilian_id: %v, user_id: %v", commID, civID, userID)
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	civilian, status, err := b.findCommunityCivilian(ctx, commID, civID)
+	if err != nil {
+		config.ErrorStatus("failed to get civilian by ID", status, w, err)
+		return
+	}
+	if userID == "" || civilian.Details.UserID != userID {
+		if _, status, err := findOwnedCommunity(ctx, b.CommunityDB, commID, userID); err != nil {
+			config.ErrorStatus("user cannot view this civilian's records", status, w, err)
+			return
+		}
+	}
+
+	bundle, err := b.assemble(ctx, civilian)
+	if err != nil {
+		config.ErrorStatus("failed to get civilian records", http.StatusInternalServerError, w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, bundle)
+}
+
+// DeleteCivilianBundleHandler erases a civilian and everything linked to it. Only the
+// civilian's owner can do this. The first request returns a confirm token, sending it back
+// in the confirm_token query param performs the deletion and returns a receipt.
+func (b CivilianBundle) DeleteCivilianBundleHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	civID := mux.Vars(r)["civilian_id"]
+	userID := getUserIDFromRequest(r)
+	confirmToken := r.URL.Query().Get("confirm_token")
+
+	zap.S().Debugf("community_id: %v, civilian_id: %v, user_id: %v", commID, civID, userID)
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	civilian, status, err := b.findCommunityCivilian(ctx, commID, civID)
+	if err != nil {
+		config.ErrorStatus("failed to get civilian by ID", status, w, err)
+		return
+	}
+	if userID == "" || civilian.Details.UserID != userID {
+		config.ErrorStatus("only the owner of a civilian can delete it", http.StatusForbidden, w, errors.New("user does not own civilian"))
+		return
+	}
+
+	subject := "civilian-bundle:" + civilian.ID + ":" + userID
+	if confirmToken == "" {
+		bundle, err := b.assemble(ctx, civilian)
+		if err != nil {
+			config.ErrorStatus("failed to get civilian records", http.StatusInternalServerError, w, err)
+			return
+		}
+		expiresAt := time.Now().Add(confirmTokenLifetime)
+		token, err := newConfirmToken(b.ConfirmSecret, subject, expiresAt)
+		if err != nil {
+			config.ErrorStatus("failed to issue confirm token", http.StatusInternalServerError, w, err)
+			return
+		}
+		respondJSON(w, http.StatusPreconditionRequired, models.CivilianBundleDeleteConfirmation{
+			ConfirmToken: token,
+			ExpiresAt:    primitive.NewDateTimeFromTime(expiresAt),
+			Counts:       bundle.Counts,
+		})
+		return
+	}
+	if !checkConfirmToken(b.ConfirmSecret, confirmToken, subject, time.Now()) {
+		config.ErrorStatus("invalid or expired confirm token", http.StatusBadRequest, w, errors.New("confirm token does not match"))
+		return
+	}
+
+	var receipt *models.CivilianBundleDeletionReceipt
+	err = b.inTransaction(ctx, func(ctx context.Context) error {
+		receipt, err = b.erase(ctx, civilian)
+		return err
+	})
+	if err != nil {
+		config.ErrorStatus("failed to delete civilian records", http.StatusInternalServerError, w, err)
+		return
+	}
+	zap.S().Infow("civilian bundle deleted", "civilian_id", civilian.ID, "user_id", userID, "deleted", receipt.Deleted)
+	recordAudit(b.AuditLogDB, models.AuditLogEntry{
+		CommunityID: commID,
+		ActorUserID: userID,
+		Action:      auditCivilianDelete,
+		TargetType:  "civilian",
+		TargetID:    civilian.ID,
+		Details:     map[string]interface{}{"deleted": receipt.Deleted, "anonymized": receipt.Anonymized, "unlinked": receipt.Unlinked},
+	})
+
+	respondJSON(w, http.StatusOK, receipt)
+}
+
+// inTransaction runs fn in a transaction when the bundle has a Transactioner
+func (b CivilianBundle) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if b.Tx == nil {
+		return fn(ctx)
+	}
+	return b.Tx.WithTransaction(ctx, fn)
+}
+
+// erase deletes the civilian's own records and anonymizes the ones other users created about
+// it, like warrants. It runs in a transaction where mongo supports them. Without one the
+// civilian itself is soft deleted last, so a failure part way through can be retried.
+func (b CivilianBundle) erase(ctx context.Context, civilian *models.Civilian) (*models.CivilianBundleDeletionReceipt, error) {
+	ids := linkedIDs(civilian)
+	receipt := &models.CivilianBundleDeletionReceipt{
+		CivilianID: civilian.ID,
+		Deleted: map[string]int64{
+			bundleVehicles:  0,
+			bundleFirearms:  0,
+			bundleLicenses:  0,
+			bundleCitations: 0,
+			bundleReports:   0,
+		},
+		Anonymized: map[string]int64{
+			bundleWarrants: 0,
+		},
+		Unlinked: map[string]int64{
+			bundlePhotos: 0,
+		},
+	}
+	if b.VehicleDB != nil {
+		res, err := b.VehicleDB.DeleteMany(ctx, bson.M{"vehicle.registeredOwnerID": ids})
+		if err != nil {
+			return nil, err
+		}
+		receipt.Deleted[bundleVehicles] = res.DeletedCount
+	}
+	if b.FirearmDB != nil {
+		res, err := b.FirearmDB.DeleteMany(ctx, bson.M{"firearm.registeredOwnerID": ids})
+		if err != nil {
+			return nil, err
+		}
+		receipt.Deleted[bundleFirearms] = res.DeletedCount
+	}
+	if b.LicenseDB != nil {
+		res, err := b.LicenseDB.DeleteMany(ctx, bson.M{"license.ownerID": ids})
+		if err != nil {
+			return nil, err
+		}
+		receipt.Deleted[bundleLicenses] = res.DeletedCount
+	}
+	if b.WarrantDB != nil {
+		res, err := b.WarrantDB.UpdateMany(ctx,
+			bson.M{"warrant.accusedID": ids},
+			bson.M{"$set": bson.M{
+				"warrant.accusedID":        "",
+				"warrant.accusedFirstName": "",
+				"warrant.accusedLastName":  "",
+			}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		receipt.Anonymized[bundleWarrants] = res.ModifiedCount
+	}
+	if b.CitationDB != nil {
+		res, err := b.CitationDB.DeleteMany(ctx, bson.M{"civilianId": ids})
+		if err != nil {
+			return nil, err
+		}
+		receipt.Deleted[bundleCitations] = res.DeletedCount
+	}
+	if civilian.Details.Image != "" {
+		receipt.Unlinked[bundlePhotos] = 1
+	}
+
+	cID, err := primitive.ObjectIDFromHex(civilian.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := primitive.NewDateTimeFromTime(time.Now())
+	_, err = b.DB.UpdateOne(ctx,
+		bson.M{"_id": cID},
+		bson.M{"$set": bson.M{
+			"civilian.deletedAt": now,
+			"civilian.image":     "",
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	receipt.DeletedAt = now
+	return receipt, nil
+}
@@ -0,0 +1,314 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	bundleCivilianID = "608cafe595eb9dc05379b7f5"
+	bundleCivOwnerID = "civ-owner"
+)
+
+type bundleMocks struct {
+	civilian  *mocks.CivilianDatabase
+	community *mocks.CommunityDatabase
+	vehicle   *mocks.VehicleDatabase
+	firearm   *mocks.FirearmDatabase
+	license   *mocks.LicenseDatabase
+	warrant   *mocks.WarrantDatabase
+	citation  *mocks.CitationDatabase
+}
+
+// newBundleMocks sets up a civilian with records in three collections (vehicles,
+// licenses and warrants) and none in firearms or citations
+func newBundleMocks() (bundleMocks, handlers.CivilianBundle) {
+	m := bundleMocks{
+		civilian:  &mocks.CivilianDatabase{},
+		community: ownedCommunityDB(),
+		vehicle:   &mocks.VehicleDatabase{},
+		firearm:   &mocks.FirearmDatabase{},
+		license:   &mocks.LicenseDatabase{},
+		warrant:   &mocks.WarrantDatabase{},
+		citation:  &mocks.CitationDatabase{},
+	}
+	m.civilian.On("FindOne", mock.Anything, mock.Anything).Return(&models.Civilian{
+		ID: bundleCivilianID,
+		Details: models.CivilianDetails{
+			FirstName:         "John",
+			LastName:          "Doe",
+			Image:             "https://images.example.com/john.png",
+			UserID:            bundleCivOwnerID,
+			ActiveCommunityID: apiKeyCommunityID,
+		},
+	}, nil)
+	m.civilian.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	m.vehicle.On("Find", mock.Anything, mock.Anything).Return([]models.Vehicle{{ID: "v1"}, {ID: "v2"}}, nil)
+	m.vehicle.On("DeleteMany", mock.Anything, mock.Anything).Return(&mongo.DeleteResult{DeletedCount: 2}, nil)
+	m.firearm.On("Find", mock.Anything, mock.Anything).Return(nil, nil)
+	m.firearm.On("DeleteMany", mock.Anything, mock.Anything).Return(&mongo.DeleteResult{}, nil)
+	m.license.On("Find", mock.Anything, mock.Anything).Return([]models.License{{ID: "l1"}}, nil)
+	m.license.On("DeleteMany", mock.Anything, mock.Anything).Return(&mongo.DeleteResult{DeletedCount: 1}, nil)
+	m.warrant.On("Find", mock.Anything, mock.Anything).Return([]models.Warrant{{ID: "w1"}}, nil)
+	m.warrant.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	m.citation.On("Find", mock.Anything, mock.Anything).Return(nil, nil)
+	m.citation.On("DeleteMany", mock.Anything, mock.Anything).Return(&mongo.DeleteResult{}, nil)
+
+	return m, handlers.CivilianBundle{
+		DB:            m.civilian,
+		CommunityDB:   m.community,
+		VehicleDB:     m.vehicle,
+		FirearmDB:     m.firearm,
+		LicenseDB:     m.license,
+		WarrantDB:     m.warrant,
+		CitationDB:    m.citation,
+		ConfirmSecret: "test-secret",
+	}
+}
+
+func bundleRequest(method, userID string, query url.Values) *http.Request {
+	req, _ := http.NewRequest(method, "/api/v1/community/"+apiKeyCommunityID+"/civilians/"+bundleCivilianID+"/bundle?"+query.Encode(), nil)
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	return mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID, "civilian_id": bundleCivilianID})
+}
+
+func TestCivilianBundle_CivilianBundleHandler(t *testing.T) {
+	m, b := newBundleMocks()
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.CivilianBundleHandler).ServeHTTP(rr, bundleRequest("GET", bundleCivOwnerID, nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.CivilianBundle
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, map[string]int{
+		"vehicles":  2,
+		"firearms":  0,
+		"licenses":  1,
+		"warrants":  1,
+		"citations": 0,
+		"reports":   0,
+		"photos":    1,
+	}, got.Counts)
+	// empty sections are still present in the document
+	assert.Contains(t, rr.Body.String(), `"firearms":[]`)
+	assert.Contains(t, rr.Body.String(), `"citations":[]`)
+
+	// every collection is read with a single $in query on the civilian's ID
+	oID, _ := primitive.ObjectIDFromHex(bundleCivilianID)
+	ids := bson.M{"$in": []interface{}{bundleCivilianID, oID}}
+	m.vehicle.AssertCalled(t, "Find", mock.Anything, bson.M{"vehicle.registeredOwnerID": ids})
+	m.firearm.AssertCalled(t, "Find", mock.Anything, bson.M{"firearm.registeredOwnerID": ids})
+	m.license.AssertCalled(t, "Find", mock.Anything, bson.M{"license.ownerID": ids})
+	m.warrant.AssertCalled(t, "Find", mock.Anything, bson.M{"warrant.accusedID": ids})
+	m.citation.AssertCalled(t, "Find", mock.Anything, bson.M{"civilianId": ids})
+	m.vehicle.AssertNumberOfCalls(t, "Find", 1)
+	m.community.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+}
+
+func TestCivilianBundle_CivilianBundleHandlerCommunityOwner(t *testing.T) {
+	_, b := newBundleMocks()
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.CivilianBundleHandler).ServeHTTP(rr, bundleRequest("GET", apiKeyOwnerID, nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestCivilianBundle_CivilianBundleHandlerForbidden(t *testing.T) {
+	m, b := newBundleMocks()
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.CivilianBundleHandler).ServeHTTP(rr, bundleRequest("GET", "someone-else", nil))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	m.vehicle.AssertNotCalled(t, "Find", mock.Anything, mock.Anything)
+}
+
+func TestCivilianBundle_CivilianBundleHandlerMissingFeatures(t *testing.T) {
+	m, _ := newBundleMocks()
+	b := handlers.CivilianBundle{DB: m.civilian, CommunityDB: m.community}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.CivilianBundleHandler).ServeHTTP(rr, bundleRequest("GET", bundleCivOwnerID, nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.CivilianBundle
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, 0, got.Counts["vehicles"])
+	assert.Equal(t, []models.Warrant{}, got.Warrants)
+}
+
+func TestCivilianBundle_CivilianBundleHandlerDeletedCivilian(t *testing.T) {
+	m, b := newBundleMocks()
+	m.civilian.ExpectedCalls = nil
+	m.civilian.On("FindOne", mock.Anything, mock.Anything).Return(&models.Civilian{
+		ID:      bundleCivilianID,
+		Details: models.CivilianDetails{UserID: bundleCivOwnerID, DeletedAt: primitive.NewDateTimeFromTime(time.Now())},
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.CivilianBundleHandler).ServeHTTP(rr, bundleRequest("GET", bundleCivOwnerID, nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestCivilianBundle_DeleteCivilianBundleHandler(t *testing.T) {
+	m, b := newBundleMocks()
+
+	// the first request only returns a confirm token
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", bundleCivOwnerID, nil))
+
+	assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+	var confirmation models.CivilianBundleDeleteConfirmation
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &confirmation))
+	assert.NotEmpty(t, confirmation.ConfirmToken)
+	assert.Equal(t, 2, confirmation.Counts["vehicles"])
+	m.vehicle.AssertNotCalled(t, "DeleteMany", mock.Anything, mock.Anything)
+	m.civilian.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+
+	// sending the token back erases the bundle
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", bundleCivOwnerID, url.Values{"confirm_token": {confirmation.ConfirmToken}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var receipt models.CivilianBundleDeletionReceipt
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &receipt))
+	assert.Equal(t, bundleCivilianID, receipt.CivilianID)
+	assert.Equal(t, int64(2), receipt.Deleted["vehicles"])
+	assert.Equal(t, int64(0), receipt.Deleted["firearms"])
+	assert.Equal(t, int64(1), receipt.Deleted["licenses"])
+	assert.Equal(t, int64(0), receipt.Deleted["citations"])
+	// the photo is only unlinked, the file itself is hosted elsewhere
+	assert.NotContains(t, receipt.Deleted, "photos")
+	assert.Equal(t, int64(1), receipt.Unlinked["photos"])
+	assert.Equal(t, int64(1), receipt.Anonymized["warrants"])
+	m.civilian.AssertNumberOfCalls(t, "UpdateOne", 1)
+}
+
+// fakeTx runs fn straight away and remembers whether it would have committed or aborted
+type fakeTx struct {
+	committed, aborted bool
+}
+
+func (tx *fakeTx) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		tx.aborted = true
+		return err
+	}
+	tx.committed = true
+	return nil
+}
+
+func deleteBundleConfirmToken(t *testing.T, b handlers.CivilianBundle) string {
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", bundleCivOwnerID, nil))
+	var confirmation models.CivilianBundleDeleteConfirmation
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &confirmation))
+	return confirmation.ConfirmToken
+}
+
+func TestCivilianBundle_DeleteCivilianBundleHandlerTransaction(t *testing.T) {
+	_, b := newBundleMocks()
+	tx := &fakeTx{}
+	b.Tx = tx
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", bundleCivOwnerID, url.Values{"confirm_token": {deleteBundleConfirmToken(t, b)}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, tx.committed)
+	assert.False(t, tx.aborted)
+}
+
+func TestCivilianBundle_DeleteCivilianBundleHandlerTransactionAborted(t *testing.T) {
+	m, b := newBundleMocks()
+	firearm := &mocks.FirearmDatabase{}
+	firearm.On("Find", mock.Anything, mock.Anything).Return(nil, nil)
+	firearm.On("DeleteMany", mock.Anything, mock.Anything).Return(nil, errors.New("write conflict"))
+	b.FirearmDB = firearm
+	auditLog := &mocks.AuditLogDatabase{}
+	b.AuditLogDB = auditLog
+	tx := &fakeTx{}
+	b.Tx = tx
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", bundleCivOwnerID, url.Values{"confirm_token": {deleteBundleConfirmToken(t, b)}}))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.True(t, tx.aborted)
+	assert.False(t, tx.committed)
+	// the civilian is never soft deleted and nothing is audited
+	m.civilian.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+	auditLog.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestCivilianBundle_DeleteCivilianBundleHandlerBadToken(t *testing.T) {
+	m, b := newBundleMocks()
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", bundleCivOwnerID, url.Values{"confirm_token": {"4102444800.deadbeef"}}))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	m.vehicle.AssertNotCalled(t, "DeleteMany", mock.Anything, mock.Anything)
+}
+
+func TestCivilianBundle_DeleteCivilianBundleHandlerNotCivilianOwner(t *testing.T) {
+	m, b := newBundleMocks()
+
+	// not even the community owner can erase someone else's civilian
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", apiKeyOwnerID, nil))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	m.vehicle.AssertNotCalled(t, "DeleteMany", mock.Anything, mock.Anything)
+}
+
+func TestCivilianBundle_DeleteCivilianBundleHandlerNoSecret(t *testing.T) {
+	m, b := newBundleMocks()
+	token := deleteBundleConfirmToken(t, b)
+	b.ConfirmSecret = ""
+
+	// no token is issued without a secret to sign it
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", bundleCivOwnerID, nil))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "confirmToken")
+
+	// and none is accepted, not even one signed before the secret went away
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", bundleCivOwnerID, url.Values{"confirm_token": {token}}))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	m.vehicle.AssertNotCalled(t, "DeleteMany", mock.Anything, mock.Anything)
+}
+
+func TestCivilianBundle_DeleteCivilianBundleHandlerOtherSecret(t *testing.T) {
+	m, b := newBundleMocks()
+	token := deleteBundleConfirmToken(t, b)
+	b.ConfirmSecret = "rotated-secret"
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(b.DeleteCivilianBundleHandler).ServeHTTP(rr, bundleRequest("DELETE", bundleCivOwnerID, url.Values{"confirm_token": {token}}))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	m.vehicle.AssertNotCalled(t, "DeleteMany", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	defaultCitationLimit = 20
+	maxCitationLimit     = 100
+)
+
+// validCitationStatuses are the statuses a citation can be moved to
+var validCitationStatuses = map[string]bool{
+	models.CitationUnpaid:    true,
+	models.CitationPaid:      true,
+	models.CitationDismissed: true,
+}
+
+// Citation exported for testing purposes
+type Citation struct {
+	DB          databases.CitationDatabase
+	CivilianDB  databases.CivilianDatabase
+	CommunityDB databases.CommunityDatabase
+	UserDB      databases.UserDatabase
+}
+
+// citationRequest is the body accepted by CreateCitationHandler
+type citationRequest struct {
+	CivilianID string  `json:"civilianId"`
+	Name       string  `json:"name"`
+	Amount     float64 `json:"amount"`
+	Notes      string  `json:"notes"`
+}
+
+// citationStatusRequest is the body accepted by UpdateCitationStatusHandler
+type citationStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// CreateCitationHandler issues a citation against a civilian of the community. Only the owner
+// and members of the community can issue one.
+func (c Citation) CreateCitationHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, user_id: %v", commID, userID)
+
+	if userID == "" {
+		config.ErrorStatus("a signed in user is required to issue a citation", http.StatusUnauthorized, w, errors.New("token does not identify a user"))
+		return
+	}
+	var req citationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		config.ErrorStatus("failed to decode request body", http.StatusBadRequest, w, err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		config.ErrorStatus("name is required", http.StatusBadRequest, w, errors.New("missing name"))
+		return
+	}
+	if req.Amount < 0 {
+		config.ErrorStatus("amount cannot be negative", http.StatusBadRequest, w, fmt.Errorf("invalid amount %v", req.Amount))
+		return
+	}
+	civID, err := primitive.ObjectIDFromHex(req.CivilianID)
+	if err != nil {
+		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	if _, status, err := findMemberCommunity(ctx, c.CommunityDB, c.UserDB, commID, userID); err != nil {
+		config.ErrorStatus("failed to get community of user", status, w, err)
+		return
+	}
+
+	civilian, err := c.CivilianDB.FindOne(ctx, bson.M{"_id": civID})
+	if err != nil || civilian.Details.DeletedAt != nil {
+		if err == nil {
+			err = errors.New("civilian has been deleted")
+		}
+		config.ErrorStatus("failed to get civilian by ID", http.StatusNotFound, w, err)
+		return
+	}
+	if civilian.Details.ActiveCommunityID != commID {
+		config.ErrorStatus("civilian does not belong to this community", http.StatusBadRequest, w, errors.New("community mismatch"))
+		return
+	}
+
+	citation := models.Citation{
+		ID:             primitive.NewObjectID(),
+		CommunityID:    commID,
+		CivilianID:     req.CivilianID,
+		IssuedByUserID: userID,
+		Name:           req.Name,
+		Amount:         req.Amount,
+		Notes:          req.Notes,
+		Status:         models.CitationUnpaid,
+		IssuedAt:       primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if _, err := c.DB.InsertOne(ctx, citation); err != nil {
+		config.ErrorStatus("failed to create citation", http.StatusInternalServerError, w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, citation)
+}
+
+// CivilianCitationsHandler returns the citations of a civilian within a community, newest first
+func (c Citation) CivilianCitationsHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	civID := mux.Vars(r)["civilian_id"]
+	p := parsePageLimit(r, pageDefaults{Limit: defaultCitationLimit, MaxLimit: maxCitationLimit})
+
+	zap.S().Debugf("community_id: %v, civilian_id: %v", commID, civID)
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	opts := p.findOptions().
+		SetSort(bson.D{{Key: "issuedAt", Value: -1}, {Key: "_id", Value: -1}})
+	dbResp, err := c.DB.Find(ctx, bson.M{"communityId": commID, "civilianId": civID}, opts)
+	if err != nil {
+		config.ErrorStatus("failed to get citations", http.StatusNotFound, w, err)
+		return
+	}
+	// Because the frontend requires that the data elements inside models.Citation exist, if
+	// len == 0 then we will just return an empty data object
+	if len(dbResp) == 0 {
+		dbResp = []models.Citation{}
+	}
+	respondJSON(w, http.StatusOK, dbResp)
+}
+
+// UpdateCitationStatusHandler marks a citation as paid, dismissed or back to unpaid. Only the
+// user who issued it and the community owner can change it.
+func (c Citation) UpdateCitationStatusHandler(w http.ResponseWriter, r *http.Request) {
+	citationID := mux.Vars(r)["citation_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("citation_id: %v, user_id: %v", citationID, userID)
+
+	cID, err := primitive.ObjectIDFromHex(citationID)
+	if err != nil {
+		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
+		return
+	}
+	var req citationStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		config.ErrorStatus("failed to decode request body", http.StatusBadRequest, w, err)
+		return
+	}
+	if !validCitationStatuses[req.Status] {
+		config.ErrorStatus("invalid status", http.StatusBadRequest, w, fmt.Errorf("unknown status '%s'", req.Status))
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	citation, err := c.DB.FindOne(ctx, bson.M{"_id": cID})
+	if err != nil {
+		config.ErrorStatus("failed to get citation by ID", http.StatusNotFound, w, err)
+		return
+	}
+	if userID == "" || citation.IssuedByUserID != userID {
+		if _, status, err := findOwnedCommunity(ctx, c.CommunityDB, citation.CommunityID, userID); err != nil {
+			config.ErrorStatus("user cannot update this citation", status, w, err)
+			return
+		}
+	}
+
+	set := bson.M{"status": req.Status}
+	update := bson.M{"$set": set}
+	citation.Status = req.Status
+	if req.Status == models.CitationUnpaid {
+		update["$unset"] = bson.M{"resolvedAt": ""}
+		citation.ResolvedAt = 0
+	} else {
+		citation.ResolvedAt = primitive.NewDateTimeFromTime(time.Now())
+		set["resolvedAt"] = citation.ResolvedAt
+	}
+	if _, err := c.DB.UpdateOne(ctx, bson.M{"_id": cID}, update); err != nil {
+		config.ErrorStatus("failed to update citation", http.StatusInternalServerError, w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, citation)
+}
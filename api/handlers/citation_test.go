@@ -0,0 +1,243 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	citationID      = "608cafe595eb9dc05379cccc"
+	citationOfficer = "608cafe595eb9dc05379aaaa"
+)
+
+// citationMemberDB has citationOfficer as a member of the api key community
+func citationMemberDB() *mocks.UserDatabase {
+	oID, _ := primitive.ObjectIDFromHex(citationOfficer)
+	db := &mocks.UserDatabase{}
+	db.On("FindOne", mock.Anything, bson.M{"_id": oID, "user.activeCommunity": apiKeyCommunityID}).Return(&models.User{ID: citationOfficer}, nil)
+	db.On("FindOne", mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+	return db
+}
+
+func citationCivilianDB(activeCommunityID string) *mocks.CivilianDatabase {
+	db := &mocks.CivilianDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(&models.Civilian{
+		ID:      bundleCivilianID,
+		Details: models.CivilianDetails{ActiveCommunityID: activeCommunityID},
+	}, nil)
+	return db
+}
+
+func createCitationRequest(body string) *http.Request {
+	return createCitationRequestAs(citationOfficer, body)
+}
+
+func createCitationRequestAs(userID, body string) *http.Request {
+	req, _ := http.NewRequest("POST", "/api/v1/community/"+apiKeyCommunityID+"/citations", strings.NewReader(body))
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	return mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID})
+}
+
+func TestCitation_CreateCitationHandler(t *testing.T) {
+	db := &mocks.CitationDatabase{}
+	db.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+	c := handlers.Citation{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CreateCitationHandler).ServeHTTP(rr, createCitationRequest(`{"civilianId": "`+bundleCivilianID+`", "name": "Speeding", "amount": 250}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var got models.Citation
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, models.CitationUnpaid, got.Status)
+	assert.Equal(t, citationOfficer, got.IssuedByUserID)
+	assert.Equal(t, apiKeyCommunityID, got.CommunityID)
+	assert.Equal(t, float64(250), got.Amount)
+	db.AssertNumberOfCalls(t, "InsertOne", 1)
+}
+
+func TestCitation_CreateCitationHandlerWrongCommunity(t *testing.T) {
+	db := &mocks.CitationDatabase{}
+	c := handlers.Citation{DB: db, CivilianDB: citationCivilianDB("608cafe595eb9dc05379ffff"), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CreateCitationHandler).ServeHTTP(rr, createCitationRequest(`{"civilianId": "`+bundleCivilianID+`", "name": "Speeding", "amount": 250}`))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "civilian does not belong to this community")
+	db.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestCitation_CreateCitationHandlerInvalidBody(t *testing.T) {
+	c := handlers.Citation{DB: &mocks.CitationDatabase{}, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	for _, body := range []string{
+		`{"civilianId": "` + bundleCivilianID + `", "amount": 250}`,
+		`{"civilianId": "` + bundleCivilianID + `", "name": "Speeding", "amount": -1}`,
+		`{"civilianId": "not-an-id", "name": "Speeding", "amount": 250}`,
+	} {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(c.CreateCitationHandler).ServeHTTP(rr, createCitationRequest(body))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func TestCitation_CreateCitationHandlerOwner(t *testing.T) {
+	db := &mocks.CitationDatabase{}
+	db.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+	userDB := &mocks.UserDatabase{}
+	c := handlers.Citation{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: userDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CreateCitationHandler).ServeHTTP(rr, createCitationRequestAs(apiKeyOwnerID, `{"civilianId": "`+bundleCivilianID+`", "name": "Speeding"}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	userDB.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+}
+
+func TestCitation_CreateCitationHandlerNotMember(t *testing.T) {
+	db := &mocks.CitationDatabase{}
+	civilianDB := citationCivilianDB(apiKeyCommunityID)
+	c := handlers.Citation{DB: db, CivilianDB: civilianDB, CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	for _, userID := range []string{"608cafe595eb9dc05379bbbb", "not-an-object-id"} {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(c.CreateCitationHandler).ServeHTTP(rr, createCitationRequestAs(userID, `{"civilianId": "`+bundleCivilianID+`", "name": "Speeding"}`))
+
+		assert.Equal(t, http.StatusForbidden, rr.Code, userID)
+	}
+	civilianDB.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+	db.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestCitation_CreateCitationHandlerNoUser(t *testing.T) {
+	db := &mocks.CitationDatabase{}
+	c := handlers.Citation{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CreateCitationHandler).ServeHTTP(rr, createCitationRequestAs("", `{"civilianId": "`+bundleCivilianID+`", "name": "Speeding"}`))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "X-User-ID")
+	db.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestCitation_CivilianCitationsHandler(t *testing.T) {
+	db := &mocks.CitationDatabase{}
+	db.On("Find", mock.Anything, bson.M{"communityId": apiKeyCommunityID, "civilianId": bundleCivilianID}, mock.Anything).Return([]models.Citation{{Name: "Speeding"}}, nil)
+	c := handlers.Citation{DB: db}
+
+	req, _ := http.NewRequest("GET", "/api/v1/community/"+apiKeyCommunityID+"/civilian/"+bundleCivilianID+"/citations?page=1&limit=5", nil)
+	req = mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID, "civilian_id": bundleCivilianID})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CivilianCitationsHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Speeding")
+	opts := db.Calls[0].Arguments.Get(2).(*options.FindOptions)
+	assert.Equal(t, int64(5), *opts.Limit)
+	assert.Equal(t, int64(5), *opts.Skip)
+	assert.Equal(t, bson.D{{Key: "issuedAt", Value: -1}, {Key: "_id", Value: -1}}, opts.Sort)
+}
+
+func TestCitation_CivilianCitationsHandlerCancelledRequest(t *testing.T) {
+	var queryCtx context.Context
+	db := &mocks.CitationDatabase{}
+	db.On("Find", mock.Anything, mock.Anything, mock.Anything).Return(nil, context.Canceled).Run(func(args mock.Arguments) {
+		queryCtx = args.Get(0).(context.Context)
+	})
+	c := handlers.Citation{DB: db}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/api/v1/community/"+apiKeyCommunityID+"/civilian/"+bundleCivilianID+"/citations", nil)
+	req = mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID, "civilian_id": bundleCivilianID})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CivilianCitationsHandler).ServeHTTP(rr, req)
+
+	assert.ErrorIs(t, queryCtx.Err(), context.Canceled)
+	_, hasDeadline := queryCtx.Deadline()
+	assert.True(t, hasDeadline)
+}
+
+func updateCitationStatusRequest(userID, status string) *http.Request {
+	req, _ := http.NewRequest("PATCH", "/api/v1/citations/"+citationID+"/status", strings.NewReader(`{"status": "`+status+`"}`))
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	return mux.SetURLVars(req, map[string]string{"citation_id": citationID})
+}
+
+func citationDBWithCitation() *mocks.CitationDatabase {
+	db := &mocks.CitationDatabase{}
+	cID, _ := primitive.ObjectIDFromHex(citationID)
+	db.On("FindOne", mock.Anything, bson.M{"_id": cID}).Return(&models.Citation{
+		ID:             cID,
+		CommunityID:    apiKeyCommunityID,
+		IssuedByUserID: citationOfficer,
+		Status:         models.CitationUnpaid,
+	}, nil)
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	return db
+}
+
+func TestCitation_UpdateCitationStatusHandler(t *testing.T) {
+	db := citationDBWithCitation()
+	c := handlers.Citation{DB: db, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.UpdateCitationStatusHandler).ServeHTTP(rr, updateCitationStatusRequest(citationOfficer, "paid"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.Citation
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, models.CitationPaid, got.Status)
+	assert.NotZero(t, got.ResolvedAt)
+	update := db.Calls[1].Arguments.Get(2).(bson.M)
+	assert.Equal(t, models.CitationPaid, update["$set"].(bson.M)["status"])
+}
+
+func TestCitation_UpdateCitationStatusHandlerCommunityOwner(t *testing.T) {
+	c := handlers.Citation{DB: citationDBWithCitation(), CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.UpdateCitationStatusHandler).ServeHTTP(rr, updateCitationStatusRequest(apiKeyOwnerID, "dismissed"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestCitation_UpdateCitationStatusHandlerForbidden(t *testing.T) {
+	db := citationDBWithCitation()
+	c := handlers.Citation{DB: db, CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.UpdateCitationStatusHandler).ServeHTTP(rr, updateCitationStatusRequest("someone-else", "dismissed"))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCitation_UpdateCitationStatusHandlerInvalidStatus(t *testing.T) {
+	c := handlers.Citation{DB: citationDBWithCitation()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.UpdateCitationStatusHandler).ServeHTTP(rr, updateCitationStatusRequest(citationOfficer, "refunded"))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
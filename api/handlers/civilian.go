@@ -25,10 +25,13 @@ var (
 
 // Civilian exported for testing purposes
 type Civilian struct {
-	DB databases.CivilianDatabase
+	DB        databases.CivilianDatabase
+	VehicleDB databases.VehicleDatabase
+	LicenseDB databases.LicenseDatabase
+	WarrantDB databases.WarrantDatabase
 }
 
-// CivilianHandler returns all civilians
+// CivilianHandler returns all civilians that have not been erased
 func (c Civilian) CivilianHandler(w http.ResponseWriter, r *http.Request) {
 	Limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
 	if err != nil {
@@ -37,7 +40,7 @@ func (c Civilian) CivilianHandler(w http.ResponseWriter, r *http.Request) {
 	limit64 := int64(Limit)
 	Page = getPage(Page, r)
 	skip64 := int64(Page * Limit)
-	dbResp, err := c.DB.Find(context.TODO(), bson.D{}, &options.FindOptions{Limit: &limit64, Skip: &skip64})
+	dbResp, err := c.DB.Find(context.TODO(), bson.M{"civilian.deletedAt": bson.M{"$exists": false}}, &options.FindOptions{Limit: &limit64, Skip: &skip64})
 	if err != nil {
 		config.ErrorStatus("failed to get civilians", http.StatusNotFound, w, err)
 		return
@@ -56,7 +59,7 @@ func (c Civilian) CivilianHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
-// CivilianByIDHandler returns a civilian by ID
+// CivilianByIDHandler returns a civilian by ID. Erased civilians are not found.
 func (c Civilian) CivilianByIDHandler(w http.ResponseWriter, r *http.Request) {
 	civID := mux.Vars(r)["civilian_id"]
 
@@ -68,7 +71,7 @@ func (c Civilian) CivilianByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dbResp, err := c.DB.FindOne(context.Background(), bson.M{"_id": cID})
+	dbResp, err := c.DB.FindOne(context.Background(), bson.M{"_id": cID, "civilian.deletedAt": bson.M{"$exists": false}})
 	if err != nil {
 		config.ErrorStatus("failed to get civilian by ID", http.StatusNotFound, w, err)
 		return
@@ -111,6 +114,7 @@ func (c Civilian) CiviliansByUserIDHandler(w http.ResponseWriter, r *http.Reques
 		dbResp, err = c.DB.Find(context.TODO(), bson.M{
 			"civilian.userID":            userID,
 			"civilian.activeCommunityID": activeCommunityID,
+			"civilian.deletedAt":         bson.M{"$exists": false},
 		}, &options.FindOptions{Limit: &limit64, Skip: &skip64})
 		if err != nil {
 			config.ErrorStatus("failed to get civilians with active community id", http.StatusNotFound, w, err)
@@ -118,7 +122,8 @@ func (c Civilian) CiviliansByUserIDHandler(w http.ResponseWriter, r *http.Reques
 		}
 	} else {
 		dbResp, err = c.DB.Find(context.TODO(), bson.M{
-			"civilian.userID": userID,
+			"civilian.userID":    userID,
+			"civilian.deletedAt": bson.M{"$exists": false},
 			"$or": []bson.M{
 				{"civilian.activeCommunityID": nil},
 				{"civilian.activeCommunityID": ""},
@@ -174,6 +179,7 @@ func (c Civilian) CiviliansByNameSearchHandler(w http.ResponseWriter, r *http.Re
 			"$search": fmt.Sprintf("%s %s", firstName, lastName),
 		},
 		"civilian.activeCommunityID": activeCommunityID,
+		"civilian.deletedAt":         bson.M{"$exists": false},
 	}, &options.FindOptions{Limit: &limit64, Skip: &skip64})
 	if err != nil {
 		config.ErrorStatus("failed to get civilian name search", http.StatusNotFound, w, err)
@@ -210,3 +216,16 @@ func getPage(Page int, r *http.Request) int {
 	}
 	return Page
 }
+
+// getLimit returns the limit query param, falling back to def when it is missing or
+// invalid and capping it at max
+func getLimit(r *http.Request, def, max int) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/linesmerrill/police-cad-api/api/handlers"
 	"github.com/linesmerrill/police-cad-api/databases"
@@ -1255,3 +1257,48 @@ func TestCivilian_CiviliansByNameSearchHandlerEmptyResponse(t *testing.T) {
 		t.Errorf("handler returned unexpected body: \ngot: %v \nwant: %v", rr.Body.String(), expected)
 	}
 }
+
+func TestCivilian_HandlersSkipErasedCivilians(t *testing.T) {
+	notErased := bson.M{"$exists": false}
+	tests := []struct {
+		name    string
+		handler func(c handlers.Civilian) http.HandlerFunc
+		vars    map[string]string
+		query   string
+	}{
+		{"all", func(c handlers.Civilian) http.HandlerFunc { return c.CivilianHandler }, nil, ""},
+		{"by user", func(c handlers.Civilian) http.HandlerFunc { return c.CiviliansByUserIDHandler }, map[string]string{"user_id": "608cafd695eb9dc05379b7f3"}, ""},
+		{"by user in community", func(c handlers.Civilian) http.HandlerFunc { return c.CiviliansByUserIDHandler }, map[string]string{"user_id": "608cafd695eb9dc05379b7f3"}, "active_community_id=61be0ebf6a1e8c9b26de2d0e"},
+		{"by name", func(c handlers.Civilian) http.HandlerFunc { return c.CiviliansByNameSearchHandler }, nil, "first_name=John&last_name=Doe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mocks.CivilianDatabase{}
+			db.On("Find", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+			req, _ := http.NewRequest("GET", "/api/v1/civilians?"+tt.query, nil)
+			req = mux.SetURLVars(req, tt.vars)
+
+			rr := httptest.NewRecorder()
+			tt.handler(handlers.Civilian{DB: db}).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "[]", rr.Body.String())
+			filter := db.Calls[0].Arguments.Get(1).(bson.M)
+			assert.Equal(t, notErased, filter["civilian.deletedAt"])
+		})
+	}
+}
+
+func TestCivilian_CivilianByIDHandlerErased(t *testing.T) {
+	db := &mocks.CivilianDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+	req, _ := http.NewRequest("GET", "/api/v1/civilian/608cafe595eb9dc05379b7f4", nil)
+	req = mux.SetURLVars(req, map[string]string{"civilian_id": "608cafe595eb9dc05379b7f4"})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Civilian{DB: db}.CivilianByIDHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	filter := db.Calls[0].Arguments.Get(1).(bson.M)
+	assert.Equal(t, bson.M{"$exists": false}, filter["civilian.deletedAt"])
+}
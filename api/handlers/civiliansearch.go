@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+// Fields a community civilian search can match on
+const (
+	civilianSearchName    = "name"
+	civilianSearchLicense = "license"
+	civilianSearchPlate   = "plate"
+)
+
+const (
+	minCivilianSearchLength    = 2
+	defaultCivilianSearchLimit = 20
+	maxCivilianSearchLimit     = 100
+)
+
+// CommunityCivilianSearchHandler searches the civilians of a community. field=name (the default)
+// matches the start of the first and last name, field=license matches a license by its ID and
+// field=plate matches the plate of a registered vehicle, both returning the owning civilian.
+func (c Civilian) CommunityCivilianSearchHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		field = civilianSearchName
+	}
+	p := parsePageLimit(r, pageDefaults{Limit: defaultCivilianSearchLimit, MaxLimit: maxCivilianSearchLimit})
+
+	zap.S().Debugf("community_id: %v, q: '%v', field: %v", commID, q, field)
+
+	if len(q) < minCivilianSearchLength {
+		config.ErrorStatus("search query is too short", http.StatusBadRequest, w, fmt.Errorf("q must be at least %d characters", minCivilianSearchLength))
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	filter := bson.M{
+		"civilian.activeCommunityID": commID,
+		"civilian.deletedAt":         bson.M{"$exists": false},
+	}
+	switch field {
+	case civilianSearchName:
+		terms := strings.Fields(q)
+		if len(terms) == 1 {
+			filter["$or"] = []bson.M{
+				{"civilian.firstName": prefixRegex(terms[0])},
+				{"civilian.lastName": prefixRegex(terms[0])},
+			}
+		} else {
+			filter["civilian.firstName"] = prefixRegex(terms[0])
+			filter["civilian.lastName"] = prefixRegex(strings.Join(terms[1:], " "))
+		}
+	case civilianSearchLicense, civilianSearchPlate:
+		ownerIDs, err := c.searchOwnerIDs(ctx, commID, field, q)
+		if err != nil {
+			config.ErrorStatus("failed to search civilians", http.StatusInternalServerError, w, err)
+			return
+		}
+		if len(ownerIDs) == 0 {
+			// nothing can match, no need to ask mongo
+			respondJSON(w, http.StatusOK, models.CivilianSearchResponse{Civilians: []models.CivilianSearchResult{}, Pagination: buildMeta(p, 0)})
+			return
+		}
+		filter["_id"] = bson.M{"$in": ownerIDs}
+	default:
+		config.ErrorStatus("invalid search field", http.StatusBadRequest, w, fmt.Errorf("field must be one of %s, %s or %s, got '%s'", civilianSearchName, civilianSearchLicense, civilianSearchPlate, field))
+		return
+	}
+
+	total, err := c.DB.CountDocuments(ctx, filter)
+	if err != nil {
+		config.ErrorStatus("failed to search civilians", http.StatusInternalServerError, w, err)
+		return
+	}
+	opts := p.findOptions().
+		SetSort(bson.D{{Key: "civilian.lastName", Value: 1}, {Key: "civilian.firstName", Value: 1}, {Key: "_id", Value: 1}}).
+		SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	dbResp, err := c.DB.Find(ctx, filter, opts)
+	if err != nil {
+		config.ErrorStatus("failed to search civilians", http.StatusInternalServerError, w, err)
+		return
+	}
+	wanted, err := activeWarrants(ctx, c.WarrantDB, dbResp)
+	if err != nil {
+		config.ErrorStatus("failed to get civilian warrants", http.StatusInternalServerError, w, err)
+		return
+	}
+	results := make([]models.CivilianSearchResult, 0, len(dbResp))
+	for _, civilian := range dbResp {
+		results = append(results, models.CivilianSearchResult{Civilian: civilian, HasActiveWarrant: wanted[civilian.ID]})
+	}
+	respondJSON(w, http.StatusOK, models.CivilianSearchResponse{Civilians: results, TotalCount: total, Pagination: buildMeta(p, total)})
+}
+
+// searchOwnerIDs finds the civilians owning the licenses or vehicles in the community that match q
+func (c Civilian) searchOwnerIDs(ctx context.Context, commID, field, q string) ([]primitive.ObjectID, error) {
+	var owners []string
+	if field == civilianSearchLicense {
+		lID, err := primitive.ObjectIDFromHex(q)
+		if err != nil {
+			// not a license ID, so it can not match any license
+			return []primitive.ObjectID{}, nil
+		}
+		licenses, err := c.LicenseDB.Find(ctx, bson.M{"_id": lID, "license.activeCommunityID": commID})
+		if err != nil {
+			return nil, err
+		}
+		for _, license := range licenses {
+			owners = append(owners, license.Details.OwnerID)
+		}
+	} else {
+		vehicles, err := c.VehicleDB.Find(ctx, bson.M{
+			"vehicle.plate":             exactRegex(q),
+			"vehicle.activeCommunityID": commID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, vehicle := range vehicles {
+			owners = append(owners, vehicle.Details.RegisteredOwnerID)
+		}
+	}
+	ids := make([]primitive.ObjectID, 0, len(owners))
+	for _, owner := range owners {
+		if oID, err := primitive.ObjectIDFromHex(owner); err == nil {
+			ids = append(ids, oID)
+		}
+	}
+	return ids, nil
+}
+
+// prefixRegex matches values starting with s, case insensitively. s is escaped so it is
+// always matched literally.
+func prefixRegex(s string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(s), Options: "i"}
+}
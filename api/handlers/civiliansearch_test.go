@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func civilianSearchRequest(query url.Values) *http.Request {
+	req, _ := http.NewRequest("GET", "/api/v1/community/"+apiKeyCommunityID+"/civilians/search?"+query.Encode(), nil)
+	return mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID})
+}
+
+func civilianSearchDB() *mocks.CivilianDatabase {
+	db := &mocks.CivilianDatabase{}
+	db.On("CountDocuments", mock.Anything, mock.Anything).Return(int64(42), nil)
+	db.On("Find", mock.Anything, mock.Anything, mock.Anything).Return([]models.Civilian{{ID: bundleCivilianID, Details: models.CivilianDetails{FirstName: "John", LastName: "Doe"}}}, nil)
+	return db
+}
+
+func TestCivilian_CommunityCivilianSearchHandlerName(t *testing.T) {
+	db := civilianSearchDB()
+	c := handlers.Civilian{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityCivilianSearchHandler).ServeHTTP(rr, civilianSearchRequest(url.Values{"q": {"jo d(e"}, "page": {"1"}, "limit": {"10"}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.CivilianSearchResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, int64(42), got.TotalCount)
+	assert.Equal(t, models.Pagination{Page: 1, Limit: 10, TotalCount: 42, HasMore: true}, got.Pagination)
+	assert.Len(t, got.Civilians, 1)
+
+	filter := db.Calls[0].Arguments.Get(1).(bson.M)
+	assert.Equal(t, apiKeyCommunityID, filter["civilian.activeCommunityID"])
+	assert.Equal(t, bson.M{"$exists": false}, filter["civilian.deletedAt"])
+	assert.Equal(t, primitive.Regex{Pattern: `^jo`, Options: "i"}, filter["civilian.firstName"])
+	assert.Equal(t, primitive.Regex{Pattern: `^d\(e`, Options: "i"}, filter["civilian.lastName"])
+	opts := db.Calls[1].Arguments.Get(2).(*options.FindOptions)
+	assert.Equal(t, int64(10), *opts.Limit)
+	assert.Equal(t, int64(10), *opts.Skip)
+}
+
+func TestCivilian_CommunityCivilianSearchHandlerSingleName(t *testing.T) {
+	db := civilianSearchDB()
+	c := handlers.Civilian{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityCivilianSearchHandler).ServeHTTP(rr, civilianSearchRequest(url.Values{"q": {"doe"}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	filter := db.Calls[0].Arguments.Get(1).(bson.M)
+	assert.Equal(t, []bson.M{
+		{"civilian.firstName": primitive.Regex{Pattern: "^doe", Options: "i"}},
+		{"civilian.lastName": primitive.Regex{Pattern: "^doe", Options: "i"}},
+	}, filter["$or"])
+}
+
+func TestCivilian_CommunityCivilianSearchHandlerPlate(t *testing.T) {
+	db := civilianSearchDB()
+	vehicleDB := &mocks.VehicleDatabase{}
+	vehicleDB.On("Find", mock.Anything, bson.M{
+		"vehicle.plate":             primitive.Regex{Pattern: "^ABC123$", Options: "i"},
+		"vehicle.activeCommunityID": apiKeyCommunityID,
+	}).Return([]models.Vehicle{{Details: models.VehicleDetails{RegisteredOwnerID: bundleCivilianID}}}, nil)
+	c := handlers.Civilian{DB: db, VehicleDB: vehicleDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityCivilianSearchHandler).ServeHTTP(rr, civilianSearchRequest(url.Values{"q": {"ABC123"}, "field": {"plate"}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	cID, _ := primitive.ObjectIDFromHex(bundleCivilianID)
+	filter := db.Calls[0].Arguments.Get(1).(bson.M)
+	assert.Equal(t, bson.M{"$in": []primitive.ObjectID{cID}}, filter["_id"])
+}
+
+func TestCivilian_CommunityCivilianSearchHandlerLicense(t *testing.T) {
+	db := civilianSearchDB()
+	licenseID := "608cafe595eb9dc05379bbbb"
+	lID, _ := primitive.ObjectIDFromHex(licenseID)
+	licenseDB := &mocks.LicenseDatabase{}
+	licenseDB.On("Find", mock.Anything, bson.M{"_id": lID, "license.activeCommunityID": apiKeyCommunityID}).Return([]models.License{{Details: models.LicenseDetails{OwnerID: bundleCivilianID}}}, nil)
+	c := handlers.Civilian{DB: db, LicenseDB: licenseDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityCivilianSearchHandler).ServeHTTP(rr, civilianSearchRequest(url.Values{"q": {licenseID}, "field": {"license"}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"totalCount":42`)
+}
+
+func TestCivilian_CommunityCivilianSearchHandlerNoOwners(t *testing.T) {
+	db := &mocks.CivilianDatabase{}
+	c := handlers.Civilian{DB: db, LicenseDB: &mocks.LicenseDatabase{}}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityCivilianSearchHandler).ServeHTTP(rr, civilianSearchRequest(url.Values{"q": {"not-a-license"}, "field": {"license"}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"civilians":[],"totalCount":0,"pagination":{"page":0,"limit":20,"totalCount":0,"hasMore":false}}`, rr.Body.String())
+	db.AssertNotCalled(t, "Find", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCivilian_CommunityCivilianSearchHandlerInvalid(t *testing.T) {
+	c := handlers.Civilian{DB: &mocks.CivilianDatabase{}}
+
+	for _, query := range []url.Values{
+		{"q": {"j"}},
+		{"q": {"john"}, "field": {"ssn"}},
+	} {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(c.CommunityCivilianSearchHandler).ServeHTTP(rr, civilianSearchRequest(query))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query.Encode())
+	}
+}
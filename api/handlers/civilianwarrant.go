@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	defaultCivilianWarrantLimit = 20
+	maxCivilianWarrantLimit     = 100
+)
+
+// validWarrantStatuses are the statuses a warrant can be moved to
+var validWarrantStatuses = map[string]bool{
+	models.WarrantActive:   true,
+	models.WarrantServed:   true,
+	models.WarrantRecalled: true,
+}
+
+// CivilianWarrant exported for testing purposes
+type CivilianWarrant struct {
+	DB          databases.WarrantDatabase
+	CivilianDB  databases.CivilianDatabase
+	CommunityDB databases.CommunityDatabase
+	UserDB      databases.UserDatabase
+}
+
+// civilianWarrantRequest is the body accepted by CreateCivilianWarrantHandler
+type civilianWarrantRequest struct {
+	Reasons []string `json:"reasons"`
+}
+
+// warrantStatusRequest is the body accepted by UpdateCivilianWarrantStatusHandler
+type warrantStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// findCivilian loads a civilian that has not been erased and checks it belongs to the community
+func (cw CivilianWarrant) findCivilian(ctx context.Context, commID, civID string) (*models.Civilian, int, error) {
+	cID, err := primitive.ObjectIDFromHex(civID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	civilian, err := cw.CivilianDB.FindOne(ctx, bson.M{"_id": cID})
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	if civilian.Details.DeletedAt != nil {
+		return nil, http.StatusNotFound, errors.New("civilian has been deleted")
+	}
+	if civilian.Details.ActiveCommunityID != commID {
+		return nil, http.StatusBadRequest, errors.New("civilian does not belong to this community")
+	}
+	return civilian, http.StatusOK, nil
+}
+
+// CreateCivilianWarrantHandler issues an active warrant against a civilian of the community.
+// Only the community owner and its members may issue warrants.
+func (cw CivilianWarrant) CreateCivilianWarrantHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	civID := mux.Vars(r)["civilian_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, civilian_id: %v, user_id: %v", commID, civID, userID)
+
+	if userID == "" {
+		config.ErrorStatus("a signed in user is required to issue a warrant", http.StatusUnauthorized, w, errors.New("token does not identify a user"))
+		return
+	}
+	var req civilianWarrantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		config.ErrorStatus("failed to decode request body", http.StatusBadRequest, w, err)
+		return
+	}
+	reasons := make([]string, 0, len(req.Reasons))
+	for _, reason := range req.Reasons {
+		if reason = strings.TrimSpace(reason); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	if len(reasons) == 0 {
+		config.ErrorStatus("at least one reason is required", http.StatusBadRequest, w, errors.New("missing reasons"))
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	if _, status, err := findMemberCommunity(ctx, cw.CommunityDB, cw.UserDB, commID, userID); err != nil {
+		config.ErrorStatus("failed to get community of user", status, w, err)
+		return
+	}
+	civilian, status, err := cw.findCivilian(ctx, commID, civID)
+	if err != nil {
+		config.ErrorStatus("failed to get civilian by ID", status, w, err)
+		return
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	id := primitive.NewObjectID()
+	warrant := models.Warrant{
+		ID: id.Hex(),
+		Details: models.WarrantDetails{
+			Status:             true,
+			AccusedID:          civilian.ID,
+			AccusedFirstName:   civilian.Details.FirstName,
+			AccusedLastName:    civilian.Details.LastName,
+			Reasons:            reasons,
+			ReportingOfficerID: userID,
+			ActiveCommunityID:  commID,
+			CreatedAt:          now,
+			UpdatedAt:          now,
+		},
+	}
+	// the legacy app stores warrant ids as ObjectIDs, so the document is written with one
+	// instead of the hex string the model holds
+	if _, err := cw.DB.InsertOne(ctx, bson.M{"_id": id, "warrant": warrant.Details, "__v": 0}); err != nil {
+		config.ErrorStatus("failed to create warrant", http.StatusInternalServerError, w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, warrant)
+}
+
+// CivilianWarrantsHandler returns the warrants of a civilian of the community, newest first
+func (cw CivilianWarrant) CivilianWarrantsHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	civID := mux.Vars(r)["civilian_id"]
+	p := parsePageLimit(r, pageDefaults{Limit: defaultCivilianWarrantLimit, MaxLimit: maxCivilianWarrantLimit})
+
+	zap.S().Debugf("community_id: %v, civilian_id: %v", commID, civID)
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	civilian, status, err := cw.findCivilian(ctx, commID, civID)
+	if err != nil {
+		config.ErrorStatus("failed to get civilian by ID", status, w, err)
+		return
+	}
+
+	opts := p.findOptions().
+		SetSort(bson.D{{Key: "warrant.createdAt", Value: -1}, {Key: "_id", Value: -1}})
+	dbResp, err := cw.DB.Find(ctx, bson.M{"warrant.accusedID": linkedIDs(civilian)}, opts)
+	if err != nil {
+		config.ErrorStatus("failed to get warrants", http.StatusNotFound, w, err)
+		return
+	}
+	// Because the frontend requires that the data elements inside models.Warrant exist, if
+	// len == 0 then we will just return an empty data object
+	if len(dbResp) == 0 {
+		dbResp = []models.Warrant{}
+	}
+	respondJSON(w, http.StatusOK, dbResp)
+}
+
+// UpdateCivilianWarrantStatusHandler marks a warrant as active, served or recalled. Only the
+// officer who issued it and the community owner can change it.
+func (cw CivilianWarrant) UpdateCivilianWarrantStatusHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	civID := mux.Vars(r)["civilian_id"]
+	warrantID := mux.Vars(r)["warrant_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, civilian_id: %v, warrant_id: %v, user_id: %v", commID, civID, warrantID, userID)
+
+	wID, err := primitive.ObjectIDFromHex(warrantID)
+	if err != nil {
+		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
+		return
+	}
+	var req warrantStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		config.ErrorStatus("failed to decode request body", http.StatusBadRequest, w, err)
+		return
+	}
+	if !validWarrantStatuses[req.Status] {
+		config.ErrorStatus("invalid status", http.StatusBadRequest, w, fmt.Errorf("unknown status '%s'", req.Status))
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	civilian, status, err := cw.findCivilian(ctx, commID, civID)
+	if err != nil {
+		config.ErrorStatus("failed to get civilian by ID", status, w, err)
+		return
+	}
+	filter := bson.M{"_id": wID, "warrant.accusedID": linkedIDs(civilian)}
+	warrant, err := cw.DB.FindOne(ctx, filter)
+	if err != nil {
+		config.ErrorStatus("failed to get warrant by ID", http.StatusNotFound, w, err)
+		return
+	}
+	if userID == "" || warrant.Details.ReportingOfficerID != userID {
+		if _, status, err := findOwnedCommunity(ctx, cw.CommunityDB, commID, userID); err != nil {
+			config.ErrorStatus("user cannot update this warrant", status, w, err)
+			return
+		}
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	set := bson.M{"warrant.updatedAt": now}
+	update := bson.M{"$set": set}
+	warrant.Details.UpdatedAt = now
+	if req.Status == models.WarrantActive {
+		set["warrant.status"] = true
+		update["$unset"] = bson.M{"warrant.resolution": "", "warrant.clearingOfficerID": ""}
+		warrant.Details.Status = true
+		warrant.Details.Resolution = ""
+		warrant.Details.ClearingOfficerID = ""
+	} else {
+		set["warrant.status"] = false
+		set["warrant.resolution"] = req.Status
+		set["warrant.clearingOfficerID"] = userID
+		warrant.Details.Status = false
+		warrant.Details.Resolution = req.Status
+		warrant.Details.ClearingOfficerID = userID
+	}
+	if _, err := cw.DB.UpdateOne(ctx, filter, update); err != nil {
+		config.ErrorStatus("failed to update warrant", http.StatusInternalServerError, w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, warrant)
+}
+
+// activeWarrants reports which of the civilians have at least one active warrant, with a
+// single query. Served and recalled warrants are not active. A nil db reports none.
+func activeWarrants(ctx context.Context, db databases.WarrantDatabase, civilians []models.Civilian) (map[string]bool, error) {
+	wanted := map[string]bool{}
+	if db == nil || len(civilians) == 0 {
+		return wanted, nil
+	}
+	ids := []interface{}{}
+	for i := range civilians {
+		ids = append(ids, civilianRefs(&civilians[i])...)
+	}
+	warrants, err := db.Find(ctx,
+		bson.M{"warrant.accusedID": bson.M{"$in": ids}, "warrant.status": true},
+		options.Find().SetProjection(bson.M{"warrant.accusedID": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, warrant := range warrants {
+		wanted[warrant.Details.AccusedID] = true
+	}
+	return wanted, nil
+}
@@ -0,0 +1,198 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const civilianWarrantID = "608cafe595eb9dc05379dddd"
+
+func civilianWarrantRequest(method, userID, body string, vars map[string]string) *http.Request {
+	req, _ := http.NewRequest(method, "/api/v1/community/"+apiKeyCommunityID+"/civilians/"+bundleCivilianID+"/warrants", strings.NewReader(body))
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	vars["community_id"] = apiKeyCommunityID
+	vars["civilian_id"] = bundleCivilianID
+	return mux.SetURLVars(req, vars)
+}
+
+func TestCivilianWarrant_CreateCivilianWarrantHandler(t *testing.T) {
+	db := &mocks.WarrantDatabase{}
+	db.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+	cw := handlers.CivilianWarrant{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(cw.CreateCivilianWarrantHandler).ServeHTTP(rr, civilianWarrantRequest("POST", citationOfficer, `{"reasons": ["Failure to appear", " "]}`, map[string]string{}))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var got models.Warrant
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.True(t, got.Details.Status)
+	assert.Equal(t, bundleCivilianID, got.Details.AccusedID)
+	assert.Equal(t, citationOfficer, got.Details.ReportingOfficerID)
+	assert.Equal(t, []string{"Failure to appear"}, got.Details.Reasons)
+
+	doc := db.Calls[0].Arguments.Get(1).(bson.M)
+	assert.IsType(t, primitive.ObjectID{}, doc["_id"])
+	assert.Equal(t, got.ID, doc["_id"].(primitive.ObjectID).Hex())
+}
+
+func TestCivilianWarrant_CreateCivilianWarrantHandlerWrongCommunity(t *testing.T) {
+	db := &mocks.WarrantDatabase{}
+	cw := handlers.CivilianWarrant{DB: db, CivilianDB: citationCivilianDB("608cafe595eb9dc05379ffff"), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(cw.CreateCivilianWarrantHandler).ServeHTTP(rr, civilianWarrantRequest("POST", citationOfficer, `{"reasons": ["Failure to appear"]}`, map[string]string{}))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "civilian does not belong to this community")
+	db.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestCivilianWarrant_CreateCivilianWarrantHandlerNoReasons(t *testing.T) {
+	cw := handlers.CivilianWarrant{DB: &mocks.WarrantDatabase{}, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(cw.CreateCivilianWarrantHandler).ServeHTTP(rr, civilianWarrantRequest("POST", citationOfficer, `{"reasons": []}`, map[string]string{}))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCivilianWarrant_CreateCivilianWarrantHandlerOwner(t *testing.T) {
+	db := &mocks.WarrantDatabase{}
+	db.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+	userDB := &mocks.UserDatabase{}
+	cw := handlers.CivilianWarrant{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: userDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(cw.CreateCivilianWarrantHandler).ServeHTTP(rr, civilianWarrantRequest("POST", apiKeyOwnerID, `{"reasons": ["Failure to appear"]}`, map[string]string{}))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	userDB.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+}
+
+func TestCivilianWarrant_CreateCivilianWarrantHandlerNotMember(t *testing.T) {
+	db := &mocks.WarrantDatabase{}
+	civilianDB := citationCivilianDB(apiKeyCommunityID)
+	cw := handlers.CivilianWarrant{DB: db, CivilianDB: civilianDB, CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	for _, userID := range []string{"608cafe595eb9dc05379bbbb", "not-an-object-id"} {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(cw.CreateCivilianWarrantHandler).ServeHTTP(rr, civilianWarrantRequest("POST", userID, `{"reasons": ["Failure to appear"]}`, map[string]string{}))
+
+		assert.Equal(t, http.StatusForbidden, rr.Code, userID)
+	}
+	civilianDB.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+	db.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestCivilianWarrant_CreateCivilianWarrantHandlerNoUser(t *testing.T) {
+	db := &mocks.WarrantDatabase{}
+	cw := handlers.CivilianWarrant{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(cw.CreateCivilianWarrantHandler).ServeHTTP(rr, civilianWarrantRequest("POST", "", `{"reasons": ["Failure to appear"]}`, map[string]string{}))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "X-User-ID")
+	db.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestCivilianWarrant_CivilianWarrantsHandler(t *testing.T) {
+	db := &mocks.WarrantDatabase{}
+	db.On("Find", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	cw := handlers.CivilianWarrant{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID)}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(cw.CivilianWarrantsHandler).ServeHTTP(rr, civilianWarrantRequest("GET", citationOfficer, "", map[string]string{}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]", rr.Body.String())
+	cID, _ := primitive.ObjectIDFromHex(bundleCivilianID)
+	assert.Equal(t, bson.M{"warrant.accusedID": bson.M{"$in": []interface{}{bundleCivilianID, cID}}}, db.Calls[0].Arguments.Get(1))
+}
+
+func civilianWarrantDB() *mocks.WarrantDatabase {
+	db := &mocks.WarrantDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(&models.Warrant{
+		ID:      civilianWarrantID,
+		Details: models.WarrantDetails{Status: true, AccusedID: bundleCivilianID, ReportingOfficerID: citationOfficer},
+	}, nil)
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	return db
+}
+
+func TestCivilianWarrant_UpdateCivilianWarrantStatusHandlerRecalled(t *testing.T) {
+	db := civilianWarrantDB()
+	cw := handlers.CivilianWarrant{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(cw.UpdateCivilianWarrantStatusHandler).ServeHTTP(rr, civilianWarrantRequest("PATCH", apiKeyOwnerID, `{"status": "recalled"}`, map[string]string{"warrant_id": civilianWarrantID}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.Warrant
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.False(t, got.Details.Status)
+	assert.Equal(t, models.WarrantRecalled, got.Details.Resolution)
+	assert.Equal(t, apiKeyOwnerID, got.Details.ClearingOfficerID)
+	set := db.Calls[1].Arguments.Get(2).(bson.M)["$set"].(bson.M)
+	assert.Equal(t, false, set["warrant.status"])
+	assert.Equal(t, models.WarrantRecalled, set["warrant.resolution"])
+}
+
+func TestCivilianWarrant_UpdateCivilianWarrantStatusHandlerForbidden(t *testing.T) {
+	db := civilianWarrantDB()
+	cw := handlers.CivilianWarrant{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(cw.UpdateCivilianWarrantStatusHandler).ServeHTTP(rr, civilianWarrantRequest("PATCH", "someone-else", `{"status": "served"}`, map[string]string{"warrant_id": civilianWarrantID}))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCivilianWarrant_UpdateCivilianWarrantStatusHandlerInvalidStatus(t *testing.T) {
+	cw := handlers.CivilianWarrant{DB: civilianWarrantDB(), CivilianDB: citationCivilianDB(apiKeyCommunityID)}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(cw.UpdateCivilianWarrantStatusHandler).ServeHTTP(rr, civilianWarrantRequest("PATCH", citationOfficer, `{"status": "pending"}`, map[string]string{"warrant_id": civilianWarrantID}))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCivilian_CommunityCivilianSearchHandlerActiveWarrant(t *testing.T) {
+	db := &mocks.CivilianDatabase{}
+	db.On("CountDocuments", mock.Anything, mock.Anything).Return(int64(2), nil)
+	db.On("Find", mock.Anything, mock.Anything, mock.Anything).Return([]models.Civilian{{ID: bundleCivilianID}, {ID: "608cafe595eb9dc05379b7f9"}}, nil)
+	warrantDB := &mocks.WarrantDatabase{}
+	warrantDB.On("Find", mock.Anything, mock.Anything, mock.Anything).Return([]models.Warrant{{Details: models.WarrantDetails{AccusedID: bundleCivilianID, Status: true}}}, nil)
+	c := handlers.Civilian{DB: db, WarrantDB: warrantDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityCivilianSearchHandler).ServeHTTP(rr, civilianSearchRequest(url.Values{"q": {"doe"}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.CivilianSearchResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.True(t, got.Civilians[0].HasActiveWarrant)
+	assert.False(t, got.Civilians[1].HasActiveWarrant)
+	// only active warrants count, served and recalled ones have status false
+	filter := warrantDB.Calls[0].Arguments.Get(1).(bson.M)
+	assert.Equal(t, true, filter["warrant.status"])
+	warrantDB.AssertNumberOfCalls(t, "Find", 1)
+}
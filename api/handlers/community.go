@@ -1,37 +1,46 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 
+	"github.com/linesmerrill/police-cad-api/api"
 	"github.com/linesmerrill/police-cad-api/config"
 	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	minCommunitySearchLength    = 2
+	defaultCommunitySearchLimit = 20
+	maxCommunitySearchLimit     = 50
 )
 
 // Community struct mostly used for mocking tests
 type Community struct {
-	DB databases.CommunityDatabase
+	DB         databases.CommunityDatabase
+	AuditLogDB databases.AuditLogDatabase
 }
 
-// CommunityHandler returns a community given a communityID
+// CommunityHandler returns a community given either its communityID or its slug
 func (c Community) CommunityHandler(w http.ResponseWriter, r *http.Request) {
 	commID := mux.Vars(r)["community_id"]
 
 	zap.S().Debugf("community_id: %v", commID)
 
-	cID, err := primitive.ObjectIDFromHex(commID)
-	if err != nil {
-		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
-		return
-	}
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
 
-	dbResp, err := c.DB.FindOne(context.Background(), bson.M{"_id": cID})
+	dbResp, err := c.resolveCommunity(ctx, commID)
 	if err != nil {
 		config.ErrorStatus("failed to get community by ID", http.StatusNotFound, w, err)
 		return
@@ -42,8 +51,7 @@ func (c Community) CommunityHandler(w http.ResponseWriter, r *http.Request) {
 		config.ErrorStatus("failed to marshal response", http.StatusInternalServerError, w, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write(b)
+	writeWithETag(w, r, b)
 }
 
 // CommunityByCommunityAndOwnerIDHandler returns a community that contains the specified ownerID
@@ -53,12 +61,17 @@ func (c Community) CommunityByCommunityAndOwnerIDHandler(w http.ResponseWriter,
 
 	zap.S().Debugf("community_id: %v, owner_id: %v", commID, ownerID)
 
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
 	cID, err := primitive.ObjectIDFromHex(commID)
 	if err != nil {
 		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
 		return
 	}
-	dbResp, err := c.DB.FindOne(context.Background(), bson.M{"_id": cID, "community.ownerID": ownerID})
+	filter := ownerIDFilter(ownerID)
+	filter["_id"] = cID
+	dbResp, err := c.DB.FindOne(ctx, filter)
 	if err != nil {
 		config.ErrorStatus("failed to get community by ID and ownerID", http.StatusNotFound, w, err)
 		return
@@ -69,8 +82,7 @@ func (c Community) CommunityByCommunityAndOwnerIDHandler(w http.ResponseWriter,
 		config.ErrorStatus("failed to marshal response", http.StatusInternalServerError, w, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write(b)
+	writeWithETag(w, r, b)
 }
 
 // CommunitiesByOwnerIDHandler returns all communities that contain the specified ownerID
@@ -79,17 +91,68 @@ func (c Community) CommunitiesByOwnerIDHandler(w http.ResponseWriter, r *http.Re
 
 	zap.S().Debugf("owner_id: %v", ownerID)
 
-	dbResp, err := c.DB.Find(context.Background(), bson.M{"community.ownerID": ownerID})
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	dbResp, err := c.DB.Find(ctx, ownerIDFilter(ownerID))
 	if err != nil {
 		config.ErrorStatus("failed to get community by ownerID", http.StatusNotFound, w, err)
 		return
 	}
 
-	b, err := json.Marshal(dbResp)
+	respondJSON(w, http.StatusOK, dbResp)
+}
+
+// CommunitySearchHandler searches communities by name, case insensitively, in alphabetical order
+func (c Community) CommunitySearchHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	p := parsePageLimit(r, pageDefaults{Limit: defaultCommunitySearchLimit, MaxLimit: maxCommunitySearchLimit})
+
+	zap.S().Debugf("q: '%v'", q)
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	if len(q) < minCommunitySearchLength {
+		config.ErrorStatus("search query is too short", http.StatusBadRequest, w, fmt.Errorf("q must be at least %d characters", minCommunitySearchLength))
+		return
+	}
+
+	// the query is escaped so input like "(" is matched literally instead of breaking the regex
+	filter := bson.M{"community.name": primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}}
+	opts := p.findOptions().
+		SetProjection(bson.M{"community.name": 1, "community.slug": 1}).
+		SetSort(bson.D{{Key: "community.name", Value: 1}, {Key: "_id", Value: 1}}).
+		SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	dbResp, err := c.DB.Find(ctx, filter, opts)
 	if err != nil {
-		config.ErrorStatus("failed to marshal response", http.StatusInternalServerError, w, err)
+		config.ErrorStatus("failed to search communities", http.StatusNotFound, w, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write(b)
+
+	results := make([]models.CommunityLite, 0, len(dbResp))
+	for _, community := range dbResp {
+		results = append(results, models.CommunityLite{
+			ID:   community.ID,
+			Name: community.Details.Name,
+			Slug: community.Details.Slug,
+		})
+	}
+	respondJSON(w, http.StatusOK, results)
+}
+
+// ownerIDFilter matches communities owned by ownerID under either the canonical
+// community.ownerID key or the legacy community.ownerId key
+func ownerIDFilter(ownerID string) bson.M {
+	return dualKeyFilter(ownerID, "community.ownerID", "community.ownerId")
+}
+
+// dualKeyFilter matches documents where any of the given keys equals value. It lets
+// queries read documents written with older spellings of a field until they are migrated.
+func dualKeyFilter(value interface{}, keys ...string) bson.M {
+	or := make([]bson.M, 0, len(keys))
+	for _, key := range keys {
+		or = append(or, bson.M{key: value})
+	}
+	return bson.M{"$or": or}
 }
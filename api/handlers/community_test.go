@@ -1,15 +1,20 @@
 package handlers_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/linesmerrill/police-cad-api/api/handlers"
 	"github.com/linesmerrill/police-cad-api/databases"
@@ -52,11 +57,12 @@ func TestCommunity_CommunityHandlerInvalidCommunityID(t *testing.T) {
 
 	handler.ServeHTTP(rr, req)
 
-	if status := rr.Code; status != http.StatusBadRequest {
-		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	// anything that is not an ObjectID is looked up as a slug
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
 
-	expected := models.ErrorMessageResponse{Response: models.MessageError{Message: "failed to get objectID from Hex", Error: "the provided hex string is not a valid ObjectID"}}
+	expected := models.ErrorMessageResponse{Response: models.MessageError{Message: "failed to get community by ID", Error: "mocked-error"}}
 	b, _ := json.Marshal(expected)
 	if rr.Body.String() != string(b) {
 		t.Errorf("handler returned unexpected body: \ngot: %v \nwant: %v", rr.Body.String(), expected)
@@ -553,6 +559,7 @@ func TestCommunity_CommunitiesByOwnerIDHandlerSuccess(t *testing.T) {
 	_ = json.Unmarshal(rr.Body.Bytes(), &testCommunity)
 
 	assert.Equal(t, "608cafe595eb9dc05379b7f4", testCommunity[0].ID)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
 }
 
 func TestUser_CommunitiesByOwnerIDHandlerEmptyResponse(t *testing.T) {
@@ -602,3 +609,197 @@ func TestUser_CommunitiesByOwnerIDHandlerEmptyResponse(t *testing.T) {
 		t.Errorf("handler returned unexpected body: \ngot: %v \nwant: %v", rr.Body.String(), expected)
 	}
 }
+
+// legacyCommunityDocs returns the same community as stored by the legacy Node
+// writer and as stored after the ownerId migration
+func legacyCommunityDocs() map[string][]byte {
+	legacy, _ := bson.Marshal(bson.M{
+		"_id":       "608cafe595eb9dc05379b7f4",
+		"community": bson.M{"name": "Legacy RP", "ownerId": "legacy-owner"},
+	})
+	migrated, _ := bson.Marshal(bson.M{
+		"_id":       "608cafe595eb9dc05379b7f4",
+		"community": bson.M{"name": "Legacy RP", "ownerID": "legacy-owner"},
+	})
+	return map[string][]byte{"before migration": legacy, "after migration": migrated}
+}
+
+// matchesOwnerOnBothKeys makes sure the filter reads both spellings of the owner key
+func matchesOwnerOnBothKeys(ownerID string) interface{} {
+	return mock.MatchedBy(func(filter bson.M) bool {
+		or, ok := filter["$or"].([]bson.M)
+		return ok && len(or) == 2 && or[0]["community.ownerID"] == ownerID && or[1]["community.ownerId"] == ownerID
+	})
+}
+
+func TestCommunity_CommunityByCommunityAndOwnerIDHandlerLegacyOwnerID(t *testing.T) {
+	for name, raw := range legacyCommunityDocs() {
+		raw := raw
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/api/v1/community/608cafe595eb9dc05379b7f4/legacy-owner", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, map[string]string{"community_id": "608cafe595eb9dc05379b7f4", "owner_id": "legacy-owner"})
+
+			db := &mocks.DatabaseHelper{}
+			conn := &mocks.CollectionHelper{}
+			singleResultHelper := &mocks.SingleResultHelper{}
+
+			singleResultHelper.On("Decode", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				arg := args.Get(0).(**models.Community)
+				_ = bson.Unmarshal(raw, *arg)
+			})
+			conn.On("FindOne", mock.Anything, matchesOwnerOnBothKeys("legacy-owner")).Return(singleResultHelper)
+			db.On("Collection", "communities").Return(conn)
+
+			c := handlers.Community{DB: databases.NewCommunityDatabase(db)}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(c.CommunityByCommunityAndOwnerIDHandler).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var got models.Community
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+			assert.Equal(t, "legacy-owner", got.Details.OwnerID)
+		})
+	}
+}
+
+func TestCommunity_CommunitiesByOwnerIDHandlerLegacyOwnerID(t *testing.T) {
+	for name, raw := range legacyCommunityDocs() {
+		raw := raw
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/api/v1/communities/legacy-owner", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, map[string]string{"owner_id": "legacy-owner"})
+
+			db := &mocks.DatabaseHelper{}
+			conn := &mocks.CollectionHelper{}
+			cursorHelper := &mocks.CursorHelper{}
+
+			cursorHelper.On("Decode", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				arg := args.Get(0).(*[]models.Community)
+				var community models.Community
+				_ = bson.Unmarshal(raw, &community)
+				*arg = []models.Community{community}
+			})
+			conn.On("Find", mock.Anything, matchesOwnerOnBothKeys("legacy-owner")).Return(cursorHelper)
+			db.On("Collection", "communities").Return(conn)
+
+			c := handlers.Community{DB: databases.NewCommunityDatabase(db)}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(c.CommunitiesByOwnerIDHandler).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var got []models.Community
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+			assert.Len(t, got, 1)
+			assert.Equal(t, "legacy-owner", got[0].Details.OwnerID)
+		})
+	}
+}
+
+func TestCommunity_CommunitySearchHandler(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+	db.On("Find", mock.Anything, mock.Anything, mock.Anything).Return([]models.Community{
+		{ID: "608cafe595eb9dc05379b7f4", Details: models.CommunityDetails{Name: "(LSPD) Roleplay", Slug: "lspd-roleplay", OwnerID: "owner"}},
+	}, nil)
+	c := handlers.Community{DB: db}
+
+	req, _ := http.NewRequest("GET", "/api/v1/communities/search?q=(lspd&page=1&limit=10", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunitySearchHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `[{"_id":"608cafe595eb9dc05379b7f4","name":"(LSPD) Roleplay","slug":"lspd-roleplay"}]`, rr.Body.String())
+
+	// user input is matched literally
+	filter := db.Calls[0].Arguments.Get(1).(bson.M)
+	assert.Equal(t, primitive.Regex{Pattern: `\(lspd`, Options: "i"}, filter["community.name"])
+	opts := db.Calls[0].Arguments.Get(2).(*options.FindOptions)
+	assert.Equal(t, int64(10), *opts.Limit)
+	assert.Equal(t, int64(10), *opts.Skip)
+}
+
+func TestCommunity_CommunitySearchHandlerQueryTooShort(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+	c := handlers.Community{DB: db}
+
+	req, _ := http.NewRequest("GET", "/api/v1/communities/search?q=a", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunitySearchHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	db.AssertNotCalled(t, "Find", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func communityETagRequest(ifNoneMatch string) *http.Request {
+	req, _ := http.NewRequest("GET", "/api/v1/community/"+apiKeyCommunityID, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	return mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID})
+}
+
+func TestCommunity_CommunityHandlerETag(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(&models.Community{ID: apiKeyCommunityID, Details: models.CommunityDetails{Name: "Los Santos"}}, nil)
+	c := handlers.Community{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityHandler).ServeHTTP(rr, communityETagRequest(""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityHandler).ServeHTTP(rr, communityETagRequest(`"other", `+etag))
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+	assert.Empty(t, rr.Body.String())
+
+	// a strong comparison of the same tag still matches, If-None-Match uses weak comparison
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityHandler).ServeHTTP(rr, communityETagRequest(strings.TrimPrefix(etag, "W/")))
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityHandler).ServeHTTP(rr, communityETagRequest(`W/"stale"`))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Los Santos")
+}
+
+func TestCommunity_CommunityHandlerErrorHasNoETag(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(nil, errors.New("mocked-error"))
+	c := handlers.Community{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityHandler).ServeHTTP(rr, communityETagRequest("*"))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Empty(t, rr.Header().Get("ETag"))
+}
+
+func TestCommunity_CommunityHandlerCancelledRequest(t *testing.T) {
+	var queryCtx context.Context
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(nil, context.Canceled).Run(func(args mock.Arguments) {
+		queryCtx = args.Get(0).(context.Context)
+	})
+	c := handlers.Community{DB: db}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/api/v1/community/"+apiKeyCommunityID, nil)
+	req = mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(c.CommunityHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.ErrorIs(t, queryCtx.Err(), context.Canceled)
+	_, hasDeadline := queryCtx.Deadline()
+	assert.True(t, hasDeadline)
+}
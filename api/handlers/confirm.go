@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// confirmTokenLifetime is how long a destructive action can be confirmed after it was requested
+const confirmTokenLifetime = 10 * time.Minute
+
+// errNoConfirmSecret is returned when a confirm token is requested but no secret is configured
+var errNoConfirmSecret = errors.New("no secret configured to sign confirm tokens")
+
+// newConfirmToken returns a token that confirms a destructive action on subject until expiresAt.
+// Tokens are signed with secret so they do not need to be stored. Without a secret anyone
+// could forge one, so none is issued.
+func newConfirmToken(secret, subject string, expiresAt time.Time) (string, error) {
+	if secret == "" {
+		return "", errNoConfirmSecret
+	}
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expires + "." + signConfirmToken(secret, subject, expires), nil
+}
+
+// checkConfirmToken reports whether token was signed with secret for subject and has not
+// expired. Nothing is accepted while secret is empty.
+func checkConfirmToken(secret, token, subject string, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(parts[1]), []byte(signConfirmToken(secret, subject, parts[0])))
+}
+
+func signConfirmToken(secret, subject, expires string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%s:%s", subject, expires)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
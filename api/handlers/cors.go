@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// corsAllowedHeaders are the request headers browsers may send cross-origin
+const corsAllowedHeaders = "Authorization, Content-Type, X-User-ID, Idempotency-Key, If-None-Match"
+
+// corsExposedHeaders are the response headers browser code may read
+const corsExposedHeaders = "ETag, Idempotent-Replayed"
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight response
+const corsMaxAge = "600"
+
+// CORS decides which browser origins may call the api. With no allowed origins it is disabled and
+// no CORS headers are sent, which is how the api behaved before it was configurable.
+type CORS struct {
+	// AllowedOrigins holds exact origins like https://app.linespolice-cad.com, wildcard
+	// subdomains like https://*.linespolice-cad.com or *.linespolice-cad.com, or "*" for any origin
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowCredentials bool
+}
+
+// Enabled reports whether any origin is allowed
+func (c *CORS) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// allowed reports whether origin matches one of the allowed origins
+func (c *CORS) allowed(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	for _, pattern := range c.AllowedOrigins {
+		if pattern == "*" || strings.EqualFold(pattern, origin) {
+			return true
+		}
+		scheme, host := "", pattern
+		if i := strings.Index(pattern, "://"); i >= 0 {
+			scheme, host = pattern[:i], pattern[i+3:]
+		}
+		if !strings.HasPrefix(host, "*.") || (scheme != "" && !strings.EqualFold(scheme, u.Scheme)) {
+			continue
+		}
+		// *.example.com matches a.example.com and a.b.example.com but not example.com itself
+		if strings.HasSuffix(strings.ToLower(u.Host), strings.ToLower(host[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware adds the CORS headers for allowed origins and answers preflight requests itself.
+// Disallowed origins get no CORS headers, so the browser blocks the response, rather than a 403.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if origin == "" || !c.allowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		// a wildcard can not be combined with credentials, so the origin is echoed instead
+		if c.AllowCredentials || !c.allowsAny() {
+			h.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		if c.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+			h.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			h.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORS) allowsAny() bool {
+	for _, pattern := range c.AllowedOrigins {
+		if pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// preflightHandler answers OPTIONS requests the CORS middleware did not, so they get a 204
+// instead of a 405 from routes that only accept other methods
+func preflightHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
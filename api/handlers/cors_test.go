@@ -0,0 +1,90 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+)
+
+var corsNext = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func corsRequest(method, origin string) *http.Request {
+	req, _ := http.NewRequest(method, "/api/v1/community/"+apiKeyCommunityID, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	return req
+}
+
+func newCORS() *handlers.CORS {
+	return &handlers.CORS{
+		AllowedOrigins: []string{"https://www.linespolice-cad.com", "https://*.linespolice-cad.dev"},
+		AllowedMethods: []string{"GET", "POST"},
+	}
+}
+
+func TestCORS_MiddlewareAllowedOrigins(t *testing.T) {
+	for _, origin := range []string{"https://www.linespolice-cad.com", "https://staging.linespolice-cad.dev", "https://a.b.linespolice-cad.dev"} {
+		rr := httptest.NewRecorder()
+		newCORS().Middleware(corsNext).ServeHTTP(rr, corsRequest("GET", origin))
+
+		assert.Equal(t, http.StatusOK, rr.Code, origin)
+		assert.Equal(t, origin, rr.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Credentials"), origin)
+	}
+}
+
+func TestCORS_MiddlewareDisallowedOrigins(t *testing.T) {
+	for _, origin := range []string{"https://evil.com", "http://staging.linespolice-cad.dev", "https://linespolice-cad.dev", "https://www.linespolice-cad.com.evil.com"} {
+		rr := httptest.NewRecorder()
+		newCORS().Middleware(corsNext).ServeHTTP(rr, corsRequest("GET", origin))
+
+		assert.Equal(t, http.StatusOK, rr.Code, origin)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"), origin)
+	}
+}
+
+func TestCORS_MiddlewarePreflight(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	req := corsRequest("OPTIONS", "https://www.linespolice-cad.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	rr := httptest.NewRecorder()
+	newCORS().Middleware(next).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.False(t, called)
+	assert.Equal(t, "https://www.linespolice-cad.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", rr.Header().Get("Access-Control-Allow-Methods"))
+	assert.Contains(t, rr.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+}
+
+func TestCORS_MiddlewareAnyOrigin(t *testing.T) {
+	c := &handlers.CORS{AllowedOrigins: []string{"*"}}
+	rr := httptest.NewRecorder()
+	c.Middleware(corsNext).ServeHTTP(rr, corsRequest("GET", "https://anywhere.com"))
+	assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+
+	// a wildcard is never sent with credentials
+	c.AllowCredentials = true
+	rr = httptest.NewRecorder()
+	c.Middleware(corsNext).ServeHTTP(rr, corsRequest("GET", "https://anywhere.com"))
+	assert.Equal(t, "https://anywhere.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCORS_MiddlewareDisabled(t *testing.T) {
+	rr := httptest.NewRecorder()
+	(&handlers.CORS{}).Middleware(corsNext).ServeHTTP(rr, corsRequest("GET", "https://www.linespolice-cad.com"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rr.Header().Get("Vary"))
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+// Export formats accepted by CommunityExportHandler
+const (
+	exportFormatJSON = "json"
+	exportFormatCSV  = "csv"
+)
+
+// exportFlushEvery is how many members are written between flushes to the client
+const exportFlushEvery = 500
+
+// exportStreamTimeout bounds streaming the members of an export. Writing to the client happens
+// while the cursor is open, so the query timeout would cut large exports and slow readers off
+// after the response has started.
+const exportStreamTimeout = 10 * time.Minute
+
+// CommunityExport exported for testing purposes
+type CommunityExport struct {
+	DB         databases.CommunityDatabase
+	UserDB     databases.UserDatabase
+	AuditLogDB databases.AuditLogDatabase
+}
+
+// exportWriter writes one format of the export. start sends the headers and whatever comes
+// before the members, member is called once per member as it is read, flush sends what has
+// been written so far and finish closes the document.
+type exportWriter interface {
+	start() error
+	member(m models.CommunityExportMember) error
+	flush()
+	finish() error
+}
+
+// CommunityExportHandler streams a community and its member list as a single json document,
+// or as a zip of csv files with one file per entity when format=csv. Only the owner can export.
+// Members are written as they are read from the database and never held in memory together.
+func (e CommunityExport) CommunityExportHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	userID := getUserIDFromRequest(r)
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatJSON
+	}
+
+	zap.S().Debugf("community_id: %v, user_id: %v, format: %v", commID, userID, format)
+
+	if format != exportFormatJSON && format != exportFormatCSV {
+		config.ErrorStatus("invalid export format", http.StatusBadRequest, w, fmt.Errorf("format must be %s or %s, got '%s'", exportFormatJSON, exportFormatCSV, format))
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	community, status, err := findOwnedCommunity(ctx, e.DB, commID, userID)
+	cancel()
+	if err != nil {
+		config.ErrorStatus("failed to get community owned by user", status, w, err)
+		return
+	}
+
+	var ew exportWriter
+	if format == exportFormatCSV {
+		ew = &csvExport{w: w, community: community}
+	} else {
+		ew = &jsonExport{w: w, community: community, exportedAt: primitive.NewDateTimeFromTime(time.Now())}
+	}
+
+	// the response only starts with the first member, so a query that fails outright can still
+	// be answered with an error
+	started, members := false, 0
+	streamCtx, cancelStream := context.WithTimeout(r.Context(), exportStreamTimeout)
+	defer cancelStream()
+	err = e.UserDB.Each(streamCtx, bson.M{"user.activeCommunity": commID}, func(user models.User) error {
+		if !started {
+			started = true
+			if err := ew.start(); err != nil {
+				return err
+			}
+		}
+		members++
+		if err := ew.member(models.CommunityExportMember{
+			ID:       user.ID,
+			Username: user.Details.Username,
+			CallSign: user.Details.CallSign,
+		}); err != nil {
+			return err
+		}
+		if members%exportFlushEvery == 0 {
+			ew.flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		started = true
+		err = ew.start()
+	}
+	if err == nil {
+		err = ew.finish()
+	}
+	if err != nil {
+		if !started {
+			config.ErrorStatus("failed to get community members", http.StatusInternalServerError, w, err)
+			return
+		}
+		// the response is already being streamed, so errors can only be logged
+		zap.S().With(err).Errorw("failed to stream community export", "community_id", commID, "format", format)
+		return
+	}
+	recordAudit(e.AuditLogDB, models.AuditLogEntry{
+		CommunityID: commID,
+		ActorUserID: userID,
+		Action:      auditCommunityExport,
+		TargetType:  "community",
+		TargetID:    commID,
+		Details:     map[string]interface{}{"format": format, "members": members},
+	})
+}
+
+// exportFilename derives the download name from the community name, falling back to its ID
+func exportFilename(community *models.Community) string {
+	name := generateSlug(community.Details.Name)
+	if name == "" {
+		name = community.ID
+	}
+	return name + "-export"
+}
+
+// jsonExport writes {"community": ..., "exportedAt": ..., "members": [...]}
+type jsonExport struct {
+	w          http.ResponseWriter
+	community  *models.Community
+	exportedAt primitive.DateTime
+	members    int
+}
+
+func (je *jsonExport) start() error {
+	c, err := json.Marshal(je.community)
+	if err != nil {
+		return err
+	}
+	t, err := json.Marshal(je.exportedAt)
+	if err != nil {
+		return err
+	}
+	je.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, exportFilename(je.community)))
+	je.w.WriteHeader(http.StatusOK)
+	_, err = fmt.Fprintf(je.w, `{"community":%s,"exportedAt":%s,"members":[`, c, t)
+	return err
+}
+
+func (je *jsonExport) member(m models.CommunityExportMember) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if je.members > 0 {
+		b = append([]byte(","), b...)
+	}
+	je.members++
+	_, err = je.w.Write(b)
+	return err
+}
+
+func (je *jsonExport) flush() {
+	flush(je.w)
+}
+
+func (je *jsonExport) finish() error {
+	_, err := io.WriteString(je.w, "]}")
+	return err
+}
+
+// csvExport writes a zip holding community.csv and members.csv
+type csvExport struct {
+	w         http.ResponseWriter
+	community *models.Community
+	zw        *zip.Writer
+	members   *csv.Writer
+}
+
+func (ce *csvExport) start() error {
+	ce.w.Header().Set("Content-Type", "application/zip")
+	ce.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, exportFilename(ce.community)))
+	ce.w.WriteHeader(http.StatusOK)
+	ce.zw = zip.NewWriter(ce.w)
+
+	f, err := ce.zw.Create("community.csv")
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"_id", "name", "slug", "ownerID", "code", "createdAt"})
+	cw.Write([]string{
+		ce.community.ID,
+		ce.community.Details.Name,
+		ce.community.Details.Slug,
+		ce.community.Details.OwnerID,
+		ce.community.Details.Code,
+		formatExportTime(ce.community.Details.CreatedAt),
+	})
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	f, err = ce.zw.Create("members.csv")
+	if err != nil {
+		return err
+	}
+	ce.members = csv.NewWriter(f)
+	ce.members.Write([]string{"_id", "username", "callSign"})
+	return ce.members.Error()
+}
+
+func (ce *csvExport) member(m models.CommunityExportMember) error {
+	return ce.members.Write([]string{m.ID, m.Username, m.CallSign})
+}
+
+func (ce *csvExport) flush() {
+	ce.members.Flush()
+	ce.zw.Flush()
+	flush(ce.w)
+}
+
+func (ce *csvExport) finish() error {
+	ce.members.Flush()
+	if err := ce.members.Error(); err != nil {
+		return err
+	}
+	return ce.zw.Close()
+}
+
+// formatExportTime formats a stored date as RFC 3339, or empty when it was never set
+func formatExportTime(t primitive.DateTime) string {
+	if t == 0 {
+		return ""
+	}
+	return t.Time().UTC().Format(time.RFC3339)
+}
+
+// flush sends whatever has been written so far to the client, when w supports it
+func flush(w io.Writer) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
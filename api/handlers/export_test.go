@@ -0,0 +1,213 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func exportRequest(userID, format string) *http.Request {
+	req, _ := http.NewRequest("GET", "/api/v1/community/"+apiKeyCommunityID+"/export?format="+format, nil)
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	return mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID})
+}
+
+// exportDocument is the json document the export streams
+type exportDocument struct {
+	Community  models.Community               `json:"community"`
+	ExportedAt primitive.DateTime             `json:"exportedAt"`
+	Members    []models.CommunityExportMember `json:"members"`
+}
+
+// exportUsersDB hands users to Each one at a time, then returns err
+func exportUsersDB(users []models.User, err error) *mocks.UserDatabase {
+	db := &mocks.UserDatabase{}
+	db.On("Each", mock.Anything, bson.M{"user.activeCommunity": apiKeyCommunityID}, mock.Anything).Return(
+		func(ctx context.Context, filter interface{}, fn func(models.User) error) error {
+			for _, user := range users {
+				if err := fn(user); err != nil {
+					return err
+				}
+			}
+			return err
+		})
+	return db
+}
+
+func exportUserDB() *mocks.UserDatabase {
+	return exportUsersDB([]models.User{
+		{ID: "user-1", Details: models.UserDetails{Username: "jdoe", CallSign: "1-ADAM-12", Email: "jdoe@example.com", Password: "hash"}},
+		{ID: "user-2", Details: models.UserDetails{Username: "asmith", CallSign: "2-LINCOLN-5"}},
+	}, nil)
+}
+
+func exportCommunityDB() *mocks.CommunityDatabase {
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(&models.Community{
+		ID:      apiKeyCommunityID,
+		Details: models.CommunityDetails{Name: "Los Santos RP", OwnerID: apiKeyOwnerID, Slug: "los-santos-rp"},
+	}, nil)
+	return db
+}
+
+func TestCommunityExport_JSON(t *testing.T) {
+	auditDB := &mocks.AuditLogDatabase{}
+	auditDB.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+	e := handlers.CommunityExport{DB: exportCommunityDB(), UserDB: exportUserDB(), AuditLogDB: auditDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(e.CommunityExportHandler).ServeHTTP(rr, exportRequest(apiKeyOwnerID, ""))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename="los-santos-rp-export.json"`, rr.Header().Get("Content-Disposition"))
+	assert.NotContains(t, rr.Body.String(), "jdoe@example.com")
+	var got exportDocument
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "Los Santos RP", got.Community.Details.Name)
+	assert.Equal(t, []models.CommunityExportMember{
+		{ID: "user-1", Username: "jdoe", CallSign: "1-ADAM-12"},
+		{ID: "user-2", Username: "asmith", CallSign: "2-LINCOLN-5"},
+	}, got.Members)
+	assert.NotZero(t, got.ExportedAt)
+	auditDB.AssertNumberOfCalls(t, "InsertOne", 1)
+}
+
+func TestCommunityExport_CSV(t *testing.T) {
+	e := handlers.CommunityExport{DB: exportCommunityDB(), UserDB: exportUserDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(e.CommunityExportHandler).ServeHTTP(rr, exportRequest(apiKeyOwnerID, "csv"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="los-santos-rp-export.zip"`, rr.Header().Get("Content-Disposition"))
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	assert.NoError(t, err)
+	files := map[string][][]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name], err = csv.NewReader(bytes.NewReader(b)).ReadAll()
+		assert.NoError(t, err)
+	}
+	assert.Len(t, files, 2)
+	assert.Equal(t, []string{apiKeyCommunityID, "Los Santos RP", "los-santos-rp", apiKeyOwnerID, "", ""}, files["community.csv"][1])
+	assert.Equal(t, [][]string{
+		{"_id", "username", "callSign"},
+		{"user-1", "jdoe", "1-ADAM-12"},
+		{"user-2", "asmith", "2-LINCOLN-5"},
+	}, files["members.csv"])
+}
+
+func TestCommunityExport_NotOwner(t *testing.T) {
+	userDB := &mocks.UserDatabase{}
+	e := handlers.CommunityExport{DB: exportCommunityDB(), UserDB: userDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(e.CommunityExportHandler).ServeHTTP(rr, exportRequest("someone-else", "json"))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Disposition"))
+	userDB.AssertNotCalled(t, "Each", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCommunityExport_InvalidFormat(t *testing.T) {
+	e := handlers.CommunityExport{DB: exportCommunityDB(), UserDB: exportUserDB()}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(e.CommunityExportHandler).ServeHTTP(rr, exportRequest(apiKeyOwnerID, "xml"))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCommunityExport_MembersError(t *testing.T) {
+	e := handlers.CommunityExport{DB: exportCommunityDB(), UserDB: exportUsersDB(nil, errors.New("mocked-error"))}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(e.CommunityExportHandler).ServeHTTP(rr, exportRequest(apiKeyOwnerID, "json"))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Disposition"))
+}
+
+func TestCommunityExport_MembersErrorWhileStreaming(t *testing.T) {
+	auditDB := &mocks.AuditLogDatabase{}
+	users := []models.User{{ID: "user-1"}}
+	e := handlers.CommunityExport{DB: exportCommunityDB(), UserDB: exportUsersDB(users, errors.New("cursor killed")), AuditLogDB: auditDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(e.CommunityExportHandler).ServeHTTP(rr, exportRequest(apiKeyOwnerID, "json"))
+
+	// the status is already sent, the document is left unterminated and nothing is audited
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, json.Valid(rr.Body.Bytes()))
+	auditDB.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestCommunityExport_NoMembers(t *testing.T) {
+	e := handlers.CommunityExport{DB: exportCommunityDB(), UserDB: exportUsersDB(nil, nil)}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(e.CommunityExportHandler).ServeHTTP(rr, exportRequest(apiKeyOwnerID, "json"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got exportDocument
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, []models.CommunityExportMember{}, got.Members)
+}
+
+func TestCommunityExport_Flushes(t *testing.T) {
+	users := make([]models.User, 1200)
+	for i := range users {
+		users[i] = models.User{ID: fmt.Sprintf("user-%d", i)}
+	}
+	for _, format := range []string{"json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			e := handlers.CommunityExport{DB: exportCommunityDB(), UserDB: exportUsersDB(users, nil)}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(e.CommunityExportHandler).ServeHTTP(rr, exportRequest(apiKeyOwnerID, format))
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.True(t, rr.Flushed)
+		})
+	}
+}
+
+func TestCommunityExport_StreamOutlivesQueryTimeout(t *testing.T) {
+	userDB := exportUserDB()
+	e := handlers.CommunityExport{DB: exportCommunityDB(), UserDB: userDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(e.CommunityExportHandler).ServeHTTP(rr, exportRequest(apiKeyOwnerID, "json"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	// the members are streamed with their own deadline, not the one of a single query
+	deadline, ok := userDB.Calls[0].Arguments.Get(0).(context.Context).Deadline()
+	assert.True(t, ok)
+	assert.True(t, time.Until(deadline) > api.DefaultQueryTimeout)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipMinSize is the smallest body worth compressing, below it the gzip overhead outweighs the saving
+const gzipMinSize = 1024
+
+// GzipMiddleware compresses JSON responses of at least gzipMinSize bytes for clients that
+// accept gzip. Smaller bodies, other content types (the zip export for example) and responses
+// a handler already encoded itself are passed through as they are.
+func GzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows a gzip response
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		if strings.TrimSpace(fields[0]) != "gzip" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			// q=0 means the client refuses gzip
+			if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether the body is big
+// enough to compress, then either compresses everything or writes it through unchanged
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status != 0 {
+		return
+	}
+	g.status = status
+	// these responses have no body, so there is nothing to wait for
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		g.decide(false)
+	}
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if !g.decided {
+		if !g.compressible() {
+			g.decide(false)
+		} else {
+			g.buf = append(g.buf, b...)
+			if len(g.buf) < gzipMinSize {
+				return len(b), nil
+			}
+			return len(b), g.decide(true)
+		}
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// Flush sends what has been written so far, so streaming handlers keep streaming
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		g.decide(g.compressible())
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// compressible reports whether the response is JSON that nothing else has encoded
+func (g *gzipResponseWriter) compressible() bool {
+	h := g.Header()
+	return h.Get("Content-Encoding") == "" && strings.HasPrefix(h.Get("Content-Type"), "application/json")
+}
+
+// decide writes the status line and any buffered body, compressed or not
+func (g *gzipResponseWriter) decide(compress bool) error {
+	g.decided = true
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if compress {
+		g.Header().Set("Content-Encoding", "gzip")
+		g.Header().Del("Content-Length")
+		g.ResponseWriter.WriteHeader(g.status)
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+		_, err := g.gz.Write(g.buf)
+		g.buf = nil
+		return err
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	if len(g.buf) == 0 {
+		return nil
+	}
+	_, err := g.ResponseWriter.Write(g.buf)
+	g.buf = nil
+	return err
+}
+
+// close finishes the response once the handler returns
+func (g *gzipResponseWriter) close() {
+	if !g.decided {
+		if g.status == 0 && len(g.buf) == 0 {
+			// the handler wrote nothing, leave the default response to net/http
+			return
+		}
+		g.decide(false)
+	}
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
@@ -0,0 +1,103 @@
+package handlers_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+)
+
+func jsonHandler(body []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+}
+
+func gzipRequest() *http.Request {
+	req, _ := http.NewRequest("GET", "/api/v1/communities/owner", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	return req
+}
+
+func TestGzipMiddleware_CompressesLargeJSON(t *testing.T) {
+	names := make([]string, 200)
+	for i := range names {
+		names[i] = "Los Santos Police Department"
+	}
+	body, _ := json.Marshal(names)
+
+	for name, h := range map[string]http.Handler{
+		"write": jsonHandler(body),
+		"encoder": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(names)
+		}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handlers.GzipMiddleware(h).ServeHTTP(rr, gzipRequest())
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			assert.Less(t, rr.Body.Len(), len(body))
+
+			zr, err := gzip.NewReader(rr.Body)
+			assert.NoError(t, err)
+			got, err := io.ReadAll(zr)
+			assert.NoError(t, err)
+			assert.JSONEq(t, string(body), string(got))
+		})
+	}
+}
+
+func TestGzipMiddleware_SmallBodyUncompressed(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handlers.GzipMiddleware(jsonHandler([]byte(`{"alive": true}`))).ServeHTTP(rr, gzipRequest())
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+	assert.Equal(t, `{"alive": true}`, rr.Body.String())
+}
+
+func TestGzipMiddleware_Passthrough(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), 4096)
+	tests := map[string]struct {
+		handler        http.Handler
+		acceptEncoding string
+	}{
+		"client without gzip":  {jsonHandler([]byte(`"` + string(large) + `"`)), ""},
+		"client refusing gzip": {jsonHandler([]byte(`"` + string(large) + `"`)), "gzip;q=0"},
+		"not json": {http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/zip")
+			w.Write(large)
+		}), "gzip"},
+		"already encoded": {http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "br")
+			w.Write(large)
+		}), "gzip"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := gzipRequest()
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rr := httptest.NewRecorder()
+			handlers.GzipMiddleware(tt.handler).ServeHTTP(rr, req)
+
+			assert.NotEqual(t, "gzip", rr.Header().Get("Content-Encoding"))
+			assert.True(t, strings.Contains(rr.Body.String(), string(large)))
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	// maxIdempotencyKeyLength is the longest Idempotency-Key header accepted
+	maxIdempotencyKeyLength = 128
+	// idempotencyKeyLifetime is how long a stored response can be replayed
+	idempotencyKeyLifetime = 24 * time.Hour
+	// idempotencyReservationTimeout is how long a reserved key blocks retries when the request
+	// holding it never finishes, e.g. because the instance was stopped
+	idempotencyReservationTimeout = time.Minute
+)
+
+// Idempotency replays the stored response of a create request when it is retried with the
+// same Idempotency-Key, so clients on flaky connections do not create duplicates. Requests
+// without the header are passed through untouched.
+type Idempotency struct {
+	DB databases.IdempotencyDatabase
+}
+
+// idempotencyRecorder writes the response through while keeping a copy to store
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotencyRoute identifies the route a request was made to, using the route template
+// when the request went through the router
+func idempotencyRoute(r *http.Request) string {
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			path = tpl
+		}
+	}
+	return r.Method + " " + path
+}
+
+// Middleware replays the stored response for a known Idempotency-Key. Otherwise it reserves the
+// key, runs next and stores a successful response for idempotencyKeyLifetime. A retry that
+// arrives while the first request is still running gets a 409 instead of running next again.
+// Keys are scoped to the route, the community and the user, so the same key can never replay
+// another user's response.
+func (i *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			config.ErrorStatus("idempotency key is too long", http.StatusBadRequest, w, fmt.Errorf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength))
+			return
+		}
+
+		route := idempotencyRoute(r)
+		commID := mux.Vars(r)["community_id"]
+		sum := sha256.Sum256([]byte(key + "\x00" + route + "\x00" + commID + "\x00" + getUserIDFromRequest(r)))
+		id := hex.EncodeToString(sum[:])
+
+		reserveCtx, cancelReserve := api.WithQueryTimeout(r.Context())
+		reserved, err := i.reserve(reserveCtx, id, key, route, commID)
+		if mongo.IsDuplicateKeyError(err) {
+			// another request holds the key, replay its response once it has one
+			record, findErr := i.DB.FindOne(reserveCtx, bson.M{"_id": id})
+			cancelReserve()
+			if findErr == nil && record.Status != 0 {
+				zap.S().Debugw("replaying idempotent response", "route", route, "community_id", commID)
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.Status)
+				w.Write(record.Body)
+				return
+			}
+			config.ErrorStatus("a request with this idempotency key is already in progress", http.StatusConflict, w, errors.New("idempotency key is reserved"))
+			return
+		}
+		cancelReserve()
+		if err != nil {
+			// failing open keeps the api usable, at worst a retry creates a duplicate
+			zap.S().With(err).Warnw("failed to reserve idempotency key", "route", route)
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if !reserved {
+			return
+		}
+		// the handler's own queries must not eat into the time left for storing the response
+		ctx, cancel := api.WithQueryTimeout(r.Context())
+		defer cancel()
+		if rec.status < 200 || rec.status >= 300 {
+			// only successes are stored, releasing the key lets a failed request be retried with it
+			if _, err := i.DB.DeleteMany(ctx, bson.M{"_id": id, "status": 0}); err != nil {
+				zap.S().With(err).Warnw("failed to release idempotency key", "route", route)
+			}
+			return
+		}
+		_, err = i.DB.UpdateOne(ctx,
+			bson.M{"_id": id},
+			bson.M{"$set": bson.M{"status": rec.status, "body": rec.body.Bytes()}},
+		)
+		if err != nil {
+			zap.S().With(err).Warnw("failed to store idempotent response", "route", route)
+		}
+	})
+}
+
+// reserve claims an idempotency key before the request runs. The key is claimed with an upsert
+// on _id, so when two requests race only one of them can insert it and the other gets a
+// duplicate key error. Records past their lifetime, and reservations whose request never
+// finished, are taken over instead of blocking the key until mongo removes them.
+func (i *Idempotency) reserve(ctx context.Context, id, key, route, commID string) (bool, error) {
+	now := time.Now()
+	_, err := i.DB.UpdateOne(ctx,
+		bson.M{
+			"_id": id,
+			"$or": bson.A{
+				bson.M{"createdAt": bson.M{"$lte": primitive.NewDateTimeFromTime(now.Add(-idempotencyKeyLifetime))}},
+				bson.M{"status": 0, "createdAt": bson.M{"$lte": primitive.NewDateTimeFromTime(now.Add(-idempotencyReservationTimeout))}},
+			},
+		},
+		bson.M{"$set": models.IdempotencyRecord{
+			ID:          id,
+			Key:         key,
+			Route:       route,
+			CommunityID: commID,
+			CreatedAt:   primitive.NewDateTimeFromTime(now),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err == nil, err
+}
@@ -0,0 +1,226 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const idempotentCitationBody = `{"civilianId": "` + bundleCivilianID + `", "name": "Speeding", "amount": 250}`
+
+func TestIdempotency_MiddlewareReplaysResponse(t *testing.T) {
+	db := &mocks.CitationDatabase{}
+	db.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+	c := handlers.Citation{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+
+	idemDB := &mocks.IdempotencyDatabase{}
+	idemDB.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{UpsertedCount: 1}, nil).Once()
+	idemDB.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{ModifiedCount: 1}, nil).Once()
+	idem := &handlers.Idempotency{DB: idemDB}
+	h := idem.Middleware(http.HandlerFunc(c.CreateCitationHandler))
+
+	req := createCitationRequest(idempotentCitationBody)
+	req.Header.Set("Idempotency-Key", "retry-me")
+	first := httptest.NewRecorder()
+	h.ServeHTTP(first, req)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	reserved := idemDB.Calls[0].Arguments.Get(2).(bson.M)["$set"].(models.IdempotencyRecord)
+	assert.Equal(t, 0, reserved.Status)
+	assert.Equal(t, apiKeyCommunityID, reserved.CommunityID)
+	stored := idemDB.Calls[1].Arguments.Get(2).(bson.M)["$set"].(bson.M)
+	assert.Equal(t, http.StatusCreated, stored["status"])
+	assert.Equal(t, first.Body.Bytes(), stored["body"])
+
+	reserved.Status = stored["status"].(int)
+	reserved.Body = stored["body"].([]byte)
+	idemDB.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, duplicateKeyError())
+	idemDB.On("FindOne", mock.Anything, mock.Anything).Return(&reserved, nil)
+
+	req = createCitationRequest(idempotentCitationBody)
+	req.Header.Set("Idempotency-Key", "retry-me")
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, req)
+
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	db.AssertNumberOfCalls(t, "InsertOne", 1)
+}
+
+func TestIdempotency_MiddlewareDoesNotStoreErrors(t *testing.T) {
+	c := handlers.Citation{DB: &mocks.CitationDatabase{}, CivilianDB: citationCivilianDB("608cafe595eb9dc05379ffff"), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+	idemDB := &mocks.IdempotencyDatabase{}
+	idemDB.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{UpsertedCount: 1}, nil)
+	idemDB.On("DeleteMany", mock.Anything, mock.Anything).Return(&mongo.DeleteResult{DeletedCount: 1}, nil)
+	idem := &handlers.Idempotency{DB: idemDB}
+
+	req := createCitationRequest(idempotentCitationBody)
+	req.Header.Set("Idempotency-Key", "retry-me")
+	rr := httptest.NewRecorder()
+	idem.Middleware(http.HandlerFunc(c.CreateCitationHandler)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	idemDB.AssertNumberOfCalls(t, "UpdateOne", 1)
+	idemDB.AssertNumberOfCalls(t, "DeleteMany", 1)
+}
+
+func TestIdempotency_MiddlewareKeyInProgress(t *testing.T) {
+	c := handlers.Citation{DB: &mocks.CitationDatabase{}}
+	idemDB := &mocks.IdempotencyDatabase{}
+	idemDB.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, duplicateKeyError())
+	idemDB.On("FindOne", mock.Anything, mock.Anything).Return(&models.IdempotencyRecord{}, nil)
+	idem := &handlers.Idempotency{DB: idemDB}
+
+	req := createCitationRequest(idempotentCitationBody)
+	req.Header.Set("Idempotency-Key", "retry-me")
+	rr := httptest.NewRecorder()
+	idem.Middleware(http.HandlerFunc(c.CreateCitationHandler)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "already in progress")
+	idemDB.AssertNotCalled(t, "DeleteMany", mock.Anything, mock.Anything)
+}
+
+func TestIdempotency_MiddlewareConcurrentRequests(t *testing.T) {
+	idem := &handlers.Idempotency{DB: newIdempotencyStore()}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var runs int32
+	h := idem.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"608cafe595eb9dc05379bbbb"}`))
+	}))
+	newRequest := func() *http.Request {
+		req := createCitationRequest(idempotentCitationBody)
+		req.Header.Set("Idempotency-Key", "retry-me")
+		return req
+	}
+
+	first := httptest.NewRecorder()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(first, newRequest())
+	}()
+	<-started
+
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, newRequest())
+	assert.Equal(t, http.StatusConflict, second.Code)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	third := httptest.NewRecorder()
+	h.ServeHTTP(third, newRequest())
+	assert.Equal(t, http.StatusCreated, third.Code)
+	assert.Equal(t, "true", third.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), third.Body.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+}
+
+func duplicateKeyError() error {
+	return mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}
+}
+
+// idempotencyStore is an in memory IdempotencyDatabase that enforces the unique _id like mongo
+// does, which the mocks can't do for requests running at the same time
+type idempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{records: map[string]models.IdempotencyRecord{}}
+}
+
+func (s *idempotencyStore) FindOne(ctx context.Context, filter interface{}) (*models.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[filter.(bson.M)["_id"].(string)]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &record, nil
+}
+
+func (s *idempotencyStore) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := filter.(bson.M)["_id"].(string)
+	record, exists := s.records[id]
+	switch set := update.(bson.M)["$set"].(type) {
+	case models.IdempotencyRecord:
+		// the stored records never expire here, so a reservation only succeeds on a new key
+		if exists {
+			return nil, duplicateKeyError()
+		}
+		s.records[id] = set
+		return &mongo.UpdateResult{UpsertedCount: 1}, nil
+	case bson.M:
+		if !exists {
+			return &mongo.UpdateResult{}, nil
+		}
+		record.Status = set["status"].(int)
+		record.Body = set["body"].([]byte)
+		s.records[id] = record
+		return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+	}
+	return nil, errors.New("unexpected update")
+}
+
+func (s *idempotencyStore) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, filter.(bson.M)["_id"].(string))
+	return &mongo.DeleteResult{DeletedCount: 1}, nil
+}
+
+func TestIdempotency_MiddlewareWithoutKey(t *testing.T) {
+	db := &mocks.CitationDatabase{}
+	db.On("InsertOne", mock.Anything, mock.Anything).Return(&mongo.InsertOneResult{}, nil)
+	c := handlers.Citation{DB: db, CivilianDB: citationCivilianDB(apiKeyCommunityID), CommunityDB: ownedCommunityDB(), UserDB: citationMemberDB()}
+	idemDB := &mocks.IdempotencyDatabase{}
+	idem := &handlers.Idempotency{DB: idemDB}
+
+	rr := httptest.NewRecorder()
+	idem.Middleware(http.HandlerFunc(c.CreateCitationHandler)).ServeHTTP(rr, createCitationRequest(idempotentCitationBody))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Empty(t, idemDB.Calls)
+}
+
+func TestIdempotency_MiddlewareKeyTooLong(t *testing.T) {
+	c := handlers.Citation{DB: &mocks.CitationDatabase{}}
+	idem := &handlers.Idempotency{DB: &mocks.IdempotencyDatabase{}}
+
+	req := createCitationRequest(idempotentCitationBody)
+	req.Header.Set("Idempotency-Key", strings.Repeat("k", 129))
+	rr := httptest.NewRecorder()
+	idem.Middleware(http.HandlerFunc(c.CreateCitationHandler)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "idempotency key is too long")
+}
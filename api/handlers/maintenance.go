@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	// maintenanceDocID is the _id of the single maintenance document every instance polls
+	maintenanceDocID = "global"
+	// maintenanceRetryAfter is the Retry-After value, in seconds, sent with every 503
+	maintenanceRetryAfter   = "120"
+	defaultMaintenanceError = "the api is currently in maintenance mode, please try again later"
+)
+
+// maintenanceExemptPaths are never blocked, so the api can still be monitored and
+// maintenance mode can always be turned back off
+var maintenanceExemptPaths = map[string]bool{
+	"/health":                   true,
+	"/ready":                    true,
+	"/api/v1/admin/maintenance": true,
+	"/api/v1/admin/selfcheck":   true,
+}
+
+// Maintenance keeps the maintenance state in memory so the middleware never has to
+// hit mongo. Poll keeps it in sync with the stored document across instances.
+type Maintenance struct {
+	DB databases.MaintenanceDatabase
+	// ForceEnabled is set from the MAINTENANCE_MODE env var and keeps writes
+	// blocked no matter what the stored document says
+	ForceEnabled bool
+
+	mu    sync.RWMutex
+	state models.Maintenance
+}
+
+// maintenanceRequest is the body accepted by MaintenanceHandler
+type maintenanceRequest struct {
+	Enabled    bool   `json:"enabled"`
+	Message    string `json:"message"`
+	AllowReads *bool  `json:"allowReads"`
+}
+
+// State returns the current maintenance state of this instance
+func (m *Maintenance) State() models.Maintenance {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state := m.state
+	if m.ForceEnabled && !state.Enabled {
+		state.Enabled = true
+		state.AllowReads = true
+	}
+	return state
+}
+
+func (m *Maintenance) setState(state models.Maintenance) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+}
+
+// Refresh reloads the maintenance state from mongo. A missing document means maintenance is off.
+func (m *Maintenance) Refresh(ctx context.Context) error {
+	dbResp, err := m.DB.FindOne(ctx, bson.M{"_id": maintenanceDocID})
+	if err == mongo.ErrNoDocuments {
+		m.setState(models.Maintenance{AllowReads: true})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m.setState(*dbResp)
+	return nil
+}
+
+// Poll refreshes the maintenance state every interval until the context is cancelled
+func (m *Maintenance) Poll(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		zap.S().Warnf("maintenance poll interval must be positive, got %v, not polling", interval)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Refresh(ctx); err != nil {
+			zap.S().With(err).Warn("failed to refresh maintenance state")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Middleware rejects mutating requests with a 503 while maintenance mode is enabled.
+// Reads are also rejected when the maintenance state does not allow them.
+func (m *Maintenance) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := m.State()
+		if !state.Enabled || maintenanceExemptPaths[r.URL.Path] || (state.AllowReads && !isMutatingMethod(r.Method)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		message := state.Message
+		if message == "" {
+			message = defaultMaintenanceError
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", maintenanceRetryAfter)
+		config.ErrorStatus(message, http.StatusServiceUnavailable, w, errors.New("maintenance mode is enabled"))
+	})
+}
+
+// MaintenanceHandler turns maintenance mode on or off. The state is applied to this
+// instance immediately and stored in mongo so every other instance picks it up on its next poll.
+func (m *Maintenance) MaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		config.ErrorStatus("failed to decode request body", http.StatusBadRequest, w, err)
+		return
+	}
+	allowReads := true
+	if req.AllowReads != nil {
+		allowReads = *req.AllowReads
+	}
+
+	state := models.Maintenance{
+		ID:         maintenanceDocID,
+		Enabled:    req.Enabled,
+		Message:    req.Message,
+		AllowReads: allowReads,
+		UpdatedAt:  primitive.NewDateTimeFromTime(time.Now()),
+	}
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	_, err := m.DB.UpdateOne(ctx,
+		bson.M{"_id": maintenanceDocID},
+		bson.M{"$set": bson.M{
+			"enabled":    state.Enabled,
+			"message":    state.Message,
+			"allowReads": state.AllowReads,
+			"updatedAt":  state.UpdatedAt,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		config.ErrorStatus("failed to update maintenance state", http.StatusInternalServerError, w, err)
+		return
+	}
+	m.setState(state)
+
+	zap.S().Infow("maintenance state updated", "enabled", state.Enabled, "allowReads", state.AllowReads)
+
+	respondJSON(w, http.StatusOK, m.State())
+}
+
+// isMutatingMethod reports whether the http method changes data
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
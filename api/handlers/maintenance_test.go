@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func maintenanceWithState(t *testing.T, state *models.Maintenance) *handlers.Maintenance {
+	db := &mocks.MaintenanceDatabase{}
+	if state == nil {
+		db.On("FindOne", mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+	} else {
+		db.On("FindOne", mock.Anything, mock.Anything).Return(state, nil)
+	}
+	m := &handlers.Maintenance{DB: db}
+	assert.NoError(t, m.Refresh(context.Background()))
+	return m
+}
+
+func serveThrough(m *handlers.Maintenance, method, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	rr := httptest.NewRecorder()
+	m.Middleware(okHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMaintenance_MiddlewareDisabled(t *testing.T) {
+	m := maintenanceWithState(t, nil)
+
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		assert.Equal(t, http.StatusOK, serveThrough(m, method, "/api/v1/community/1234").Code, method)
+	}
+}
+
+func TestMaintenance_MiddlewareBlocksWrites(t *testing.T) {
+	m := maintenanceWithState(t, &models.Maintenance{Enabled: true, AllowReads: true, Message: "migrating communities"})
+
+	assert.Equal(t, http.StatusOK, serveThrough(m, "GET", "/api/v1/community/1234").Code)
+	for _, method := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+		rr := serveThrough(m, method, "/api/v1/community/1234")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code, method)
+		assert.Equal(t, "120", rr.Header().Get("Retry-After"))
+		assert.Contains(t, rr.Body.String(), "migrating communities")
+	}
+}
+
+func TestMaintenance_MiddlewareBlocksReads(t *testing.T) {
+	m := maintenanceWithState(t, &models.Maintenance{Enabled: true, AllowReads: false})
+
+	rr := serveThrough(m, "GET", "/api/v1/community/1234")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "maintenance mode")
+}
+
+func TestMaintenance_MiddlewareExemptPaths(t *testing.T) {
+	m := maintenanceWithState(t, &models.Maintenance{Enabled: true, AllowReads: false})
+
+	assert.Equal(t, http.StatusOK, serveThrough(m, "GET", "/health").Code)
+	assert.Equal(t, http.StatusOK, serveThrough(m, "GET", "/ready").Code)
+	assert.Equal(t, http.StatusOK, serveThrough(m, "POST", "/api/v1/admin/maintenance").Code)
+}
+
+func TestMaintenance_ForceEnabled(t *testing.T) {
+	m := maintenanceWithState(t, nil)
+	m.ForceEnabled = true
+
+	assert.Equal(t, http.StatusOK, serveThrough(m, "GET", "/api/v1/community/1234").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serveThrough(m, "POST", "/api/v1/community/1234").Code)
+}
+
+func TestMaintenance_PollPropagatesState(t *testing.T) {
+	db := &mocks.MaintenanceDatabase{}
+	// another instance has not enabled maintenance yet
+	db.On("FindOne", mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments).Once()
+	// ...and now it has
+	db.On("FindOne", mock.Anything, mock.Anything).Return(&models.Maintenance{Enabled: true, AllowReads: true}, nil)
+	m := &handlers.Maintenance{DB: db}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Poll(ctx, 5*time.Millisecond)
+
+	assert.Eventually(t, func() bool { return m.State().Enabled }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, serveThrough(m, "POST", "/api/v1/community/1234").Code)
+}
+
+func TestMaintenance_MaintenanceHandler(t *testing.T) {
+	db := &mocks.MaintenanceDatabase{}
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{UpsertedCount: 1}, nil)
+	m := &handlers.Maintenance{DB: db}
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/maintenance", strings.NewReader(`{"enabled": true, "message": "back soon", "allowReads": false}`))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(m.MaintenanceHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, m.State().Enabled)
+	assert.False(t, m.State().AllowReads)
+	assert.Equal(t, "back soon", m.State().Message)
+	db.AssertNumberOfCalls(t, "UpdateOne", 1)
+}
+
+func TestMaintenance_MaintenanceHandlerBadBody(t *testing.T) {
+	db := &mocks.MaintenanceDatabase{}
+	m := &handlers.Maintenance{DB: db}
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/maintenance", strings.NewReader(`{"enabled": "yes"`))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(m.MaintenanceHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.False(t, m.State().Enabled)
+}
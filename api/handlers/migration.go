@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	defaultMigrationBatchSize = 500
+	maxMigrationBatchSize     = 5000
+	// maxMigrationBatches bounds how much work a single migration request does
+	maxMigrationBatches = 20
+)
+
+// legacyOwnerIDFilter matches communities still carrying the legacy community.ownerId key,
+// leaving out the ones that were skipped so they are not fetched again
+func legacyOwnerIDFilter(skipped []string) bson.M {
+	filter := bson.M{"community.ownerId": bson.M{"$exists": true}}
+	if len(skipped) > 0 {
+		filter["_id"] = bson.M{"$nin": skipped}
+	}
+	return filter
+}
+
+// legacyOwnerIDUpdate copies community.ownerId into community.ownerID, unless the canonical
+// key is already set, and then removes the legacy key
+var legacyOwnerIDUpdate = mongo.Pipeline{
+	{{Key: "$set", Value: bson.M{"community.ownerID": bson.M{"$ifNull": bson.A{"$community.ownerID", "$community.ownerId"}}}}},
+	{{Key: "$unset", Value: "community.ownerId"}},
+}
+
+// MigrateLegacyOwnerIDHandler rewrites communities stored with the legacy community.ownerId
+// key to the canonical community.ownerID key. It works in batches of ?batch_size and stops
+// after a fixed number of batches, so it should be called until the response reports done.
+func (c Community) MigrateLegacyOwnerIDHandler(w http.ResponseWriter, r *http.Request) {
+	batchSize := int64(defaultMigrationBatchSize)
+	if v := r.URL.Query().Get("batch_size"); v != "" {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size < 1 || size > maxMigrationBatchSize {
+			config.ErrorStatus("invalid batch_size", http.StatusBadRequest, w, fmt.Errorf("batch_size must be between 1 and %d", maxMigrationBatchSize))
+			return
+		}
+		batchSize = size
+	}
+
+	progress := models.MigrationProgress{}
+	var skipped []string
+	for progress.Batches < maxMigrationBatches {
+		// every query gets its own timeout, the batches together may take longer than one
+		ctx, cancel := api.WithQueryTimeout(r.Context())
+		communities, err := c.DB.Find(ctx, legacyOwnerIDFilter(skipped), options.Find().SetLimit(batchSize).SetProjection(bson.M{"_id": 1}))
+		cancel()
+		if err != nil {
+			config.ErrorStatus("failed to get communities to migrate", http.StatusInternalServerError, w, err)
+			return
+		}
+		if len(communities) == 0 {
+			progress.Done = true
+			break
+		}
+
+		ids := make([]primitive.ObjectID, 0, len(communities))
+		for _, community := range communities {
+			id, err := primitive.ObjectIDFromHex(community.ID)
+			if err != nil {
+				zap.S().With(err).Warnw("skipping community with non ObjectID _id", "community_id", community.ID)
+				skipped = append(skipped, community.ID)
+				continue
+			}
+			ids = append(ids, id)
+		}
+		progress.Batches++
+		progress.Skipped = len(skipped)
+		if len(ids) > 0 {
+			ctx, cancel := api.WithQueryTimeout(r.Context())
+			res, err := c.DB.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, legacyOwnerIDUpdate)
+			cancel()
+			if err != nil {
+				config.ErrorStatus("failed to migrate communities", http.StatusInternalServerError, w, err)
+				return
+			}
+			progress.Migrated += res.ModifiedCount
+		}
+		zap.S().Infow("migrated legacy ownerId batch", "batch", progress.Batches, "migrated", progress.Migrated)
+
+		if int64(len(communities)) < batchSize {
+			progress.Done = true
+			break
+		}
+	}
+
+	respondJSON(w, http.StatusOK, progress)
+}
@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func TestCommunity_MigrateLegacyOwnerIDHandler(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+	db.On("Find", mock.Anything, bson.M{"community.ownerId": bson.M{"$exists": true}}, mock.Anything).Return([]models.Community{
+		{ID: "608cafe595eb9dc05379b7f4"},
+		{ID: "608cafe595eb9dc05379b7f5"},
+	}, nil).Once()
+	db.On("Find", mock.Anything, bson.M{"community.ownerId": bson.M{"$exists": true}}, mock.Anything).Return([]models.Community{
+		{ID: "608cafe595eb9dc05379b7f6"},
+	}, nil).Once()
+	db.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{ModifiedCount: 2}, nil).Once()
+	db.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{ModifiedCount: 1}, nil).Once()
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/migrations/community-owner-id?batch_size=2", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Community{DB: db}.MigrateLegacyOwnerIDHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var got models.MigrationProgress
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, models.MigrationProgress{Batches: 2, Migrated: 3, Done: true}, got)
+}
+
+func TestCommunity_MigrateLegacyOwnerIDHandlerNothingToMigrate(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+	db.On("Find", mock.Anything, mock.Anything, mock.Anything).Return([]models.Community{}, nil)
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/migrations/community-owner-id", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Community{DB: db}.MigrateLegacyOwnerIDHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.MigrationProgress
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, models.MigrationProgress{Done: true}, got)
+	db.AssertNotCalled(t, "UpdateMany", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCommunity_MigrateLegacyOwnerIDHandlerInvalidBatchSize(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/migrations/community-owner-id?batch_size=-1", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Community{DB: db}.MigrateLegacyOwnerIDHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestCommunity_MigrateLegacyOwnerIDHandlerUpdateError(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+	db.On("Find", mock.Anything, mock.Anything, mock.Anything).Return([]models.Community{{ID: "608cafe595eb9dc05379b7f4"}}, nil)
+	db.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("mocked-error"))
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/migrations/community-owner-id", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Community{DB: db}.MigrateLegacyOwnerIDHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestCommunity_MigrateLegacyOwnerIDHandlerSkipsNonObjectIDs(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+	// a full batch of ids that can not be migrated must not be fetched again
+	db.On("Find", mock.Anything, bson.M{"community.ownerId": bson.M{"$exists": true}}, mock.Anything).Return([]models.Community{
+		{ID: "legacy-1"},
+		{ID: "legacy-2"},
+	}, nil).Once()
+	db.On("Find", mock.Anything, bson.M{
+		"community.ownerId": bson.M{"$exists": true},
+		"_id":               bson.M{"$nin": []string{"legacy-1", "legacy-2"}},
+	}, mock.Anything).Return([]models.Community{
+		{ID: "608cafe595eb9dc05379b7f6"},
+	}, nil).Once()
+	db.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{ModifiedCount: 1}, nil).Once()
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/migrations/community-owner-id?batch_size=2", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handlers.Community{DB: db}.MigrateLegacyOwnerIDHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.MigrationProgress
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, models.MigrationProgress{Batches: 2, Migrated: 1, Skipped: 2, Done: true}, got)
+	db.AssertNumberOfCalls(t, "UpdateMany", 1)
+}
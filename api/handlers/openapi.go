@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/docs"
+)
+
+// openAPISpec is the embedded swagger.yaml converted to JSON, built on first request
+var openAPISpec struct {
+	once sync.Once
+	b    []byte
+	err  error
+}
+
+// specJSON converts a YAML spec to JSON
+func specJSON(spec []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// OpenAPIHandler serves the api's swagger spec as JSON so clients can fetch it at runtime
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	openAPISpec.once.Do(func() {
+		openAPISpec.b, openAPISpec.err = specJSON(docs.SwaggerYAML)
+	})
+	if openAPISpec.err != nil {
+		config.ErrorStatus("failed to convert spec", http.StatusInternalServerError, w, openAPISpec.err)
+		return
+	}
+	writeWithETag(w, r, openAPISpec.b)
+}
@@ -0,0 +1,87 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/v1/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	handlers.OpenAPIHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.NotEmpty(t, rr.Header().Get("ETag"))
+
+	var spec struct {
+		Swagger string                 `json:"swagger"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &spec))
+	assert.Equal(t, "2.0", spec.Swagger)
+	assert.Contains(t, spec.Paths, "/health")
+}
+
+func TestOpenAPIHandler_NotModified(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/v1/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	handlers.OpenAPIHandler(rr, req)
+
+	req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	handlers.OpenAPIHandler(rr, req)
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+}
+
+// TestOpenAPIHandler_MatchesRouter fails when a route is added to or removed from the router
+// without regenerating docs/swagger.yaml, so the served spec can not drift from the api
+func TestOpenAPIHandler_MatchesRouter(t *testing.T) {
+	a := handlers.App{}
+	var routes []string
+	err := a.New().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || path == "/" || route.GetHandler() == nil {
+			// the swagger ui file server and the subrouter prefix are not api routes
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{http.MethodGet}
+		}
+		for _, method := range methods {
+			routes = append(routes, strings.ToLower(method)+" "+path)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/v1/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	handlers.OpenAPIHandler(rr, req)
+	var spec struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &spec))
+	var documented []string
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(documented)
+	assert.Equal(t, routes, documented, "docs/swagger.yaml is out of date, update docs/docs.go and run make swagger")
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+// pageDefaults are the limit a list route uses when none is asked for and the most it allows
+type pageDefaults struct {
+	Limit    int
+	MaxLimit int
+}
+
+// pageRequest is the 0-based page and the limit of a list request
+type pageRequest struct {
+	Page  int
+	Limit int
+}
+
+// parsePageLimit reads the page and limit query params. A missing, negative or non-numeric
+// page is the first page, and a page so large it would overflow the skip is clamped. The
+// limit falls back to the route default and is capped at its max, see getLimit.
+func parsePageLimit(r *http.Request, d pageDefaults) pageRequest {
+	limit := getLimit(r, d.Limit, d.MaxLimit)
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 0 {
+		page = 0
+	}
+	if page > math.MaxInt32/limit {
+		page = math.MaxInt32 / limit
+	}
+	return pageRequest{Page: page, Limit: limit}
+}
+
+// skip is the number of documents before the page
+func (p pageRequest) skip() int64 {
+	return int64(p.Page) * int64(p.Limit)
+}
+
+// findOptions limits a query to the page, callers add their own sort
+func (p pageRequest) findOptions() *options.FindOptions {
+	return options.Find().SetLimit(int64(p.Limit)).SetSkip(p.skip())
+}
+
+// buildMeta describes the page out of total matching items
+func buildMeta(p pageRequest, total int64) models.Pagination {
+	return models.Pagination{
+		Page:       p.Page,
+		Limit:      p.Limit,
+		TotalCount: total,
+		HasMore:    p.skip()+int64(p.Limit) < total,
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func TestParsePageLimit(t *testing.T) {
+	defaults := pageDefaults{Limit: 20, MaxLimit: 100}
+	tests := []struct {
+		name  string
+		query string
+		want  pageRequest
+	}{
+		{"defaults", "", pageRequest{Page: 0, Limit: 20}},
+		{"page and limit", "page=2&limit=50", pageRequest{Page: 2, Limit: 50}},
+		{"negative page", "page=-3", pageRequest{Page: 0, Limit: 20}},
+		{"non-numeric page", "page=two", pageRequest{Page: 0, Limit: 20}},
+		{"negative limit", "limit=-1", pageRequest{Page: 0, Limit: 20}},
+		{"zero limit", "limit=0", pageRequest{Page: 0, Limit: 20}},
+		{"non-numeric limit", "limit=ten", pageRequest{Page: 0, Limit: 20}},
+		{"limit over max", "limit=5000", pageRequest{Page: 0, Limit: 100}},
+		{"huge page", "page=9223372036854775807&limit=100", pageRequest{Page: math.MaxInt32 / 100, Limit: 100}},
+		{"page past int", "page=99999999999999999999", pageRequest{Page: 0, Limit: 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/v1/communities/search?"+tt.query, nil)
+			got := parsePageLimit(req, defaults)
+			assert.Equal(t, tt.want, got)
+			assert.GreaterOrEqual(t, got.skip(), int64(0))
+		})
+	}
+}
+
+func TestPageRequestFindOptions(t *testing.T) {
+	opts := pageRequest{Page: 3, Limit: 25}.findOptions()
+	assert.Equal(t, int64(25), *opts.Limit)
+	assert.Equal(t, int64(75), *opts.Skip)
+}
+
+func TestBuildMeta(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  pageRequest
+		total int64
+		want  models.Pagination
+	}{
+		{"empty", pageRequest{Page: 0, Limit: 20}, 0, models.Pagination{Page: 0, Limit: 20, TotalCount: 0, HasMore: false}},
+		{"first of many", pageRequest{Page: 0, Limit: 20}, 45, models.Pagination{Page: 0, Limit: 20, TotalCount: 45, HasMore: true}},
+		{"exactly full", pageRequest{Page: 1, Limit: 20}, 40, models.Pagination{Page: 1, Limit: 20, TotalCount: 40, HasMore: false}},
+		{"last partial", pageRequest{Page: 2, Limit: 20}, 45, models.Pagination{Page: 2, Limit: 20, TotalCount: 45, HasMore: false}},
+		{"past the end", pageRequest{Page: 9, Limit: 20}, 45, models.Pagination{Page: 9, Limit: 20, TotalCount: 45, HasMore: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, buildMeta(tt.page, tt.total))
+		})
+	}
+}
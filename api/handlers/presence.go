@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+)
+
+// defaultPresenceTimeout is used when Presence.Timeout is not set
+const defaultPresenceTimeout = 5 * time.Minute
+
+// Presence marks users offline once they stop sending heartbeats, so a client that crashes
+// does not leave its user online forever
+type Presence struct {
+	DB databases.UserDatabase
+	// Timeout is how long a user stays online after their last heartbeat
+	Timeout time.Duration
+}
+
+// HeartbeatHandler marks the user as online and records when they were last seen.
+// Users can only send heartbeats for themselves.
+func (u User) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	u.setOnline(w, r, true)
+}
+
+// OfflineHandler marks the user as offline straight away, for clients that shut down cleanly
+func (u User) OfflineHandler(w http.ResponseWriter, r *http.Request) {
+	u.setOnline(w, r, false)
+}
+
+func (u User) setOnline(w http.ResponseWriter, r *http.Request, online bool) {
+	uID := mux.Vars(r)["user_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("user_id: %v, online: %v", uID, online)
+
+	if userID == "" || userID != uID {
+		config.ErrorStatus("users can only update their own presence", http.StatusForbidden, w, errors.New("user does not match"))
+		return
+	}
+	oID, err := primitive.ObjectIDFromHex(uID)
+	if err != nil {
+		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	set := bson.M{"user.isOnline": online}
+	if online {
+		set["user.lastSeenAt"] = primitive.NewDateTimeFromTime(time.Now())
+	}
+	res, err := u.DB.UpdateOne(ctx, bson.M{"_id": oID}, bson.M{"$set": set})
+	if err != nil {
+		config.ErrorStatus("failed to update presence", http.StatusInternalServerError, w, err)
+		return
+	}
+	if res.MatchedCount == 0 {
+		config.ErrorStatus("failed to get user by ID", http.StatusNotFound, w, errors.New("user not found"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sweep marks every online user whose last heartbeat is older than the timeout as offline.
+// It is a single update, so a heartbeat that lands during the sweep is never overwritten:
+// it moves lastSeenAt past the cutoff and the user no longer matches the filter.
+func (p *Presence) Sweep(ctx context.Context) (int64, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultPresenceTimeout
+	}
+	cutoff := primitive.NewDateTimeFromTime(time.Now().Add(-timeout))
+	res, err := p.DB.UpdateMany(ctx,
+		bson.M{
+			"user.isOnline": true,
+			// users set online before heartbeats existed have no lastSeenAt
+			"$or": []bson.M{
+				{"user.lastSeenAt": bson.M{"$lt": cutoff}},
+				{"user.lastSeenAt": bson.M{"$exists": false}},
+			},
+		},
+		bson.M{"$set": bson.M{"user.isOnline": false}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// Run sweeps every interval until the context is cancelled
+func (p *Presence) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		zap.S().Warnf("presence sweep interval must be positive, got %v, not sweeping", interval)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := p.Sweep(ctx)
+		if err != nil {
+			zap.S().With(err).Warn("failed to sweep user presence")
+			continue
+		}
+		if n > 0 {
+			zap.S().Infow("marked users offline", "count", n)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+)
+
+const presenceUserID = "608cafe595eb9dc05379b7f1"
+
+func presenceRequest(path, userID string) *http.Request {
+	req, _ := http.NewRequest("POST", "/api/v1/users/"+presenceUserID+"/"+path, nil)
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	return mux.SetURLVars(req, map[string]string{"user_id": presenceUserID})
+}
+
+func TestPresence_HeartbeatHandler(t *testing.T) {
+	db := &mocks.UserDatabase{}
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	u := handlers.User{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(u.HeartbeatHandler).ServeHTTP(rr, presenceRequest("heartbeat", presenceUserID))
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	oID, _ := primitive.ObjectIDFromHex(presenceUserID)
+	assert.Equal(t, bson.M{"_id": oID}, db.Calls[0].Arguments.Get(1))
+	set := db.Calls[0].Arguments.Get(2).(bson.M)["$set"].(bson.M)
+	assert.Equal(t, true, set["user.isOnline"])
+	assert.WithinDuration(t, time.Now(), set["user.lastSeenAt"].(primitive.DateTime).Time(), time.Minute)
+}
+
+func TestPresence_OfflineHandler(t *testing.T) {
+	db := &mocks.UserDatabase{}
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	u := handlers.User{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(u.OfflineHandler).ServeHTTP(rr, presenceRequest("offline", presenceUserID))
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, bson.M{"$set": bson.M{"user.isOnline": false}}, db.Calls[0].Arguments.Get(2))
+}
+
+func TestPresence_HeartbeatHandlerOtherUser(t *testing.T) {
+	db := &mocks.UserDatabase{}
+	u := handlers.User{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(u.HeartbeatHandler).ServeHTTP(rr, presenceRequest("heartbeat", "someone-else"))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPresence_HeartbeatHandlerUnknownUser(t *testing.T) {
+	db := &mocks.UserDatabase{}
+	db.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(&mongo.UpdateResult{}, nil)
+	u := handlers.User{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(u.HeartbeatHandler).ServeHTTP(rr, presenceRequest("heartbeat", presenceUserID))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPresence_Sweep(t *testing.T) {
+	db := &mocks.UserDatabase{}
+	db.On("UpdateMany", mock.Anything, mock.Anything, bson.M{"$set": bson.M{"user.isOnline": false}}).Return(&mongo.UpdateResult{MatchedCount: 3, ModifiedCount: 3}, nil)
+	p := handlers.Presence{DB: db, Timeout: 2 * time.Minute}
+
+	n, err := p.Sweep(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	db.AssertNumberOfCalls(t, "UpdateMany", 1)
+	filter := db.Calls[0].Arguments.Get(1).(bson.M)
+	assert.Equal(t, true, filter["user.isOnline"])
+	or := filter["$or"].([]bson.M)
+	cutoff := or[0]["user.lastSeenAt"].(bson.M)["$lt"].(primitive.DateTime).Time()
+	assert.WithinDuration(t, time.Now().Add(-2*time.Minute), cutoff, 10*time.Second)
+	assert.Equal(t, bson.M{"user.lastSeenAt": bson.M{"$exists": false}}, or[1])
+}
+
+func TestPresence_SweepError(t *testing.T) {
+	db := &mocks.UserDatabase{}
+	db.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("mocked-error"))
+	p := handlers.Presence{DB: db}
+
+	_, err := p.Sweep(context.Background())
+
+	assert.EqualError(t, err, "mocked-error")
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	defaultRegistrationSearchLimit = 20
+	maxRegistrationSearchLimit     = 100
+)
+
+// RegistrationSearch looks up the vehicles and firearms registered in a community, for plate
+// and serial number checks
+type RegistrationSearch struct {
+	VehicleDB  databases.VehicleDatabase
+	FirearmDB  databases.FirearmDatabase
+	CivilianDB databases.CivilianDatabase
+	WarrantDB  databases.WarrantDatabase
+}
+
+// exactRegex matches values equal to s, case insensitively. s is escaped so it is always
+// matched literally.
+func exactRegex(s string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(s) + "$", Options: "i"}
+}
+
+// registeredOwners loads the civilians with the given IDs in one query and flags the ones with
+// an active warrant. Civilians that were erased or can not be found are left out.
+func (rs RegistrationSearch) registeredOwners(ctx context.Context, ownerIDs []string) (map[string]models.RegisteredOwner, error) {
+	owners := map[string]models.RegisteredOwner{}
+	ids := make([]primitive.ObjectID, 0, len(ownerIDs))
+	for _, owner := range ownerIDs {
+		if oID, err := primitive.ObjectIDFromHex(owner); err == nil {
+			ids = append(ids, oID)
+		}
+	}
+	if len(ids) == 0 {
+		return owners, nil
+	}
+	civilians, err := rs.CivilianDB.Find(ctx, bson.M{
+		"_id":                bson.M{"$in": ids},
+		"civilian.deletedAt": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	wanted, err := activeWarrants(ctx, rs.WarrantDB, civilians)
+	if err != nil {
+		return nil, err
+	}
+	for _, civilian := range civilians {
+		owners[civilian.ID] = models.RegisteredOwner{
+			ID:               civilian.ID,
+			FirstName:        civilian.Details.FirstName,
+			LastName:         civilian.Details.LastName,
+			Found:            true,
+			HasActiveWarrant: wanted[civilian.ID],
+		}
+	}
+	return owners, nil
+}
+
+// ownerOf returns the registered owner with the given ID, or one marked as not found
+func ownerOf(owners map[string]models.RegisteredOwner, ownerID string) models.RegisteredOwner {
+	if owner, ok := owners[ownerID]; ok {
+		return owner
+	}
+	return models.RegisteredOwner{ID: ownerID}
+}
+
+// CommunityVehicleSearchHandler finds the vehicles of a community with the given plate,
+// case insensitively, with their registered owner. No match is an empty list, not a 404.
+func (rs RegistrationSearch) CommunityVehicleSearchHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	plate := strings.TrimSpace(r.URL.Query().Get("plate"))
+	p := parsePageLimit(r, pageDefaults{Limit: defaultRegistrationSearchLimit, MaxLimit: maxRegistrationSearchLimit})
+
+	zap.S().Debugf("community_id: %v, plate: '%v'", commID, plate)
+
+	if plate == "" {
+		config.ErrorStatus("plate is required", http.StatusBadRequest, w, errors.New("missing plate"))
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	filter := bson.M{"vehicle.plate": exactRegex(plate), "vehicle.activeCommunityID": commID}
+	total, err := rs.VehicleDB.CountDocuments(ctx, filter)
+	if err != nil {
+		config.ErrorStatus("failed to search vehicles", http.StatusInternalServerError, w, err)
+		return
+	}
+	vehicles, err := rs.VehicleDB.Find(ctx, filter, p.findOptions().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		config.ErrorStatus("failed to search vehicles", http.StatusInternalServerError, w, err)
+		return
+	}
+	ownerIDs := make([]string, 0, len(vehicles))
+	for _, vehicle := range vehicles {
+		ownerIDs = append(ownerIDs, vehicle.Details.RegisteredOwnerID)
+	}
+	owners, err := rs.registeredOwners(ctx, ownerIDs)
+	if err != nil {
+		config.ErrorStatus("failed to get registered owners", http.StatusInternalServerError, w, err)
+		return
+	}
+
+	results := make([]models.VehicleSearchResult, 0, len(vehicles))
+	for _, vehicle := range vehicles {
+		results = append(results, models.VehicleSearchResult{Vehicle: vehicle, Owner: ownerOf(owners, vehicle.Details.RegisteredOwnerID)})
+	}
+	respondJSON(w, http.StatusOK, models.VehicleSearchResponse{Vehicles: results, Pagination: buildMeta(p, total)})
+}
+
+// CommunityFirearmSearchHandler finds the firearms of a community with the given serial number,
+// case insensitively, with their registered owner. No match is an empty list, not a 404.
+func (rs RegistrationSearch) CommunityFirearmSearchHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	serial := strings.TrimSpace(r.URL.Query().Get("serial"))
+	p := parsePageLimit(r, pageDefaults{Limit: defaultRegistrationSearchLimit, MaxLimit: maxRegistrationSearchLimit})
+
+	zap.S().Debugf("community_id: %v, serial: '%v'", commID, serial)
+
+	if serial == "" {
+		config.ErrorStatus("serial is required", http.StatusBadRequest, w, errors.New("missing serial"))
+		return
+	}
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	filter := bson.M{"firearm.serialNumber": exactRegex(serial), "firearm.activeCommunityID": commID}
+	total, err := rs.FirearmDB.CountDocuments(ctx, filter)
+	if err != nil {
+		config.ErrorStatus("failed to search firearms", http.StatusInternalServerError, w, err)
+		return
+	}
+	firearms, err := rs.FirearmDB.Find(ctx, filter, p.findOptions().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		config.ErrorStatus("failed to search firearms", http.StatusInternalServerError, w, err)
+		return
+	}
+	ownerIDs := make([]string, 0, len(firearms))
+	for _, firearm := range firearms {
+		ownerIDs = append(ownerIDs, firearm.Details.RegisteredOwnerID)
+	}
+	owners, err := rs.registeredOwners(ctx, ownerIDs)
+	if err != nil {
+		config.ErrorStatus("failed to get registered owners", http.StatusInternalServerError, w, err)
+		return
+	}
+
+	results := make([]models.FirearmSearchResult, 0, len(firearms))
+	for _, firearm := range firearms {
+		results = append(results, models.FirearmSearchResult{Firearm: firearm, Owner: ownerOf(owners, firearm.Details.RegisteredOwnerID)})
+	}
+	respondJSON(w, http.StatusOK, models.FirearmSearchResponse{Firearms: results, Pagination: buildMeta(p, total)})
+}
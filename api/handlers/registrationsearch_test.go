@@ -0,0 +1,126 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func registrationSearchRequest(path string, query url.Values) *http.Request {
+	req, _ := http.NewRequest("GET", "/api/v1/community/"+apiKeyCommunityID+"/"+path+"/search?"+query.Encode(), nil)
+	return mux.SetURLVars(req, map[string]string{"community_id": apiKeyCommunityID})
+}
+
+// registeredOwnerDBs returns the civilian and warrant databases for an owner with an active warrant
+func registeredOwnerDBs() (*mocks.CivilianDatabase, *mocks.WarrantDatabase) {
+	civilianDB := &mocks.CivilianDatabase{}
+	civilianDB.On("Find", mock.Anything, mock.Anything).Return([]models.Civilian{{ID: bundleCivilianID, Details: models.CivilianDetails{FirstName: "John", LastName: "Doe"}}}, nil)
+	warrantDB := &mocks.WarrantDatabase{}
+	warrantDB.On("Find", mock.Anything, mock.Anything, mock.Anything).Return([]models.Warrant{{Details: models.WarrantDetails{AccusedID: bundleCivilianID}}}, nil)
+	return civilianDB, warrantDB
+}
+
+func TestRegistrationSearch_CommunityVehicleSearchHandler(t *testing.T) {
+	filter := bson.M{
+		"vehicle.plate":             primitive.Regex{Pattern: `^AB\.C123$`, Options: "i"},
+		"vehicle.activeCommunityID": apiKeyCommunityID,
+	}
+	vehicleDB := &mocks.VehicleDatabase{}
+	vehicleDB.On("CountDocuments", mock.Anything, filter).Return(int64(2), nil)
+	vehicleDB.On("Find", mock.Anything, filter, mock.Anything).Return([]models.Vehicle{
+		{ID: "v1", Details: models.VehicleDetails{Plate: "AB.C123", RegisteredOwnerID: bundleCivilianID}},
+		{ID: "v2", Details: models.VehicleDetails{Plate: "ab.c123", RegisteredOwnerID: "gone"}},
+	}, nil)
+	civilianDB, warrantDB := registeredOwnerDBs()
+	rs := handlers.RegistrationSearch{VehicleDB: vehicleDB, CivilianDB: civilianDB, WarrantDB: warrantDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(rs.CommunityVehicleSearchHandler).ServeHTTP(rr, registrationSearchRequest("vehicles", url.Values{"plate": {" AB.C123 "}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.VehicleSearchResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, models.Pagination{Page: 0, Limit: 20, TotalCount: 2}, got.Pagination)
+	assert.Len(t, got.Vehicles, 2)
+	assert.Equal(t, models.RegisteredOwner{ID: bundleCivilianID, FirstName: "John", LastName: "Doe", Found: true, HasActiveWarrant: true}, got.Vehicles[0].Owner)
+	assert.Equal(t, models.RegisteredOwner{ID: "gone"}, got.Vehicles[1].Owner)
+
+	// only ids that can be civilians are looked up
+	cID, _ := primitive.ObjectIDFromHex(bundleCivilianID)
+	ownerFilter := civilianDB.Calls[0].Arguments.Get(1).(bson.M)
+	assert.Equal(t, bson.M{"$in": []primitive.ObjectID{cID}}, ownerFilter["_id"])
+}
+
+func TestRegistrationSearch_CommunityVehicleSearchHandlerNoMatch(t *testing.T) {
+	vehicleDB := &mocks.VehicleDatabase{}
+	vehicleDB.On("CountDocuments", mock.Anything, mock.Anything).Return(int64(0), nil)
+	vehicleDB.On("Find", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	civilianDB := &mocks.CivilianDatabase{}
+	rs := handlers.RegistrationSearch{VehicleDB: vehicleDB, CivilianDB: civilianDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(rs.CommunityVehicleSearchHandler).ServeHTTP(rr, registrationSearchRequest("vehicles", url.Values{"plate": {"NOPE"}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"vehicles":[],"pagination":{"page":0,"limit":20,"totalCount":0,"hasMore":false}}`, rr.Body.String())
+	civilianDB.AssertNotCalled(t, "Find", mock.Anything, mock.Anything)
+}
+
+func TestRegistrationSearch_CommunityVehicleSearchHandlerMissingPlate(t *testing.T) {
+	vehicleDB := &mocks.VehicleDatabase{}
+	rs := handlers.RegistrationSearch{VehicleDB: vehicleDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(rs.CommunityVehicleSearchHandler).ServeHTTP(rr, registrationSearchRequest("vehicles", url.Values{"plate": {"  "}}))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	vehicleDB.AssertNotCalled(t, "Find", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRegistrationSearch_CommunityFirearmSearchHandler(t *testing.T) {
+	filter := bson.M{
+		"firearm.serialNumber":      primitive.Regex{Pattern: `^SN-\(1\)$`, Options: "i"},
+		"firearm.activeCommunityID": apiKeyCommunityID,
+	}
+	firearmDB := &mocks.FirearmDatabase{}
+	firearmDB.On("CountDocuments", mock.Anything, filter).Return(int64(3), nil)
+	firearmDB.On("Find", mock.Anything, filter, mock.Anything).Return([]models.Firearm{
+		{ID: "f1", Details: models.FirearmDetails{SerialNumber: "SN-(1)", RegisteredOwnerID: bundleCivilianID}},
+	}, nil)
+	civilianDB, warrantDB := registeredOwnerDBs()
+	rs := handlers.RegistrationSearch{FirearmDB: firearmDB, CivilianDB: civilianDB, WarrantDB: warrantDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(rs.CommunityFirearmSearchHandler).ServeHTTP(rr, registrationSearchRequest("firearms", url.Values{"serial": {"SN-(1)"}, "limit": {"1"}}))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.FirearmSearchResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, models.Pagination{Page: 0, Limit: 1, TotalCount: 3, HasMore: true}, got.Pagination)
+	assert.Len(t, got.Firearms, 1)
+	assert.True(t, got.Firearms[0].Owner.HasActiveWarrant)
+}
+
+func TestRegistrationSearch_CommunityFirearmSearchHandlerError(t *testing.T) {
+	firearmDB := &mocks.FirearmDatabase{}
+	firearmDB.On("CountDocuments", mock.Anything, mock.Anything).Return(int64(0), errors.New("i/o timeout"))
+	rs := handlers.RegistrationSearch{FirearmDB: firearmDB}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(rs.CommunityFirearmSearchHandler).ServeHTTP(rr, registrationSearchRequest("firearms", url.Values{"serial": {"SN1"}}))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
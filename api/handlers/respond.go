@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/linesmerrill/police-cad-api/config"
+)
+
+// respondJSON marshals payload and writes it with the given status. The body is marshaled
+// before anything is written, so a marshal failure turns into a 500 error message instead
+// of a success status followed by a broken body.
+func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	b, err := json.Marshal(payload)
+	if err != nil {
+		config.ErrorStatus("failed to marshal response", http.StatusInternalServerError, w, err)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(b)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespondJSON(t *testing.T) {
+	rr := httptest.NewRecorder()
+	respondJSON(rr, http.StatusCreated, map[string]string{"name": "LSPD"})
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"name": "LSPD"}`, rr.Body.String())
+}
+
+func TestRespondJSONMarshalFailure(t *testing.T) {
+	rr := httptest.NewRecorder()
+	respondJSON(rr, http.StatusOK, map[string]interface{}{"bad": make(chan int)})
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"Response": {"Message": "failed to marshal response", "Error": "json: unsupported type: chan int"}}`, rr.Body.String())
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+// defaultSelfCheckTimeout bounds each check, so one hanging dependency cannot stall the whole run
+const defaultSelfCheckTimeout = 10 * time.Second
+
+// SelfCheckFunc is a single non-destructive check. Returning nil passes the check, a
+// SelfCheckWarning warns and any other error fails it.
+type SelfCheckFunc func(ctx context.Context) error
+
+// SelfCheckWarning is returned by a check that found a problem the api can still run with
+type SelfCheckWarning struct {
+	Message string
+}
+
+func (w SelfCheckWarning) Error() string {
+	return w.Message
+}
+
+type selfCheck struct {
+	name  string
+	check SelfCheckFunc
+}
+
+// SelfCheck is the registry of checks run by the -selfcheck flag and the admin selfcheck route.
+// Subsystems register their own checks when they are wired up in App.New.
+type SelfCheck struct {
+	// Timeout bounds each check, defaults to 10s
+	Timeout time.Duration
+
+	checks []selfCheck
+}
+
+// Register adds a check to the registry. Checks run in the order they were registered.
+func (s *SelfCheck) Register(name string, check SelfCheckFunc) {
+	s.checks = append(s.checks, selfCheck{name: name, check: check})
+}
+
+// Run runs every registered check and reports each one with its timing
+func (s *SelfCheck) Run(ctx context.Context) models.SelfCheckReport {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultSelfCheckTimeout
+	}
+	report := models.SelfCheckReport{Status: models.SelfCheckPass, Results: []models.SelfCheckResult{}}
+	for _, c := range s.checks {
+		start := time.Now()
+		err := runSelfCheck(ctx, timeout, c.check)
+		result := models.SelfCheckResult{
+			Name:       c.name,
+			Status:     models.SelfCheckPass,
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+		var warning SelfCheckWarning
+		if errors.As(err, &warning) {
+			result.Status = models.SelfCheckWarn
+			result.Message = warning.Message
+			if report.Status == models.SelfCheckPass {
+				report.Status = models.SelfCheckWarn
+			}
+		} else if err != nil {
+			result.Status = models.SelfCheckFail
+			result.Message = err.Error()
+			report.Status = models.SelfCheckFail
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// runSelfCheck runs a single check with a timeout, turning a panic into a failure
+func runSelfCheck(ctx context.Context, timeout time.Duration, check SelfCheckFunc) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("check panicked: %v", r)
+		}
+	}()
+	return check(ctx)
+}
+
+// SelfCheckHandler runs every registered check. It responds with a 503 when any check fails.
+func (s *SelfCheck) SelfCheckHandler(w http.ResponseWriter, r *http.Request) {
+	report := s.Run(r.Context())
+
+	zap.S().Infow("self check finished", "status", report.Status)
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		config.ErrorStatus("failed to marshal response", http.StatusInternalServerError, w, err)
+		return
+	}
+	if report.Status == models.SelfCheckFail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.Write(b)
+}
+
+// MongoSelfCheck makes sure mongo is reachable and has every collection the api reads from
+func MongoSelfCheck(db databases.DatabaseHelper) SelfCheckFunc {
+	return func(ctx context.Context) error {
+		names, err := db.ListCollectionNames(ctx, bson.M{})
+		if err != nil {
+			return fmt.Errorf("failed to list collections: %v", err)
+		}
+		existing := map[string]bool{}
+		for _, name := range names {
+			existing[name] = true
+		}
+		var missing []string
+		for _, name := range databases.RequiredCollections {
+			if !existing[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return SelfCheckWarning{Message: "missing collections: " + strings.Join(missing, ", ")}
+		}
+		return nil
+	}
+}
+
+// IndexSelfCheck makes sure every index the api relies on exists. It only lists the indexes,
+// creating them is left to ENSURE_INDEXES at startup.
+func IndexSelfCheck(db databases.DatabaseHelper) SelfCheckFunc {
+	return func(ctx context.Context) error {
+		missing, err := databases.MissingIndexes(ctx, db)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			// missing indexes only make queries slower
+			return SelfCheckWarning{Message: "missing indexes: " + strings.Join(missing, ", ")}
+		}
+		return nil
+	}
+}
+
+// ConfigSelfCheck runs the same validation as startup, then warns about optional settings
+// that are missing
+func ConfigSelfCheck(conf config.Config) SelfCheckFunc {
+	return func(ctx context.Context) error {
+		if err := conf.Validate(); err != nil {
+			return err
+		}
+		if conf.BaseURL == "" {
+			return SelfCheckWarning{Message: "BASE_URL is not set"}
+		}
+		if conf.ProxySecret == "" {
+			return SelfCheckWarning{Message: "PROXY_SECRET is not set, the X-User-ID header is ignored and users are only identified by their token"}
+		}
+		if conf.MaintenanceMode {
+			return SelfCheckWarning{Message: "MAINTENANCE_MODE is enabled, writes are rejected"}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,163 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/linesmerrill/police-cad-api/api/handlers"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func TestSelfCheck_Run(t *testing.T) {
+	db := &mocks.DatabaseHelper{}
+	db.On("ListCollectionNames", mock.Anything, mock.Anything).Return(databases.RequiredCollections, nil)
+
+	s := &handlers.SelfCheck{}
+	s.Register("mongo", handlers.MongoSelfCheck(db))
+	s.Register("emitter", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	s.Register("storage", func(ctx context.Context) error {
+		return handlers.SelfCheckWarning{Message: "bucket is not versioned"}
+	})
+
+	report := s.Run(context.Background())
+
+	assert.Equal(t, models.SelfCheckFail, report.Status)
+	assert.Len(t, report.Results, 3)
+	assert.Equal(t, "mongo", report.Results[0].Name)
+	assert.Equal(t, models.SelfCheckPass, report.Results[0].Status)
+	assert.Equal(t, models.SelfCheckFail, report.Results[1].Status)
+	assert.Equal(t, "connection refused", report.Results[1].Message)
+	assert.Equal(t, models.SelfCheckWarn, report.Results[2].Status)
+}
+
+func TestSelfCheck_RunWarnOnly(t *testing.T) {
+	s := &handlers.SelfCheck{}
+	s.Register("ok", func(ctx context.Context) error { return nil })
+	s.Register("meh", func(ctx context.Context) error { return handlers.SelfCheckWarning{Message: "meh"} })
+
+	assert.Equal(t, models.SelfCheckWarn, s.Run(context.Background()).Status)
+}
+
+func TestSelfCheck_RunTimeoutAndPanic(t *testing.T) {
+	s := &handlers.SelfCheck{Timeout: 10 * time.Millisecond}
+	s.Register("hangs", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s.Register("panics", func(ctx context.Context) error {
+		panic("boom")
+	})
+
+	report := s.Run(context.Background())
+
+	assert.Equal(t, models.SelfCheckFail, report.Results[0].Status)
+	assert.Equal(t, models.SelfCheckFail, report.Results[1].Status)
+	assert.Contains(t, report.Results[1].Message, "boom")
+}
+
+func TestSelfCheck_SelfCheckHandler(t *testing.T) {
+	s := &handlers.SelfCheck{}
+	s.Register("emitter", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	req, _ := http.NewRequest("POST", "/api/v1/admin/selfcheck", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.SelfCheckHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	var report models.SelfCheckReport
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, models.SelfCheckFail, report.Status)
+}
+
+func TestSelfCheck_MongoSelfCheck(t *testing.T) {
+	db := &mocks.DatabaseHelper{}
+	db.On("ListCollectionNames", mock.Anything, mock.Anything).Return([]string{"users", "communities"}, nil)
+
+	err := handlers.MongoSelfCheck(db)(context.Background())
+
+	var warning handlers.SelfCheckWarning
+	assert.True(t, errors.As(err, &warning))
+	assert.Contains(t, warning.Message, "civilians")
+	assert.NotContains(t, warning.Message, "users")
+}
+
+func TestSelfCheck_MongoSelfCheckUnreachable(t *testing.T) {
+	db := &mocks.DatabaseHelper{}
+	db.On("ListCollectionNames", mock.Anything, mock.Anything).Return(nil, errors.New("server selection timeout"))
+
+	err := handlers.MongoSelfCheck(db)(context.Background())
+
+	assert.EqualError(t, err, "failed to list collections: server selection timeout")
+}
+
+func TestSelfCheck_IndexSelfCheck(t *testing.T) {
+	indexView := &mocks.IndexViewHelper{}
+	indexView.On("ListSpecifications", mock.Anything).Return(nil, nil)
+	collection := &mocks.CollectionHelper{}
+	collection.On("Indexes").Return(indexView)
+	db := &mocks.DatabaseHelper{}
+	db.On("Collection", mock.Anything).Return(collection)
+
+	err := handlers.IndexSelfCheck(db)(context.Background())
+
+	var warning handlers.SelfCheckWarning
+	assert.True(t, errors.As(err, &warning))
+	assert.Contains(t, warning.Message, "missing indexes: ")
+	assert.Contains(t, warning.Message, "communities.community.ownerID_1")
+	// the check only reports missing indexes, it never creates them
+	indexView.AssertNotCalled(t, "CreateMany", mock.Anything, mock.Anything)
+}
+
+// selfCheckConfig is a config that passes Validate with every optional setting filled in
+func selfCheckConfig() config.Config {
+	return config.Config{
+		URL:                     "mongodb://127.0.0.1:27017",
+		DatabaseName:            "test",
+		Port:                    "8080",
+		JWTSecret:               "shh",
+		BaseURL:                 "http://localhost",
+		ProxySecret:             "proxy",
+		MaintenancePollInterval: 30 * time.Second,
+		PresenceTimeout:         5 * time.Minute,
+		PresenceSweepInterval:   time.Minute,
+		DBQueryTimeout:          10 * time.Second,
+	}
+}
+
+func TestSelfCheck_ConfigSelfCheck(t *testing.T) {
+	conf := selfCheckConfig()
+	assert.NoError(t, handlers.ConfigSelfCheck(conf)(context.Background()))
+
+	conf.ProxySecret = ""
+	err := handlers.ConfigSelfCheck(conf)(context.Background())
+	assert.EqualError(t, err, "PROXY_SECRET is not set, the X-User-ID header is ignored and users are only identified by their token")
+
+	conf.BaseURL = ""
+	err = handlers.ConfigSelfCheck(conf)(context.Background())
+	assert.IsType(t, handlers.SelfCheckWarning{}, err)
+}
+
+func TestSelfCheck_ConfigSelfCheckInvalid(t *testing.T) {
+	conf := selfCheckConfig()
+	conf.URL, conf.JWTSecret = "", ""
+	conf.DBQueryTimeout = 0
+
+	err := handlers.ConfigSelfCheck(conf)(context.Background())
+
+	// the self check reports exactly what startup validation reports
+	assert.Equal(t, conf.Validate(), err)
+	assert.EqualError(t, err, "invalid config: missing required env vars: DB_URI, SECRET_KEY; DB_QUERY_TIMEOUT must be greater than 0, got 0s")
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const (
+	// slugChangeInterval is how long an owner has to wait between slug changes
+	slugChangeInterval = 30 * 24 * time.Hour
+	minSlugLength      = 3
+	maxSlugLength      = 50
+	// maxSlugAttempts bounds the number of suffixes tried before giving up on a collision
+	maxSlugAttempts = 100
+)
+
+// reservedSlugs collide with frontend routes and can never be claimed by a community
+var reservedSlugs = map[string]bool{
+	"api":   true,
+	"admin": true,
+	"c":     true,
+	"join":  true,
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugRequest is the body accepted by UpdateCommunitySlugHandler
+type slugRequest struct {
+	Slug string `json:"slug"`
+}
+
+// generateSlug lowercases the name and collapses every run of characters that
+// are not letters or digits into a single dash, e.g. "LSPD RP!" becomes "lspd-rp"
+func generateSlug(name string) string {
+	slug := nonSlugChars.ReplaceAllString(strings.ToLower(name), "-")
+	slug = strings.Trim(slug, "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	return slug
+}
+
+// validateSlug makes sure a slug can be claimed by a community
+func validateSlug(slug string) error {
+	if len(slug) < minSlugLength || len(slug) > maxSlugLength {
+		return fmt.Errorf("slug must be between %d and %d characters", minSlugLength, maxSlugLength)
+	}
+	if reservedSlugs[slug] {
+		return fmt.Errorf("slug '%s' is reserved", slug)
+	}
+	// a slug that looks like an ObjectID would be ambiguous for resolveCommunity
+	if primitive.IsValidObjectID(slug) {
+		return errors.New("slug cannot be a 24 character hex string")
+	}
+	return nil
+}
+
+// slugFilter matches a community by its current slug or any slug it used to have
+func slugFilter(slug string) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"community.slug": slug},
+		{"community.previousSlugs": slug},
+	}}
+}
+
+// uniqueSlug returns the first free variant of base, suffixing -2, -3, etc. on collision. The
+// base is shortened to make room for the suffix, and candidates validateSlug rejects, like
+// reserved words or ObjectID lookalikes, are skipped. Uniqueness is backed by the unique index
+// on community.slug.
+func (c Community) uniqueSlug(ctx context.Context, base string) (string, error) {
+	if len(base) < minSlugLength {
+		base = strings.Trim(fmt.Sprintf("community-%s", base), "-")
+	}
+	for i := 1; i <= maxSlugAttempts; i++ {
+		candidate := base
+		if i > 1 {
+			suffix := fmt.Sprintf("-%d", i)
+			if len(base)+len(suffix) > maxSlugLength {
+				candidate = strings.TrimRight(base[:maxSlugLength-len(suffix)], "-")
+			}
+			candidate += suffix
+		}
+		if validateSlug(candidate) != nil {
+			continue
+		}
+		_, err := c.DB.FindOne(ctx, slugFilter(candidate))
+		if err == mongo.ErrNoDocuments {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("could not find a free slug for '%s'", base)
+}
+
+// resolveCommunity finds a community by either its ObjectID or its slug. Slugs that were
+// changed still resolve, and the community returned always carries the canonical slug.
+// Communities created before slugs existed are given one the first time they are read.
+func (c Community) resolveCommunity(ctx context.Context, idOrSlug string) (*models.Community, error) {
+	if cID, err := primitive.ObjectIDFromHex(idOrSlug); err == nil {
+		community, err := c.DB.FindOne(ctx, bson.M{"_id": cID})
+		if err != nil {
+			return nil, err
+		}
+		if community.Details.Slug == "" {
+			c.backfillSlug(ctx, cID, community)
+		}
+		return community, nil
+	}
+	return c.DB.FindOne(ctx, slugFilter(strings.ToLower(idOrSlug)))
+}
+
+// backfillSlug generates and stores a slug for a community that does not have one yet.
+// Failures are only logged since the community is still usable without a slug.
+func (c Community) backfillSlug(ctx context.Context, cID primitive.ObjectID, community *models.Community) {
+	slug, err := c.uniqueSlug(ctx, generateSlug(community.Details.Name))
+	if err != nil {
+		zap.S().With(err).Warnw("failed to generate slug", "community_id", community.ID)
+		return
+	}
+	// only set the slug if nobody else has backfilled it in the meantime
+	res, err := c.DB.UpdateOne(ctx,
+		bson.M{"_id": cID, "community.slug": bson.M{"$in": []interface{}{nil, ""}}},
+		bson.M{"$set": bson.M{"community.slug": slug}},
+	)
+	if err != nil {
+		zap.S().With(err).Warnw("failed to backfill slug", "community_id", community.ID)
+		return
+	}
+	if res.MatchedCount > 0 {
+		community.Details.Slug = slug
+	}
+}
+
+// CommunityBySlugHandler returns a community given either its slug or its ID
+func (c Community) CommunityBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	zap.S().Debugf("slug: %v", slug)
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	dbResp, err := c.resolveCommunity(ctx, slug)
+	if err != nil {
+		config.ErrorStatus("failed to get community by slug", http.StatusNotFound, w, err)
+		return
+	}
+
+	b, err := json.Marshal(dbResp)
+	if err != nil {
+		config.ErrorStatus("failed to marshal response", http.StatusInternalServerError, w, err)
+		return
+	}
+	writeWithETag(w, r, b)
+}
+
+// UpdateCommunitySlugHandler lets the owner of a community change its slug once every 30 days.
+// The old slug is kept in previousSlugs so existing links keep working.
+func (c Community) UpdateCommunitySlugHandler(w http.ResponseWriter, r *http.Request) {
+	commID := mux.Vars(r)["community_id"]
+	userID := getUserIDFromRequest(r)
+
+	zap.S().Debugf("community_id: %v, user_id: %v", commID, userID)
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	cID, err := primitive.ObjectIDFromHex(commID)
+	if err != nil {
+		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
+		return
+	}
+
+	var req slugRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		config.ErrorStatus("failed to decode request body", http.StatusBadRequest, w, err)
+		return
+	}
+	slug := generateSlug(req.Slug)
+	if err := validateSlug(slug); err != nil {
+		config.ErrorStatus("invalid slug", http.StatusBadRequest, w, err)
+		return
+	}
+
+	community, err := c.DB.FindOne(ctx, bson.M{"_id": cID})
+	if err != nil {
+		config.ErrorStatus("failed to get community by ID", http.StatusNotFound, w, err)
+		return
+	}
+	if userID == "" || community.Details.OwnerID != userID {
+		config.ErrorStatus("only the community owner can change the slug", http.StatusForbidden, w, errors.New("user is not the community owner"))
+		return
+	}
+
+	if slug != community.Details.Slug {
+		if community.Details.SlugUpdatedAt != 0 {
+			nextChange := community.Details.SlugUpdatedAt.Time().Add(slugChangeInterval)
+			if time.Now().Before(nextChange) {
+				config.ErrorStatus("slug can only be changed once every 30 days", http.StatusBadRequest, w, fmt.Errorf("next change allowed after %v", nextChange.Format(time.RFC3339)))
+				return
+			}
+		}
+
+		existing, err := c.DB.FindOne(ctx, slugFilter(slug))
+		if err != nil && err != mongo.ErrNoDocuments {
+			config.ErrorStatus("failed to check slug availability", http.StatusInternalServerError, w, err)
+			return
+		}
+		if err == nil && existing.ID != community.ID {
+			config.ErrorStatus("slug is already taken", http.StatusConflict, w, fmt.Errorf("slug '%s' is in use by another community", slug))
+			return
+		}
+
+		now := primitive.NewDateTimeFromTime(time.Now())
+		update := bson.M{"$set": bson.M{
+			"community.slug":          slug,
+			"community.slugUpdatedAt": now,
+		}}
+		if community.Details.Slug != "" {
+			update["$addToSet"] = bson.M{"community.previousSlugs": community.Details.Slug}
+			community.Details.PreviousSlugs = append(community.Details.PreviousSlugs, community.Details.Slug)
+		}
+		_, err = c.DB.UpdateOne(ctx, bson.M{"_id": cID}, update)
+		if mongo.IsDuplicateKeyError(err) {
+			// another community took the slug after the check above, the unique index caught it
+			config.ErrorStatus("slug is already taken", http.StatusConflict, w, fmt.Errorf("slug '%s' is in use by another community", slug))
+			return
+		}
+		if err != nil {
+			config.ErrorStatus("failed to update slug", http.StatusInternalServerError, w, err)
+			return
+		}
+		recordAudit(c.AuditLogDB, models.AuditLogEntry{
+			CommunityID: commID,
+			ActorUserID: userID,
+			Action:      auditSlugUpdate,
+			TargetType:  "community",
+			TargetID:    commID,
+			Details:     map[string]interface{}{"from": community.Details.Slug, "to": slug},
+		})
+		community.Details.Slug = slug
+		community.Details.SlugUpdatedAt = now
+	}
+
+	respondJSON(w, http.StatusOK, community)
+}
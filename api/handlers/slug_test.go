@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/api"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func TestGenerateSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"LSPD RP", "lspd-rp"},
+		{"  Blaine County -- Sheriff!! ", "blaine-county-sheriff"},
+		{"Lines Police CAD", "lines-police-cad"},
+		{"!!!", ""},
+		{strings.Repeat("a", 60), strings.Repeat("a", maxSlugLength)},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, generateSlug(tt.name), tt.name)
+	}
+}
+
+func TestValidateSlug(t *testing.T) {
+	assert.NoError(t, validateSlug("lspd-rp"))
+	assert.Error(t, validateSlug("ab"))
+	assert.Error(t, validateSlug("api"))
+	assert.Error(t, validateSlug("join"))
+	assert.Error(t, validateSlug("608cafe595eb9dc05379b7f4"))
+}
+
+func TestCommunity_UniqueSlugCollision(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, slugFilter("lspd-rp")).Return(&models.Community{ID: "taken"}, nil)
+	db.On("FindOne", mock.Anything, slugFilter("lspd-rp-2")).Return(&models.Community{ID: "taken-too"}, nil)
+	db.On("FindOne", mock.Anything, slugFilter("lspd-rp-3")).Return(nil, mongo.ErrNoDocuments)
+
+	c := Community{DB: db}
+	slug, err := c.uniqueSlug(context.Background(), "lspd-rp")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "lspd-rp-3", slug)
+}
+
+func TestCommunity_UniqueSlugObjectIDName(t *testing.T) {
+	// a community named like an ObjectID would otherwise be resolved as an ID
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, slugFilter("608cafe595eb9dc05379b7f4-2")).Return(nil, mongo.ErrNoDocuments)
+
+	c := Community{DB: db}
+	slug, err := c.uniqueSlug(context.Background(), generateSlug("608CAFE595EB9DC05379B7F4"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "608cafe595eb9dc05379b7f4-2", slug)
+	db.AssertNumberOfCalls(t, "FindOne", 1)
+}
+
+func TestCommunity_UniqueSlugSuffixLength(t *testing.T) {
+	base := generateSlug(strings.Repeat("a", 48) + " b")
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, slugFilter(base)).Return(&models.Community{ID: "taken"}, nil)
+	db.On("FindOne", mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+
+	c := Community{DB: db}
+	slug, err := c.uniqueSlug(context.Background(), base)
+
+	assert.NoError(t, err)
+	// the base is cut before the suffix, along with the dash it ends on
+	assert.Equal(t, strings.Repeat("a", 48)+"-2", slug)
+	assert.NoError(t, validateSlug(slug))
+}
+
+func TestCommunity_CommunityBySlugHandlerLazyBackfill(t *testing.T) {
+	commID := "608cafe595eb9dc05379b7f4"
+	cID, _ := primitive.ObjectIDFromHex(commID)
+
+	req, err := http.NewRequest("GET", "/api/v1/community/by-slug/"+commID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"slug": commID})
+
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, bson.M{"_id": cID}).Return(&models.Community{ID: commID, Details: models.CommunityDetails{Name: "LSPD RP"}}, nil)
+	db.On("FindOne", mock.Anything, slugFilter("lspd-rp")).Return(nil, mongo.ErrNoDocuments)
+	db.On("UpdateOne", mock.Anything, mock.Anything, bson.M{"$set": bson.M{"community.slug": "lspd-rp"}}).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db}.CommunityBySlugHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.Community
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "lspd-rp", got.Details.Slug)
+	db.AssertNumberOfCalls(t, "UpdateOne", 1)
+}
+
+func TestCommunity_CommunityBySlugHandlerPreviousSlug(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/v1/community/by-slug/Old-Slug", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"slug": "Old-Slug"})
+
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, slugFilter("old-slug")).Return(&models.Community{ID: "608cafe595eb9dc05379b7f4", Details: models.CommunityDetails{Slug: "new-slug", PreviousSlugs: []string{"old-slug"}}}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db}.CommunityBySlugHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.Community
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "new-slug", got.Details.Slug)
+	db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCommunity_CommunityBySlugHandlerNotFound(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/v1/community/by-slug/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"slug": "missing"})
+
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, slugFilter("missing")).Return(nil, mongo.ErrNoDocuments)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db}.CommunityBySlugHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func newSlugRequest(t *testing.T, commID, userID, body string) *http.Request {
+	req, err := http.NewRequest("PUT", "/api/v1/community/"+commID+"/slug", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(api.WithUserID(req.Context(), userID))
+	return mux.SetURLVars(req, map[string]string{"community_id": commID})
+}
+
+func TestCommunity_UpdateCommunitySlugHandlerNotOwner(t *testing.T) {
+	commID := "608cafe595eb9dc05379b7f4"
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(&models.Community{ID: commID, Details: models.CommunityDetails{OwnerID: "owner"}}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db}.UpdateCommunitySlugHandler).ServeHTTP(rr, newSlugRequest(t, commID, "someone-else", `{"slug": "lspd-rp"}`))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCommunity_UpdateCommunitySlugHandlerReserved(t *testing.T) {
+	db := &mocks.CommunityDatabase{}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db}.UpdateCommunitySlugHandler).ServeHTTP(rr, newSlugRequest(t, "608cafe595eb9dc05379b7f4", "owner", `{"slug": "Admin"}`))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	db.AssertNotCalled(t, "FindOne", mock.Anything, mock.Anything)
+}
+
+func TestCommunity_UpdateCommunitySlugHandlerTooSoon(t *testing.T) {
+	commID := "608cafe595eb9dc05379b7f4"
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, mock.Anything).Return(&models.Community{ID: commID, Details: models.CommunityDetails{
+		OwnerID:       "owner",
+		Slug:          "old-slug",
+		SlugUpdatedAt: primitive.NewDateTimeFromTime(time.Now().Add(-24 * time.Hour)),
+	}}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db}.UpdateCommunitySlugHandler).ServeHTTP(rr, newSlugRequest(t, commID, "owner", `{"slug": "new-slug"}`))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	db.AssertNotCalled(t, "UpdateOne", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCommunity_UpdateCommunitySlugHandlerTaken(t *testing.T) {
+	commID := "608cafe595eb9dc05379b7f4"
+	cID, _ := primitive.ObjectIDFromHex(commID)
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, bson.M{"_id": cID}).Return(&models.Community{ID: commID, Details: models.CommunityDetails{OwnerID: "owner", Slug: "old-slug"}}, nil)
+	db.On("FindOne", mock.Anything, slugFilter("new-slug")).Return(&models.Community{ID: "another-community"}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db}.UpdateCommunitySlugHandler).ServeHTTP(rr, newSlugRequest(t, commID, "owner", `{"slug": "new-slug"}`))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestCommunity_UpdateCommunitySlugHandlerTakenConcurrently(t *testing.T) {
+	commID := "608cafe595eb9dc05379b7f4"
+	cID, _ := primitive.ObjectIDFromHex(commID)
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, bson.M{"_id": cID}).Return(&models.Community{ID: commID, Details: models.CommunityDetails{OwnerID: "owner", Slug: "old-slug"}}, nil)
+	db.On("FindOne", mock.Anything, slugFilter("new-slug")).Return(nil, mongo.ErrNoDocuments)
+	db.On("UpdateOne", mock.Anything, bson.M{"_id": cID}, mock.Anything).Return(nil, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}})
+	auditDB := &mocks.AuditLogDatabase{}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db, AuditLogDB: auditDB}.UpdateCommunitySlugHandler).ServeHTTP(rr, newSlugRequest(t, commID, "owner", `{"slug": "new-slug"}`))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "slug is already taken")
+	auditDB.AssertNotCalled(t, "InsertOne", mock.Anything, mock.Anything)
+}
+
+func TestCommunity_UpdateCommunitySlugHandlerSuccess(t *testing.T) {
+	commID := "608cafe595eb9dc05379b7f4"
+	cID, _ := primitive.ObjectIDFromHex(commID)
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, bson.M{"_id": cID}).Return(&models.Community{ID: commID, Details: models.CommunityDetails{
+		OwnerID:       "owner",
+		Slug:          "old-slug",
+		SlugUpdatedAt: primitive.NewDateTimeFromTime(time.Now().Add(-31 * 24 * time.Hour)),
+	}}, nil)
+	db.On("FindOne", mock.Anything, slugFilter("new-slug")).Return(nil, mongo.ErrNoDocuments)
+	db.On("UpdateOne", mock.Anything, bson.M{"_id": cID}, mock.MatchedBy(func(update bson.M) bool {
+		return update["$addToSet"].(bson.M)["community.previousSlugs"] == "old-slug" &&
+			update["$set"].(bson.M)["community.slug"] == "new-slug"
+	})).Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db}.UpdateCommunitySlugHandler).ServeHTTP(rr, newSlugRequest(t, commID, "owner", `{"slug": "New Slug"}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.Community
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "new-slug", got.Details.Slug)
+	assert.Equal(t, []string{"old-slug"}, got.Details.PreviousSlugs)
+}
+
+func TestCommunity_CommunityHandlerBySlug(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/v1/community/LSPD-RP", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"community_id": "LSPD-RP"})
+
+	db := &mocks.CommunityDatabase{}
+	db.On("FindOne", mock.Anything, slugFilter("lspd-rp")).Return(&models.Community{ID: "608cafe595eb9dc05379b7f4", Details: models.CommunityDetails{Slug: "lspd-rp"}}, nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Community{DB: db}.CommunityHandler).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.Community
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "608cafe595eb9dc05379b7f4", got.ID)
+}
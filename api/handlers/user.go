@@ -1,20 +1,25 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 
+	"github.com/linesmerrill/police-cad-api/api"
 	"github.com/linesmerrill/police-cad-api/config"
 	"github.com/linesmerrill/police-cad-api/databases"
 	"github.com/linesmerrill/police-cad-api/models"
 )
 
+// maxUsersBatch is the most IDs a single batch lookup accepts
+const maxUsersBatch = 200
+
 type User struct {
 	DB databases.UserDatabase
 }
@@ -25,25 +30,22 @@ func (u User) UserHandler(w http.ResponseWriter, r *http.Request) {
 
 	zap.S().Debugf("user_id: %v", commID)
 
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
 	cID, err := primitive.ObjectIDFromHex(commID)
 	if err != nil {
 		config.ErrorStatus("failed to get objectID from Hex", http.StatusBadRequest, w, err)
 		return
 	}
 
-	dbResp, err := u.DB.FindOne(context.Background(), bson.M{"_id": cID})
+	dbResp, err := u.DB.FindOne(ctx, bson.M{"_id": cID})
 	if err != nil {
 		config.ErrorStatus("failed to get user by ID", http.StatusNotFound, w, err)
 		return
 	}
 
-	b, err := json.Marshal(dbResp)
-	if err != nil {
-		config.ErrorStatus("failed to marshal response", http.StatusInternalServerError, w, err)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
-	w.Write(b)
+	respondJSON(w, http.StatusOK, dbResp)
 }
 
 // UsersFindAllHandler runs a mongo find{} query to find all
@@ -52,7 +54,10 @@ func (u User) UsersFindAllHandler(w http.ResponseWriter, r *http.Request) {
 
 	zap.S().Debugf("active_community_id: %v", commID)
 
-	dbResp, err := u.DB.Find(context.Background(), bson.M{"user.activeCommunity": commID})
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	dbResp, err := u.DB.Find(ctx, bson.M{"user.activeCommunity": commID})
 	if err != nil {
 		config.ErrorStatus("failed to get user by ID", http.StatusNotFound, w, err)
 		return
@@ -62,11 +67,66 @@ func (u User) UsersFindAllHandler(w http.ResponseWriter, r *http.Request) {
 	if len(dbResp) == 0 {
 		dbResp = []models.User{}
 	}
-	b, err := json.Marshal(dbResp)
-	if err != nil {
-		config.ErrorStatus("failed to marshal response", http.StatusInternalServerError, w, err)
+	respondJSON(w, http.StatusOK, dbResp)
+}
+
+// usersBatchRequest is the body accepted by UsersBatchHandler
+type usersBatchRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// UsersBatchHandler returns the public profile of every requested user with a single query.
+// Users come back in the order they were requested, IDs that are invalid or do not match a
+// user are listed in missing instead of failing the request.
+func (u User) UsersBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var req usersBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		config.ErrorStatus("failed to decode request body", http.StatusBadRequest, w, err)
+		return
+	}
+	if len(req.IDs) > maxUsersBatch {
+		config.ErrorStatus("too many ids", http.StatusBadRequest, w, fmt.Errorf("at most %d ids can be requested, got %d", maxUsersBatch, len(req.IDs)))
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write(b)
+
+	zap.S().Debugf("ids: %v", len(req.IDs))
+
+	ctx, cancel := api.WithQueryTimeout(r.Context())
+	defer cancel()
+
+	oIDs := make([]primitive.ObjectID, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		if oID, err := primitive.ObjectIDFromHex(id); err == nil {
+			oIDs = append(oIDs, oID)
+		}
+	}
+	found := map[string]models.User{}
+	if len(oIDs) > 0 {
+		// only the fields of UserLite are read, the rest of a user document is never sent
+		opts := options.Find().SetProjection(bson.M{"_id": 1, "user.username": 1, "user.callSign": 1, "user.isOnline": 1})
+		dbResp, err := u.DB.Find(ctx, bson.M{"_id": bson.M{"$in": oIDs}}, opts)
+		if err != nil {
+			config.ErrorStatus("failed to get users", http.StatusInternalServerError, w, err)
+			return
+		}
+		for _, user := range dbResp {
+			found[user.ID] = user
+		}
+	}
+
+	resp := models.UsersBatchResponse{Users: []models.UserLite{}, Missing: []string{}}
+	for _, id := range req.IDs {
+		user, ok := found[id]
+		if !ok {
+			resp.Missing = append(resp.Missing, id)
+			continue
+		}
+		resp.Users = append(resp.Users, models.UserLite{
+			ID:       user.ID,
+			Username: user.Details.Username,
+			CallSign: user.Details.CallSign,
+			IsOnline: user.Details.IsOnline,
+		})
+	}
+	respondJSON(w, http.StatusOK, resp)
 }
@@ -1,15 +1,19 @@
 package handlers_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/linesmerrill/police-cad-api/api/handlers"
 	"github.com/linesmerrill/police-cad-api/databases"
@@ -53,6 +57,18 @@ func (_m *MockDatabaseHelper) Collection(name string) databases.CollectionHelper
 	return r0
 }
 
+// ListCollectionNames provides a mock function.
+func (_m *MockDatabaseHelper) ListCollectionNames(ctx context.Context, filter interface{}) ([]string, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []string
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]string)
+	}
+
+	return r0, ret.Error(1)
+}
+
 func TestUser_UserHandlerInvalidID(t *testing.T) {
 	req, err := http.NewRequest("GET", "/api/v1/user/asdf", nil)
 	if err != nil {
@@ -484,3 +500,59 @@ func TestUser_UsersFindAllHandlerEmptyResponse(t *testing.T) {
 		t.Errorf("handler returned unexpected body: \ngot: %v \nwant: %v", rr.Body.String(), expected)
 	}
 }
+
+func usersBatchRequest(body string) *http.Request {
+	req, _ := http.NewRequest("POST", "/api/v1/users/batch", strings.NewReader(body))
+	return req
+}
+
+func TestUser_UsersBatchHandler(t *testing.T) {
+	db := &mocks.UserDatabase{}
+	db.On("Find", mock.Anything, mock.Anything, mock.Anything).Return([]models.User{
+		{ID: "608cafe595eb9dc05379b7f2", Details: models.UserDetails{Username: "asmith", Email: "asmith@example.com"}},
+		{ID: "608cafe595eb9dc05379b7f1", Details: models.UserDetails{Username: "jdoe", CallSign: "1-ADAM-12", Password: "hash", IsOnline: true}},
+	}, nil)
+	u := handlers.User{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(u.UsersBatchHandler).ServeHTTP(rr, usersBatchRequest(`{"ids": ["608cafe595eb9dc05379b7f1", "not-an-id", "608cafe595eb9dc05379b7f9", "608cafe595eb9dc05379b7f2"]}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "asmith@example.com")
+	assert.NotContains(t, rr.Body.String(), "hash")
+	var got models.UsersBatchResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, []models.UserLite{
+		{ID: "608cafe595eb9dc05379b7f1", Username: "jdoe", CallSign: "1-ADAM-12", IsOnline: true},
+		{ID: "608cafe595eb9dc05379b7f2", Username: "asmith"},
+	}, got.Users)
+	assert.Equal(t, []string{"not-an-id", "608cafe595eb9dc05379b7f9"}, got.Missing)
+	db.AssertNumberOfCalls(t, "Find", 1)
+	opts := db.Calls[0].Arguments.Get(2).(*options.FindOptions)
+	assert.Equal(t, bson.M{"_id": 1, "user.username": 1, "user.callSign": 1, "user.isOnline": 1}, opts.Projection)
+}
+
+func TestUser_UsersBatchHandlerOnlyInvalidIDs(t *testing.T) {
+	db := &mocks.UserDatabase{}
+	u := handlers.User{DB: db}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(u.UsersBatchHandler).ServeHTTP(rr, usersBatchRequest(`{"ids": ["not-an-id"]}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"users":[],"missing":["not-an-id"]}`, rr.Body.String())
+	db.AssertNotCalled(t, "Find", mock.Anything, mock.Anything)
+}
+
+func TestUser_UsersBatchHandlerTooManyIDs(t *testing.T) {
+	ids := make([]string, 201)
+	for i := range ids {
+		ids[i] = `"608cafe595eb9dc05379b7f1"`
+	}
+	u := handlers.User{DB: &mocks.UserDatabase{}}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(u.UsersBatchHandler).ServeHTTP(rr, usersBatchRequest(`{"ids": [`+strings.Join(ids, ",")+`]}`))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
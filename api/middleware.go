@@ -2,11 +2,13 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/form3tech-oss/jwt-go"
 	"go.uber.org/zap"
@@ -16,8 +18,104 @@ type key int
 
 const (
 	keyPrincipalID key = iota
+	keyUserID
 )
 
+// ProxySecretHeader carries the secret shared with the frontend proxy. Only requests that
+// present it may identify their user with the X-User-ID header.
+const ProxySecretHeader = "X-Proxy-Secret"
+
+var (
+	jwtSecret    string
+	proxySecret  string
+	adminUserIDs = map[string]bool{}
+)
+
+// SetJWTSecret sets the secret tokens are signed with. It is called once at startup with
+// SECRET_KEY, an empty value keeps reading SECRET_KEY from the environment.
+func SetJWTSecret(secret string) {
+	if secret != "" {
+		jwtSecret = secret
+	}
+}
+
+// SetProxySecret sets the secret the frontend proxy sends in ProxySecretHeader. While it is
+// empty the X-User-ID header is never trusted and users can only be identified by their token.
+func SetProxySecret(secret string) {
+	proxySecret = secret
+}
+
+// SetAdminUserIDs sets the users allowed through Admin. Nobody is an admin while it is empty.
+func SetAdminUserIDs(ids []string) {
+	admins := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		admins[id] = true
+	}
+	adminUserIDs = admins
+}
+
+func signingKey() []byte {
+	if jwtSecret != "" {
+		return []byte(jwtSecret)
+	}
+	return []byte(os.Getenv("SECRET_KEY"))
+}
+
+// NewToken signs an HS256 token for userID that expires after ttl, for tests and tooling
+func NewToken(userID string, ttl time.Duration) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId": userID,
+		"exp":    time.Now().Add(ttl).Unix(),
+	}).SignedString(signingKey())
+}
+
+// UserIDFromContext returns the ID of the user the request's token was issued to, or "" when
+// the token does not name one
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(keyUserID).(string)
+	return userID
+}
+
+// ProxyHeaderTrusted reports whether the request came through the frontend proxy, so its
+// X-User-ID header can be believed. Nothing is trusted until a proxy secret is configured.
+func ProxyHeaderTrusted(r *http.Request) bool {
+	if proxySecret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(ProxySecretHeader)), []byte(proxySecret)) == 1
+}
+
+// claimsUserID reads the user ID from the userId claim, falling back to the standard sub claim
+func claimsUserID(claims jwt.MapClaims) string {
+	if userID, ok := claims["userId"].(string); ok && userID != "" {
+		return userID
+	}
+	userID, _ := claims["sub"].(string)
+	return userID
+}
+
+// WithUserID returns a context naming userID as the user making the request, as a valid token
+// would, for tests and tooling
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, keyUserID, userID)
+}
+
+// withClaims stores the token claims and the user they name on the request context
+func withClaims(r *http.Request, claims jwt.MapClaims) *http.Request {
+	ctx := context.WithValue(r.Context(), keyPrincipalID, claims)
+	ctx = context.WithValue(ctx, keyUserID, claimsUserID(claims))
+	return r.WithContext(ctx)
+}
+
+func parseToken(jwtToken string) (*jwt.Token, error) {
+	return jwt.Parse(jwtToken, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf(`{"error": "unexpected signing method, %v"}`, token.Header["alg"])
+		}
+		return signingKey(), nil
+	})
+}
+
 // Middleware adds some basic header authentication around accessing the routes
 func Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -37,27 +135,24 @@ func Middleware(next http.Handler) http.Handler {
 			return
 		}
 		jwtToken := authHeader[1]
-		token, err := jwt.Parse(jwtToken, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf(`{"error": "unexpected signing method, %v"}`, token.Header["alg"])
-			}
-			return []byte(os.Getenv("SECRET_KEY")), nil
-		})
+		token, err := parseToken(jwtToken)
 		if err != nil {
 			zap.S().With("error", err).Errorw("failed to parse token",
 				"url", r.URL,
 				"token", jwtToken,
 			)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf(`{"error": "failed to parse token, %v"}`, err)))
+			// whether the token is expired, signed with another key or not a token at all, the
+			// client can not be let in with it
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(fmt.Sprintf(`{"error": "invalid token, %v"}`, err)))
 			return
 		}
 
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			ctx := context.WithValue(r.Context(), keyPrincipalID, claims)
 			// Access context values in handlers like this
 			// props, _ := r.Context().Value("props").(jwt.MapClaims)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			// or the user the token was issued to with UserIDFromContext
+			next.ServeHTTP(w, withClaims(r, claims))
 		} else {
 			zap.S().Errorw("unauthorized",
 				"url", r.URL,
@@ -69,3 +164,37 @@ func Middleware(next http.Handler) http.Handler {
 		}
 	})
 }
+
+// Public marks a route as reachable without a token. A valid bearer token is still read, so
+// the handler knows who is calling when the client is signed in, but a missing or bad one is
+// never rejected.
+func Public(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if jwtToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); jwtToken != r.Header.Get("Authorization") {
+			if token, err := parseToken(jwtToken); err == nil && token.Valid {
+				if claims, ok := token.Claims.(jwt.MapClaims); ok {
+					r = withClaims(r, claims)
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Admin authenticates the request like Middleware and then only lets the users set with
+// SetAdminUserIDs through, everyone else gets a 403
+func Admin(next http.Handler) http.Handler {
+	return Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := UserIDFromContext(r.Context())
+		if userID == "" || !adminUserIDs[userID] {
+			zap.S().Warnw("admin access denied",
+				"url", r.URL,
+				"user_id", userID,
+			)
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "admin access required"}`))
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,175 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linesmerrill/police-cad-api/api"
+)
+
+const testUserID = "608cafd695eb9dc05379b7f3"
+
+// userIDHandler writes back the user the middleware put on the context
+var userIDHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(api.UserIDFromContext(r.Context())))
+})
+
+func authRequest(token string) *http.Request {
+	req, _ := http.NewRequest("GET", "/api/v1/community/1234", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestMain(m *testing.M) {
+	os.Setenv("SECRET_KEY", "test-secret")
+	code := m.Run()
+	os.Unsetenv("SECRET_KEY")
+	os.Exit(code)
+}
+
+func TestMiddleware_ValidToken(t *testing.T) {
+	token, err := api.NewToken(testUserID, time.Hour)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	api.Middleware(userIDHandler).ServeHTTP(rr, authRequest(token))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, testUserID, rr.Body.String())
+}
+
+func TestMiddleware_ExpiredToken(t *testing.T) {
+	token, err := api.NewToken(testUserID, -time.Minute)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	api.Middleware(userIDHandler).ServeHTTP(rr, authRequest(token))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Token is expired")
+}
+
+func TestMiddleware_BadSignature(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"userId": testUserID}).SignedString([]byte("another-secret"))
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	api.Middleware(userIDHandler).ServeHTTP(rr, authRequest(token))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "signature is invalid")
+}
+
+func TestMiddleware_OtherSigningMethod(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{"userId": testUserID}).SignedString([]byte("test-secret"))
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	api.Middleware(userIDHandler).ServeHTTP(rr, authRequest(token))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "unexpected signing method")
+}
+
+func TestMiddleware_MalformedToken(t *testing.T) {
+	for name, token := range map[string]string{
+		"not a jwt":       "asdfasdf",
+		"bad segments":    "a.b.c",
+		"bad json header": "eyJhbGciOiJIUzI1NiI.eyJ1c2VySWQiOiIxIn0.sig",
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			api.Middleware(userIDHandler).ServeHTTP(rr, authRequest(token))
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			assert.Contains(t, rr.Body.String(), "invalid token")
+		})
+	}
+}
+
+func TestMiddleware_SubClaim(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": testUserID}).SignedString([]byte("test-secret"))
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	api.Middleware(userIDHandler).ServeHTTP(rr, authRequest(token))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, testUserID, rr.Body.String())
+}
+
+func TestPublic(t *testing.T) {
+	token, err := api.NewToken(testUserID, time.Hour)
+	assert.NoError(t, err)
+
+	for name, tt := range map[string]struct {
+		token string
+		want  string
+	}{
+		"no token":      {"", ""},
+		"valid token":   {token, testUserID},
+		"invalid token": {"asdfasdf", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			api.Public(userIDHandler).ServeHTTP(rr, authRequest(tt.token))
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tt.want, rr.Body.String())
+		})
+	}
+}
+
+func TestProxyHeaderTrusted(t *testing.T) {
+	defer api.SetProxySecret("")
+	req := authRequest("")
+
+	// without a configured secret nothing is trusted, even a request that sends an empty one
+	api.SetProxySecret("")
+	assert.False(t, api.ProxyHeaderTrusted(req))
+	req.Header.Set(api.ProxySecretHeader, "")
+	assert.False(t, api.ProxyHeaderTrusted(req))
+
+	api.SetProxySecret("proxy-secret")
+	assert.False(t, api.ProxyHeaderTrusted(req))
+	req.Header.Set(api.ProxySecretHeader, "wrong")
+	assert.False(t, api.ProxyHeaderTrusted(req))
+	req.Header.Set(api.ProxySecretHeader, "proxy-secret")
+	assert.True(t, api.ProxyHeaderTrusted(req))
+}
+
+func TestAdmin(t *testing.T) {
+	defer api.SetAdminUserIDs(nil)
+	api.SetAdminUserIDs([]string{testUserID})
+	adminToken, _ := api.NewToken(testUserID, time.Hour)
+	userToken, _ := api.NewToken("608cafd695eb9dc05379b7f4", time.Hour)
+
+	for name, tt := range map[string]struct {
+		token string
+		want  int
+	}{
+		"admin":     {adminToken, http.StatusOK},
+		"non-admin": {userToken, http.StatusForbidden},
+		"no token":  {"", http.StatusUnauthorized},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			api.Admin(userIDHandler).ServeHTTP(rr, authRequest(tt.token))
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+
+	// nobody is an admin until the list is configured
+	api.SetAdminUserIDs(nil)
+	rr := httptest.NewRecorder()
+	api.Admin(userIDHandler).ServeHTTP(rr, authRequest(adminToken))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
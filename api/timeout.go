@@ -0,0 +1,26 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultQueryTimeout is used until SetQueryTimeout is called with the configured value
+const DefaultQueryTimeout = 10 * time.Second
+
+var queryTimeout = DefaultQueryTimeout
+
+// SetQueryTimeout sets the timeout WithQueryTimeout applies. It is called once at startup
+// with DB_QUERY_TIMEOUT, a zero or negative value keeps the current timeout.
+func SetQueryTimeout(d time.Duration) {
+	if d > 0 {
+		queryTimeout = d
+	}
+}
+
+// WithQueryTimeout derives the context database queries should run with from a request
+// context. The query is cancelled when the client goes away or the timeout passes, whichever
+// happens first, so a stuck query can not hold on to a pooled connection.
+func WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, queryTimeout)
+}
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/linesmerrill/police-cad-api/metrics"
 	"github.com/linesmerrill/police-cad-api/models"
 )
 
@@ -17,6 +21,32 @@ type Config struct {
 	DatabaseName string
 	BaseURL      string
 	Port         string
+	// MaintenanceMode starts the api rejecting writes, regardless of the stored maintenance state
+	MaintenanceMode bool
+	// MaintenancePollInterval is how often each instance reloads the stored maintenance state
+	MaintenancePollInterval time.Duration
+	// PresenceTimeout is how long a user stays online after their last heartbeat
+	PresenceTimeout time.Duration
+	// PresenceSweepInterval is how often users without a recent heartbeat are marked offline
+	PresenceSweepInterval time.Duration
+	// DBQueryTimeout bounds how long a single request can spend on database queries
+	DBQueryTimeout time.Duration
+	// EnsureIndexes creates the indexes the queries rely on at startup
+	EnsureIndexes bool
+	// JWTSecret is the HS256 secret bearer tokens are signed with
+	JWTSecret string
+	// ProxySecret is shared with the frontend proxy, only requests carrying it may set X-User-ID
+	ProxySecret string
+	// CORSAllowedOrigins are the browser origins allowed to call the api, CORS is off when empty
+	CORSAllowedOrigins []string
+	// CORSAllowedMethods are the methods allowed in cross-origin requests
+	CORSAllowedMethods []string
+	// CORSAllowCredentials lets browsers send cookies and auth headers cross-origin
+	CORSAllowCredentials bool
+	// AdminUserIDs are the users allowed to call the /admin routes
+	AdminUserIDs []string
+	// StrictConfig stops the api from starting when Validate fails, otherwise it only warns
+	StrictConfig bool
 }
 
 // New sets up all config related services
@@ -36,6 +66,20 @@ func New() *Config {
 		DatabaseName: os.Getenv("DB_NAME"),
 		BaseURL:      os.Getenv("BASE_URL"),
 		Port:         os.Getenv("PORT"),
+		JWTSecret:    os.Getenv("SECRET_KEY"),
+		ProxySecret:  os.Getenv("PROXY_SECRET"),
+
+		EnsureIndexes:           getEnvBool("ENSURE_INDEXES", true),
+		MaintenanceMode:         getEnvBool("MAINTENANCE_MODE", false),
+		MaintenancePollInterval: getEnvDuration("MAINTENANCE_POLL_INTERVAL", 30*time.Second),
+		PresenceTimeout:         getEnvDuration("PRESENCE_TIMEOUT", 5*time.Minute),
+		PresenceSweepInterval:   getEnvDuration("PRESENCE_SWEEP_INTERVAL", time.Minute),
+		DBQueryTimeout:          getEnvDuration("DB_QUERY_TIMEOUT", 10*time.Second),
+		CORSAllowedOrigins:      getEnvList("CORS_ALLOWED_ORIGINS", nil),
+		CORSAllowedMethods:      getEnvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		CORSAllowCredentials:    getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+		AdminUserIDs:            getEnvList("ADMIN_USER_IDS", nil),
+		StrictConfig:            getEnvBool("STRICT_CONFIG", false),
 	}
 
 }
@@ -44,12 +88,55 @@ func New() *Config {
 // give message, status code and err
 func ErrorStatus(message string, httpStatusCode int, w http.ResponseWriter, err error) {
 	zap.S().With(err).Error(message)
+	metrics.ObserveError(err)
 	w.WriteHeader(httpStatusCode)
 	b, _ := json.Marshal(models.ErrorMessageResponse{Response: models.MessageError{Message: message, Error: err.Error()}})
 	w.Write(b)
 	return
 }
 
+// getEnvBool parses a boolean env var, falling back to def when unset or invalid
+func getEnvBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		zap.S().With(err).Warnf("invalid value for %s, using default of %v", key, def)
+		return def
+	}
+	return b
+}
+
+// getEnvDuration parses a duration env var such as "30s", falling back to def when unset or invalid
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		zap.S().With(err).Warnf("invalid value for %s, using default of %v", key, def)
+		return def
+	}
+	return d
+}
+
+// getEnvList parses a comma separated env var, falling back to def when unset or empty
+func getEnvList(key string, def []string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	if len(list) == 0 {
+		return def
+	}
+	return list
+}
+
 // setLogger is a helper function to set the logger based on the environment
 func setLogger(env string) (*zap.Logger, error) {
 	if env == "production" {
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,6 +18,7 @@ func TestNew(t *testing.T) {
 	conf := New()
 
 	assert.NotEmpty(t, conf)
+	assert.Equal(t, 10*time.Second, conf.DBQueryTimeout)
 }
 
 func TestErrorStatus(t *testing.T) {
@@ -48,3 +50,39 @@ func TestSetLoggerCannotFindEnv(t *testing.T) {
 	assert.Equal(t, err, fmt.Errorf("cannot find ENV var so defaulting to debug level logging"))
 	assert.True(t, l.Core().Enabled(0))
 }
+
+func TestGetEnvBool(t *testing.T) {
+	os.Setenv("TEST_BOOL", "true")
+	defer os.Unsetenv("TEST_BOOL")
+	assert.True(t, getEnvBool("TEST_BOOL", false))
+
+	os.Setenv("TEST_BOOL", "not-a-bool")
+	assert.False(t, getEnvBool("TEST_BOOL", false))
+
+	os.Unsetenv("TEST_BOOL")
+	assert.True(t, getEnvBool("TEST_BOOL", true))
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	os.Setenv("TEST_DURATION", "5s")
+	defer os.Unsetenv("TEST_DURATION")
+	assert.Equal(t, 5*time.Second, getEnvDuration("TEST_DURATION", time.Minute))
+
+	os.Setenv("TEST_DURATION", "five")
+	assert.Equal(t, time.Minute, getEnvDuration("TEST_DURATION", time.Minute))
+
+	os.Unsetenv("TEST_DURATION")
+	assert.Equal(t, time.Minute, getEnvDuration("TEST_DURATION", time.Minute))
+}
+
+func TestGetEnvList(t *testing.T) {
+	os.Setenv("TEST_LIST", " https://a.com, ,https://*.b.com ")
+	defer os.Unsetenv("TEST_LIST")
+	assert.Equal(t, []string{"https://a.com", "https://*.b.com"}, getEnvList("TEST_LIST", nil))
+
+	os.Setenv("TEST_LIST", " , ")
+	assert.Equal(t, []string{"GET"}, getEnvList("TEST_LIST", []string{"GET"}))
+
+	os.Unsetenv("TEST_LIST")
+	assert.Nil(t, getEnvList("TEST_LIST", nil))
+}
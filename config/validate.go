@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// boolSettings and durationSettings are the optional env vars that must parse as their type.
+// New falls back to the default for an invalid value, Validate reports it.
+var (
+	boolSettings     = []string{"ENSURE_INDEXES", "MAINTENANCE_MODE", "CORS_ALLOW_CREDENTIALS", "STRICT_CONFIG"}
+	durationSettings = []string{"MAINTENANCE_POLL_INTERVAL", "PRESENCE_TIMEOUT", "PRESENCE_SWEEP_INTERVAL", "DB_QUERY_TIMEOUT"}
+)
+
+// redacted replaces secrets when the config is logged
+const redacted = "[redacted]"
+
+// ValidationError lists every problem Validate found with the config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the settings the api cannot run without are present and that every typed
+// setting parses, so a bad deploy fails at startup instead of on the first request.
+func (c Config) Validate() error {
+	var problems []string
+	var missing []string
+	for _, setting := range []struct{ key, value string }{
+		{"DB_URI", c.URL},
+		{"DB_NAME", c.DatabaseName},
+		{"PORT", c.Port},
+		{"SECRET_KEY", c.JWTSecret},
+	} {
+		if setting.value == "" {
+			missing = append(missing, setting.key)
+		}
+	}
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("missing required env vars: %s", strings.Join(missing, ", ")))
+	}
+
+	if c.URL != "" && !strings.HasPrefix(c.URL, "mongodb://") && !strings.HasPrefix(c.URL, "mongodb+srv://") {
+		problems = append(problems, "DB_URI must be a mongodb:// or mongodb+srv:// connection string")
+	}
+	if c.Port != "" {
+		if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("PORT must be a number between 1 and 65535, got '%s'", c.Port))
+		}
+	}
+
+	for _, key := range boolSettings {
+		if v := os.Getenv(key); v != "" {
+			if _, err := strconv.ParseBool(v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be true or false, got '%s'", key, v))
+			}
+		}
+	}
+	for _, key := range durationSettings {
+		if v := os.Getenv(key); v != "" {
+			if _, err := time.ParseDuration(v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be a duration such as 10s, got '%s'", key, v))
+			}
+		}
+	}
+	for _, setting := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"MAINTENANCE_POLL_INTERVAL", c.MaintenancePollInterval},
+		{"PRESENCE_TIMEOUT", c.PresenceTimeout},
+		{"PRESENCE_SWEEP_INTERVAL", c.PresenceSweepInterval},
+		{"DB_QUERY_TIMEOUT", c.DBQueryTimeout},
+	} {
+		if setting.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be greater than 0, got %v", setting.key, setting.value))
+		}
+	}
+
+	if len(problems) > 0 {
+		return ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Redacted returns the effective config for logging, with secrets and database credentials
+// hidden. Unset secrets are left empty so it is clear they are missing.
+func (c Config) Redacted() map[string]interface{} {
+	return map[string]interface{}{
+		"DB_URI":                    redactURI(c.URL),
+		"DB_NAME":                   c.DatabaseName,
+		"BASE_URL":                  c.BaseURL,
+		"PORT":                      c.Port,
+		"SECRET_KEY":                redactSecret(c.JWTSecret),
+		"PROXY_SECRET":              redactSecret(c.ProxySecret),
+		"MAINTENANCE_MODE":          c.MaintenanceMode,
+		"MAINTENANCE_POLL_INTERVAL": c.MaintenancePollInterval.String(),
+		"PRESENCE_TIMEOUT":          c.PresenceTimeout.String(),
+		"PRESENCE_SWEEP_INTERVAL":   c.PresenceSweepInterval.String(),
+		"DB_QUERY_TIMEOUT":          c.DBQueryTimeout.String(),
+		"ENSURE_INDEXES":            c.EnsureIndexes,
+		"CORS_ALLOWED_ORIGINS":      c.CORSAllowedOrigins,
+		"CORS_ALLOWED_METHODS":      c.CORSAllowedMethods,
+		"CORS_ALLOW_CREDENTIALS":    c.CORSAllowCredentials,
+		"ADMIN_USER_IDS":            c.AdminUserIDs,
+		"STRICT_CONFIG":             c.StrictConfig,
+	}
+}
+
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURI hides the password of a connection string, a string that does not parse is
+// hidden entirely since it may still hold credentials
+func redactURI(uri string) string {
+	if uri == "" {
+		return ""
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
@@ -0,0 +1,59 @@
+package databases
+
+// go generate: mockery --name APIKeyDatabase
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const apiKeyName = "apiKeys"
+
+// APIKeyDatabase contains the methods to use with the api key database
+type APIKeyDatabase interface {
+	FindOne(ctx context.Context, filter interface{}) (*models.APIKey, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.APIKey, error)
+	InsertOne(ctx context.Context, document interface{}) (*mongo.InsertOneResult, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+}
+
+type apiKeyDatabase struct {
+	db DatabaseHelper
+}
+
+// NewAPIKeyDatabase initializes a new instance of api key database with the provided db connection
+func NewAPIKeyDatabase(db DatabaseHelper) APIKeyDatabase {
+	return &apiKeyDatabase{
+		db: db,
+	}
+}
+
+func (a *apiKeyDatabase) FindOne(ctx context.Context, filter interface{}) (*models.APIKey, error) {
+	apiKey := &models.APIKey{}
+	err := a.db.Collection(apiKeyName).FindOne(ctx, filter).Decode(&apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+func (a *apiKeyDatabase) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.APIKey, error) {
+	var apiKeys []models.APIKey
+	err := a.db.Collection(apiKeyName).Find(ctx, filter, opts...).Decode(&apiKeys)
+	if err != nil {
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+func (a *apiKeyDatabase) InsertOne(ctx context.Context, document interface{}) (*mongo.InsertOneResult, error) {
+	return a.db.Collection(apiKeyName).InsertOne(ctx, document)
+}
+
+func (a *apiKeyDatabase) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return a.db.Collection(apiKeyName).UpdateOne(ctx, filter, update, opts...)
+}
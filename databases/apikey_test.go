@@ -0,0 +1,211 @@
+package databases_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func TestNewAPIKeyDatabase(t *testing.T) {
+	_ = os.Setenv("DB_URI", "mongodb://127.0.0.1:27017")
+	_ = os.Setenv("DB_NAME", "test")
+	conf := config.New()
+
+	dbClient, err := databases.NewClient(conf)
+	assert.NoError(t, err)
+
+	db := databases.NewDatabase(conf, dbClient)
+
+	apiKeyDB := databases.NewAPIKeyDatabase(db)
+
+	assert.NotEmpty(t, apiKeyDB)
+}
+
+func TestAPIKeyDatabase_FindOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+	var srHelperErr databases.SingleResultHelper
+	var srHelperCorrect databases.SingleResultHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+	srHelperErr = &mocks.SingleResultHelper{}
+	srHelperCorrect = &mocks.SingleResultHelper{}
+
+	srHelperErr.(*mocks.SingleResultHelper).
+		On("Decode", mock.Anything).
+		Return(errors.New("mocked-error"))
+
+	srHelperCorrect.(*mocks.SingleResultHelper).
+		On("Decode", mock.Anything).
+		Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(0).(**models.APIKey)
+		(*arg).Name = "mocked-api-key"
+	})
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("FindOne", context.Background(), bson.M{"error": true}).
+		Return(srHelperErr)
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("FindOne", context.Background(), bson.M{"error": false}).
+		Return(srHelperCorrect)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "apiKeys").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	apiKeyDba := databases.NewAPIKeyDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	apiKey, err := apiKeyDba.FindOne(context.Background(), bson.M{"error": true})
+
+	assert.Empty(t, apiKey)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	apiKey, err = apiKeyDba.FindOne(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, &models.APIKey{Name: "mocked-api-key"}, apiKey)
+	assert.NoError(t, err)
+}
+
+func TestAPIKeyDatabase_Find(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+	var srHelperErr databases.CursorHelper
+	var srHelperCorrect databases.CursorHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+	srHelperErr = &mocks.CursorHelper{}
+	srHelperCorrect = &mocks.CursorHelper{}
+
+	srHelperErr.(*mocks.CursorHelper).
+		On("Decode", mock.Anything).
+		Return(errors.New("mocked-error"))
+
+	srHelperCorrect.(*mocks.CursorHelper).
+		On("Decode", mock.Anything).
+		Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(0).(*[]models.APIKey)
+		*arg = []models.APIKey{{Name: "mocked-api-key"}}
+	})
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("Find", context.Background(), bson.M{"error": true}).
+		Return(srHelperErr)
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("Find", context.Background(), bson.M{"error": false}).
+		Return(srHelperCorrect)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "apiKeys").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	apiKeyDba := databases.NewAPIKeyDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	apiKeys, err := apiKeyDba.Find(context.Background(), bson.M{"error": true})
+
+	assert.Empty(t, apiKeys)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	apiKeys, err = apiKeyDba.Find(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, []models.APIKey{{Name: "mocked-api-key"}}, apiKeys)
+	assert.NoError(t, err)
+}
+
+func TestAPIKeyDatabase_InsertOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("InsertOne", context.Background(), models.APIKey{Name: "error"}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("InsertOne", context.Background(), models.APIKey{Name: "correct"}).
+		Return(&mongo.InsertOneResult{InsertedID: "mocked-id"}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "apiKeys").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	apiKeyDba := databases.NewAPIKeyDatabase(dbHelper)
+
+	result, err := apiKeyDba.InsertOne(context.Background(), models.APIKey{Name: "error"})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	result, err = apiKeyDba.InsertOne(context.Background(), models.APIKey{Name: "correct"})
+
+	assert.Equal(t, &mongo.InsertOneResult{InsertedID: "mocked-id"}, result)
+	assert.NoError(t, err)
+}
+
+func TestAPIKeyDatabase_UpdateOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": true}, bson.M{}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": false}, bson.M{}).
+		Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "apiKeys").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	apiKeyDba := databases.NewAPIKeyDatabase(dbHelper)
+
+	result, err := apiKeyDba.UpdateOne(context.Background(), bson.M{"error": true}, bson.M{})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	result, err = apiKeyDba.UpdateOne(context.Background(), bson.M{"error": false}, bson.M{})
+
+	assert.Equal(t, &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, result)
+	assert.NoError(t, err)
+}
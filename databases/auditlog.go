@@ -0,0 +1,44 @@
+package databases
+
+// go generate: mockery --name AuditLogDatabase
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const auditLogName = "auditLog"
+
+// AuditLogDatabase contains the methods to use with the audit log database
+type AuditLogDatabase interface {
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.AuditLogEntry, error)
+	InsertOne(ctx context.Context, document interface{}) (*mongo.InsertOneResult, error)
+}
+
+type auditLogDatabase struct {
+	db DatabaseHelper
+}
+
+// NewAuditLogDatabase initializes a new instance of audit log database with the provided db connection
+func NewAuditLogDatabase(db DatabaseHelper) AuditLogDatabase {
+	return &auditLogDatabase{
+		db: db,
+	}
+}
+
+func (a *auditLogDatabase) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.AuditLogEntry, error) {
+	var entries []models.AuditLogEntry
+	err := a.db.Collection(auditLogName).Find(ctx, filter, opts...).Decode(&entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (a *auditLogDatabase) InsertOne(ctx context.Context, document interface{}) (*mongo.InsertOneResult, error) {
+	return a.db.Collection(auditLogName).InsertOne(ctx, document)
+}
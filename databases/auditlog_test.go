@@ -0,0 +1,122 @@
+package databases_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func TestNewAuditLogDatabase(t *testing.T) {
+	_ = os.Setenv("DB_URI", "mongodb://127.0.0.1:27017")
+	_ = os.Setenv("DB_NAME", "test")
+	conf := config.New()
+
+	dbClient, err := databases.NewClient(conf)
+	assert.NoError(t, err)
+
+	db := databases.NewDatabase(conf, dbClient)
+
+	auditLogDB := databases.NewAuditLogDatabase(db)
+
+	assert.NotEmpty(t, auditLogDB)
+}
+
+func TestAuditLogDatabase_Find(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+	var srHelperErr databases.CursorHelper
+	var srHelperCorrect databases.CursorHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+	srHelperErr = &mocks.CursorHelper{}
+	srHelperCorrect = &mocks.CursorHelper{}
+
+	srHelperErr.(*mocks.CursorHelper).
+		On("Decode", mock.Anything).
+		Return(errors.New("mocked-error"))
+
+	srHelperCorrect.(*mocks.CursorHelper).
+		On("Decode", mock.Anything).
+		Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(0).(*[]models.AuditLogEntry)
+		*arg = []models.AuditLogEntry{{Action: "mocked-action"}}
+	})
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("Find", context.Background(), bson.M{"error": true}).
+		Return(srHelperErr)
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("Find", context.Background(), bson.M{"error": false}).
+		Return(srHelperCorrect)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "auditLog").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	auditLogDba := databases.NewAuditLogDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	entries, err := auditLogDba.Find(context.Background(), bson.M{"error": true})
+
+	assert.Empty(t, entries)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	entries, err = auditLogDba.Find(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, []models.AuditLogEntry{{Action: "mocked-action"}}, entries)
+	assert.NoError(t, err)
+}
+
+func TestAuditLogDatabase_InsertOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("InsertOne", context.Background(), models.AuditLogEntry{Action: "error"}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("InsertOne", context.Background(), models.AuditLogEntry{Action: "correct"}).
+		Return(&mongo.InsertOneResult{InsertedID: "mocked-id"}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "auditLog").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	auditLogDba := databases.NewAuditLogDatabase(dbHelper)
+
+	result, err := auditLogDba.InsertOne(context.Background(), models.AuditLogEntry{Action: "error"})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	result, err = auditLogDba.InsertOne(context.Background(), models.AuditLogEntry{Action: "correct"})
+
+	assert.Equal(t, &mongo.InsertOneResult{InsertedID: "mocked-id"}, result)
+	assert.NoError(t, err)
+}
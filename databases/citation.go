@@ -0,0 +1,64 @@
+package databases
+
+// go generate: mockery --name CitationDatabase
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const citationName = "citations"
+
+// CitationDatabase contains the methods to use with the citation database
+type CitationDatabase interface {
+	FindOne(ctx context.Context, filter interface{}) (*models.Citation, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Citation, error)
+	InsertOne(ctx context.Context, document interface{}) (*mongo.InsertOneResult, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	DeleteMany(ctx context.Context, filter interface{}) (*mongo.DeleteResult, error)
+}
+
+type citationDatabase struct {
+	db DatabaseHelper
+}
+
+// NewCitationDatabase initializes a new instance of citation database with the provided db connection
+func NewCitationDatabase(db DatabaseHelper) CitationDatabase {
+	return &citationDatabase{
+		db: db,
+	}
+}
+
+func (c *citationDatabase) FindOne(ctx context.Context, filter interface{}) (*models.Citation, error) {
+	citation := &models.Citation{}
+	err := c.db.Collection(citationName).FindOne(ctx, filter).Decode(&citation)
+	if err != nil {
+		return nil, err
+	}
+	return citation, nil
+}
+
+func (c *citationDatabase) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Citation, error) {
+	var citations []models.Citation
+	err := c.db.Collection(citationName).Find(ctx, filter, opts...).Decode(&citations)
+	if err != nil {
+		return nil, err
+	}
+	return citations, nil
+}
+
+func (c *citationDatabase) InsertOne(ctx context.Context, document interface{}) (*mongo.InsertOneResult, error) {
+	return c.db.Collection(citationName).InsertOne(ctx, document)
+}
+
+func (c *citationDatabase) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.db.Collection(citationName).UpdateOne(ctx, filter, update, opts...)
+}
+
+func (c *citationDatabase) DeleteMany(ctx context.Context, filter interface{}) (*mongo.DeleteResult, error) {
+	return c.db.Collection(citationName).DeleteMany(ctx, filter)
+}
@@ -0,0 +1,250 @@
+package databases_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func TestNewCitationDatabase(t *testing.T) {
+	_ = os.Setenv("DB_URI", "mongodb://127.0.0.1:27017")
+	_ = os.Setenv("DB_NAME", "test")
+	conf := config.New()
+
+	dbClient, err := databases.NewClient(conf)
+	assert.NoError(t, err)
+
+	db := databases.NewDatabase(conf, dbClient)
+
+	citationDB := databases.NewCitationDatabase(db)
+
+	assert.NotEmpty(t, citationDB)
+}
+
+func TestCitationDatabase_FindOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+	var srHelperErr databases.SingleResultHelper
+	var srHelperCorrect databases.SingleResultHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+	srHelperErr = &mocks.SingleResultHelper{}
+	srHelperCorrect = &mocks.SingleResultHelper{}
+
+	srHelperErr.(*mocks.SingleResultHelper).
+		On("Decode", mock.Anything).
+		Return(errors.New("mocked-error"))
+
+	srHelperCorrect.(*mocks.SingleResultHelper).
+		On("Decode", mock.Anything).
+		Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(0).(**models.Citation)
+		(*arg).Name = "mocked-citation"
+	})
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("FindOne", context.Background(), bson.M{"error": true}).
+		Return(srHelperErr)
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("FindOne", context.Background(), bson.M{"error": false}).
+		Return(srHelperCorrect)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "citations").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	citationDba := databases.NewCitationDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	citation, err := citationDba.FindOne(context.Background(), bson.M{"error": true})
+
+	assert.Empty(t, citation)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	citation, err = citationDba.FindOne(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, &models.Citation{Name: "mocked-citation"}, citation)
+	assert.NoError(t, err)
+}
+
+func TestCitationDatabase_Find(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+	var srHelperErr databases.CursorHelper
+	var srHelperCorrect databases.CursorHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+	srHelperErr = &mocks.CursorHelper{}
+	srHelperCorrect = &mocks.CursorHelper{}
+
+	srHelperErr.(*mocks.CursorHelper).
+		On("Decode", mock.Anything).
+		Return(errors.New("mocked-error"))
+
+	srHelperCorrect.(*mocks.CursorHelper).
+		On("Decode", mock.Anything).
+		Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(0).(*[]models.Citation)
+		*arg = []models.Citation{{Name: "mocked-citation"}}
+	})
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("Find", context.Background(), bson.M{"error": true}).
+		Return(srHelperErr)
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("Find", context.Background(), bson.M{"error": false}).
+		Return(srHelperCorrect)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "citations").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	citationDba := databases.NewCitationDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	citations, err := citationDba.Find(context.Background(), bson.M{"error": true})
+
+	assert.Empty(t, citations)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	citations, err = citationDba.Find(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, []models.Citation{{Name: "mocked-citation"}}, citations)
+	assert.NoError(t, err)
+}
+
+func TestCitationDatabase_InsertOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("InsertOne", context.Background(), models.Citation{Name: "error"}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("InsertOne", context.Background(), models.Citation{Name: "correct"}).
+		Return(&mongo.InsertOneResult{InsertedID: "mocked-id"}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "citations").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	citationDba := databases.NewCitationDatabase(dbHelper)
+
+	result, err := citationDba.InsertOne(context.Background(), models.Citation{Name: "error"})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	result, err = citationDba.InsertOne(context.Background(), models.Citation{Name: "correct"})
+
+	assert.Equal(t, &mongo.InsertOneResult{InsertedID: "mocked-id"}, result)
+	assert.NoError(t, err)
+}
+
+func TestCitationDatabase_UpdateOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": true}, bson.M{}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": false}, bson.M{}).
+		Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "citations").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	citationDba := databases.NewCitationDatabase(dbHelper)
+
+	result, err := citationDba.UpdateOne(context.Background(), bson.M{"error": true}, bson.M{})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	result, err = citationDba.UpdateOne(context.Background(), bson.M{"error": false}, bson.M{})
+
+	assert.Equal(t, &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, result)
+	assert.NoError(t, err)
+}
+
+func TestCitationDatabase_DeleteMany(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("DeleteMany", context.Background(), bson.M{"error": true}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("DeleteMany", context.Background(), bson.M{"error": false}).
+		Return(&mongo.DeleteResult{DeletedCount: 2}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "citations").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	citationDba := databases.NewCitationDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	result, err := citationDba.DeleteMany(context.Background(), bson.M{"error": true})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	result, err = citationDba.DeleteMany(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, &mongo.DeleteResult{DeletedCount: 2}, result)
+	assert.NoError(t, err)
+}
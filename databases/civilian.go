@@ -6,6 +6,7 @@ import (
 	"context"
 
 	"github.com/linesmerrill/police-cad-api/models"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -15,6 +16,8 @@ const civilianName = "civilians"
 type CivilianDatabase interface {
 	FindOne(context.Context, interface{}, ...*options.FindOneOptions) (*models.Civilian, error)
 	Find(context.Context, interface{}, ...*options.FindOptions) ([]models.Civilian, error)
+	UpdateOne(context.Context, interface{}, interface{}, ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	CountDocuments(context.Context, interface{}, ...*options.CountOptions) (int64, error)
 }
 
 type civilianDatabase struct {
@@ -45,3 +48,11 @@ func (c *civilianDatabase) Find(ctx context.Context, filter interface{}, opts ..
 	}
 	return civilians, nil
 }
+
+func (c *civilianDatabase) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.db.Collection(civilianName).UpdateOne(ctx, filter, update, opts...)
+}
+
+func (c *civilianDatabase) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	return c.db.Collection(civilianName).CountDocuments(ctx, filter, opts...)
+}
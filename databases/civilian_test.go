@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/linesmerrill/police-cad-api/config"
 	"github.com/linesmerrill/police-cad-api/databases"
@@ -138,3 +139,81 @@ func TestCivilianDatabase_Find(t *testing.T) {
 	assert.Equal(t, []models.Civilian{{ID: "mocked-user"}}, user)
 	assert.NoError(t, err)
 }
+
+func TestCivilianDatabase_UpdateOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": true}, bson.M{}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": false}, bson.M{}).
+		Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "civilians").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	civilianDba := databases.NewCivilianDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	result, err := civilianDba.UpdateOne(context.Background(), bson.M{"error": true}, bson.M{})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	result, err = civilianDba.UpdateOne(context.Background(), bson.M{"error": false}, bson.M{})
+
+	assert.Equal(t, &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, result)
+	assert.NoError(t, err)
+}
+
+func TestCivilianDatabase_CountDocuments(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("CountDocuments", context.Background(), bson.M{"error": true}).
+		Return(int64(0), errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("CountDocuments", context.Background(), bson.M{"error": false}).
+		Return(int64(3), nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "civilians").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	civilianDba := databases.NewCivilianDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	count, err := civilianDba.CountDocuments(context.Background(), bson.M{"error": true})
+
+	assert.Zero(t, count)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	count, err = civilianDba.CountDocuments(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, int64(3), count)
+	assert.NoError(t, err)
+}
@@ -5,6 +5,9 @@ package databases
 import (
 	"context"
 
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
 	"github.com/linesmerrill/police-cad-api/models"
 )
 
@@ -13,7 +16,9 @@ const collectionName = "communities"
 // CommunityDatabase contains the methods to use with the community database
 type CommunityDatabase interface {
 	FindOne(ctx context.Context, filter interface{}) (*models.Community, error)
-	Find(ctx context.Context, filter interface{}) ([]models.Community, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Community, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 }
 
 type communityDatabase struct {
@@ -33,14 +38,35 @@ func (c *communityDatabase) FindOne(ctx context.Context, filter interface{}) (*m
 	if err != nil {
 		return nil, err
 	}
+	normalizeCommunity(community)
 	return community, nil
 }
 
-func (c *communityDatabase) Find(ctx context.Context, filter interface{}) ([]models.Community, error) {
+func (c *communityDatabase) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Community, error) {
 	var communities []models.Community
-	err := c.db.Collection(collectionName).Find(ctx, filter).Decode(&communities)
+	err := c.db.Collection(collectionName).Find(ctx, filter, opts...).Decode(&communities)
 	if err != nil {
 		return nil, err
 	}
+	for i := range communities {
+		normalizeCommunity(&communities[i])
+	}
 	return communities, nil
 }
+
+func (c *communityDatabase) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.db.Collection(collectionName).UpdateOne(ctx, filter, update, opts...)
+}
+
+func (c *communityDatabase) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.db.Collection(collectionName).UpdateMany(ctx, filter, update, opts...)
+}
+
+// normalizeCommunity folds legacy field spellings into their canonical fields, so
+// documents written by the legacy Node writer look the same as everything else
+func normalizeCommunity(community *models.Community) {
+	if community.Details.OwnerID == "" {
+		community.Details.OwnerID = community.Details.LegacyOwnerID
+	}
+	community.Details.LegacyOwnerID = ""
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/linesmerrill/police-cad-api/config"
 	"github.com/linesmerrill/police-cad-api/databases"
@@ -138,3 +139,81 @@ func TestCommunityDatabase_Find(t *testing.T) {
 	assert.Equal(t, []models.Community{{ID: "mocked-user"}}, user)
 	assert.NoError(t, err)
 }
+
+func TestCommunityDatabase_UpdateOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": true}, bson.M{}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": false}, bson.M{}).
+		Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "communities").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	communityDba := databases.NewCommunityDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	result, err := communityDba.UpdateOne(context.Background(), bson.M{"error": true}, bson.M{})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	result, err = communityDba.UpdateOne(context.Background(), bson.M{"error": false}, bson.M{})
+
+	assert.Equal(t, &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, result)
+	assert.NoError(t, err)
+}
+
+func TestCommunityDatabase_FindOneLegacyOwnerID(t *testing.T) {
+	legacy, _ := bson.Marshal(bson.M{
+		"_id":       "608cafe595eb9dc05379b7f4",
+		"community": bson.M{"name": "Legacy RP", "ownerId": "legacy-owner"},
+	})
+
+	dbHelper := &mocks.DatabaseHelper{}
+	collectionHelper := &mocks.CollectionHelper{}
+	srHelper := &mocks.SingleResultHelper{}
+
+	srHelper.On("Decode", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(0).(**models.Community)
+		_ = bson.Unmarshal(legacy, *arg)
+	})
+	collectionHelper.On("FindOne", context.Background(), bson.M{}).Return(srHelper)
+	dbHelper.On("Collection", "communities").Return(collectionHelper)
+
+	community, err := databases.NewCommunityDatabase(dbHelper).FindOne(context.Background(), bson.M{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy-owner", community.Details.OwnerID)
+	assert.Empty(t, community.Details.LegacyOwnerID)
+}
+
+func TestCommunityWritesCanonicalKeys(t *testing.T) {
+	b, err := bson.Marshal(models.Community{ID: "608cafe595eb9dc05379b7f4", Details: models.CommunityDetails{OwnerID: "owner"}})
+	assert.NoError(t, err)
+
+	var doc bson.M
+	assert.NoError(t, bson.Unmarshal(b, &doc))
+	details := doc["community"].(bson.M)
+
+	assert.Equal(t, "owner", details["ownerID"])
+	assert.NotContains(t, details, "ownerId")
+	assert.NotContains(t, details, "ownerid")
+	assert.Contains(t, details, "activePanics")
+	assert.Contains(t, details, "activeSignal100")
+}
@@ -2,9 +2,11 @@ package databases
 
 import (
 	"context"
+	"errors"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 
 	"github.com/linesmerrill/police-cad-api/config"
 )
@@ -14,12 +16,40 @@ import (
 type DatabaseHelper interface {
 	Collection(name string) CollectionHelper
 	Client() ClientHelper
+	ListCollectionNames(ctx context.Context, filter interface{}) ([]string, error)
+}
+
+// RequiredCollections are the collections the police-cad app creates and this api reads from.
+// Collections only this api writes to, like apiKeys, are created on first use and not listed.
+var RequiredCollections = []string{
+	collectionName,
+	userName,
+	civilianName,
+	vehicleName,
+	firearmName,
+	licenseName,
+	emsName,
+	emsVehicleName,
+	warrantName,
+	callName,
 }
 
 // CollectionHelper contains all the methods defined for collections in this project
 type CollectionHelper interface {
 	FindOne(context.Context, interface{}, ...*options.FindOneOptions) SingleResultHelper
 	Find(context.Context, interface{}, ...*options.FindOptions) CursorHelper
+	InsertOne(context.Context, interface{}, ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	UpdateOne(context.Context, interface{}, interface{}, ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(context.Context, interface{}, interface{}, ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	DeleteMany(context.Context, interface{}, ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	CountDocuments(context.Context, interface{}, ...*options.CountOptions) (int64, error)
+	Indexes() IndexViewHelper
+}
+
+// IndexViewHelper creates the indexes of a collection
+type IndexViewHelper interface {
+	CreateMany(context.Context, []mongo.IndexModel, ...*options.CreateIndexesOptions) ([]string, error)
+	ListSpecifications(context.Context, ...*options.ListIndexesOptions) ([]*mongo.IndexSpecification, error)
 }
 
 // SingleResultHelper contains a single method to decode the result
@@ -32,11 +62,20 @@ type CursorHelper interface {
 	Decode(v interface{}) error
 }
 
+// CursorIterator is implemented by cursors that can also be read one document at a time
+type CursorIterator interface {
+	Next(ctx context.Context) bool
+	DecodeCurrent(v interface{}) error
+	Err() error
+	Close(ctx context.Context) error
+}
+
 // ClientHelper defined to help at client creation inside main.go
 type ClientHelper interface {
 	Database(string) DatabaseHelper
 	Connect() error
 	StartSession() (mongo.Session, error)
+	Ping(ctx context.Context) error
 }
 
 type mongoClient struct {
@@ -51,12 +90,23 @@ type mongoCollection struct {
 	coll *mongo.Collection
 }
 
+type mongoIndexView struct {
+	iv mongo.IndexView
+}
+
 type mongoSingleResult struct {
 	sr *mongo.SingleResult
 }
 
+// errNoCursor is returned when a cursor is read without the query having returned one
+var errNoCursor = errors.New("no cursor, the query was not run")
+
 type mongoCursor struct {
-	cr *mongo.Cursor
+	// ctx is the context the query was run with, Decode drains the cursor with it so the
+	// query timeout covers reading the results too
+	ctx context.Context
+	cr  *mongo.Cursor
+	err error
 }
 
 type mongoSession struct {
@@ -89,6 +139,11 @@ func (mc *mongoClient) Connect() error {
 	return mc.cl.Connect(nil)
 }
 
+// Ping checks that the primary can be reached
+func (mc *mongoClient) Ping(ctx context.Context) error {
+	return mc.cl.Ping(ctx, readpref.Primary())
+}
+
 func (md *mongoDatabase) Collection(colName string) CollectionHelper {
 	collection := md.db.Collection(colName)
 	return &mongoCollection{coll: collection}
@@ -99,6 +154,10 @@ func (md *mongoDatabase) Client() ClientHelper {
 	return &mongoClient{cl: client}
 }
 
+func (md *mongoDatabase) ListCollectionNames(ctx context.Context, filter interface{}) ([]string, error) {
+	return md.db.ListCollectionNames(ctx, filter)
+}
+
 func (mc *mongoCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) SingleResultHelper {
 	singleResult := mc.coll.FindOne(ctx, filter, opts...)
 	return &mongoSingleResult{sr: singleResult}
@@ -106,10 +165,39 @@ func (mc *mongoCollection) FindOne(ctx context.Context, filter interface{}, opts
 
 func (mc *mongoCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) CursorHelper {
 	cursor, err := mc.coll.Find(ctx, filter, opts...)
-	if err != nil {
-		println(err)
-	}
-	return &mongoCursor{cr: cursor}
+	return &mongoCursor{ctx: ctx, cr: cursor, err: err}
+}
+
+func (mc *mongoCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return mc.coll.InsertOne(ctx, document, opts...)
+}
+
+func (mc *mongoCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return mc.coll.UpdateOne(ctx, filter, update, opts...)
+}
+
+func (mc *mongoCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return mc.coll.UpdateMany(ctx, filter, update, opts...)
+}
+
+func (mc *mongoCollection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return mc.coll.DeleteMany(ctx, filter, opts...)
+}
+
+func (mc *mongoCollection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	return mc.coll.CountDocuments(ctx, filter, opts...)
+}
+
+func (mc *mongoCollection) Indexes() IndexViewHelper {
+	return &mongoIndexView{iv: mc.coll.Indexes()}
+}
+
+func (mi *mongoIndexView) CreateMany(ctx context.Context, models []mongo.IndexModel, opts ...*options.CreateIndexesOptions) ([]string, error) {
+	return mi.iv.CreateMany(ctx, models, opts...)
+}
+
+func (mi *mongoIndexView) ListSpecifications(ctx context.Context, opts ...*options.ListIndexesOptions) ([]*mongo.IndexSpecification, error) {
+	return mi.iv.ListSpecifications(ctx, opts...)
 }
 
 func (sr *mongoSingleResult) Decode(v interface{}) error {
@@ -117,9 +205,50 @@ func (sr *mongoSingleResult) Decode(v interface{}) error {
 }
 
 func (cr *mongoCursor) Decode(v interface{}) error {
-	return cr.All(context.Background(), v)
+	return cr.All(cr.ctx, v)
 }
 
+// All decodes every remaining document into results. It returns the error of the query
+// instead when the query could not be run.
 func (cr *mongoCursor) All(ctx context.Context, results interface{}) error {
+	if cr.err != nil {
+		return cr.err
+	}
+	if cr.cr == nil {
+		return errNoCursor
+	}
 	return cr.cr.All(ctx, results)
 }
+
+// Next moves to the next document. It returns false once the cursor is exhausted or when the
+// query could not be run, Err tells the two apart.
+func (cr *mongoCursor) Next(ctx context.Context) bool {
+	if cr.cr == nil {
+		return false
+	}
+	return cr.cr.Next(ctx)
+}
+
+func (cr *mongoCursor) DecodeCurrent(v interface{}) error {
+	if cr.cr == nil {
+		return errNoCursor
+	}
+	return cr.cr.Decode(v)
+}
+
+func (cr *mongoCursor) Err() error {
+	if cr.err != nil {
+		return cr.err
+	}
+	if cr.cr == nil {
+		return errNoCursor
+	}
+	return cr.cr.Err()
+}
+
+func (cr *mongoCursor) Close(ctx context.Context) error {
+	if cr.cr == nil {
+		return nil
+	}
+	return cr.cr.Close(ctx)
+}
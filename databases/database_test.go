@@ -55,6 +55,18 @@ func (_m *MockDatabaseHelper) Collection(name string) databases.CollectionHelper
 	return r0
 }
 
+// ListCollectionNames provides a mock function.
+func (_m *MockDatabaseHelper) ListCollectionNames(ctx context.Context, filter interface{}) ([]string, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []string
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]string)
+	}
+
+	return r0, ret.Error(1)
+}
+
 // TestNewDatabase test new database creation
 func TestNewDatabase(t *testing.T) {
 	os.Setenv("DB_URI", "mongodb://127.0.0.1:27017")
@@ -124,7 +136,14 @@ func TestRestOfInternalCode(t *testing.T) {
 	var result interface{}
 	// because we do not care for actual results, we just quickly timeout the
 	// call and we use incorrect call method
-	timeoutCtx, _ := context.WithTimeout(context.Background(), 1*time.Microsecond)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 1*time.Microsecond)
+	defer cancel()
 	db.Collection("non-fake-existing-collection").FindOne(timeoutCtx, "incorrect-value").Decode(&result)
-	db.Collection("non-fake-existing-collection").Find(timeoutCtx, "incorrect-value").Decode(&result)
+	// a query that fails is reported by Decode instead of panicking on the missing cursor
+	assert.Error(t, db.Collection("non-fake-existing-collection").Find(timeoutCtx, "incorrect-value").Decode(&result))
+	db.Collection("non-fake-existing-collection").InsertOne(timeoutCtx, "incorrect-value")
+	db.Collection("non-fake-existing-collection").UpdateOne(timeoutCtx, "incorrect-value", "incorrect-value")
+	db.Collection("non-fake-existing-collection").UpdateMany(timeoutCtx, "incorrect-value", "incorrect-value")
+	db.Collection("non-fake-existing-collection").DeleteMany(timeoutCtx, "incorrect-value")
+	db.ListCollectionNames(timeoutCtx, "incorrect-value")
 }
@@ -0,0 +1,49 @@
+package databases
+
+// go generate: mockery --name IdempotencyDatabase
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const idempotencyName = "idempotencyKeys"
+
+// IdempotencyDatabase contains the methods to use with the idempotency database
+type IdempotencyDatabase interface {
+	FindOne(ctx context.Context, filter interface{}) (*models.IdempotencyRecord, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+}
+
+type idempotencyDatabase struct {
+	db DatabaseHelper
+}
+
+// NewIdempotencyDatabase initializes a new instance of idempotency database with the provided db connection
+func NewIdempotencyDatabase(db DatabaseHelper) IdempotencyDatabase {
+	return &idempotencyDatabase{
+		db: db,
+	}
+}
+
+func (i *idempotencyDatabase) FindOne(ctx context.Context, filter interface{}) (*models.IdempotencyRecord, error) {
+	record := &models.IdempotencyRecord{}
+	err := i.db.Collection(idempotencyName).FindOne(ctx, filter).Decode(&record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (i *idempotencyDatabase) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return i.db.Collection(idempotencyName).UpdateOne(ctx, filter, update, opts...)
+}
+
+func (i *idempotencyDatabase) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return i.db.Collection(idempotencyName).DeleteMany(ctx, filter, opts...)
+}
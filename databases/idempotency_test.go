@@ -0,0 +1,165 @@
+package databases_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+func TestNewIdempotencyDatabase(t *testing.T) {
+	_ = os.Setenv("DB_URI", "mongodb://127.0.0.1:27017")
+	_ = os.Setenv("DB_NAME", "test")
+	conf := config.New()
+
+	dbClient, err := databases.NewClient(conf)
+	assert.NoError(t, err)
+
+	db := databases.NewDatabase(conf, dbClient)
+
+	idempotencyDB := databases.NewIdempotencyDatabase(db)
+
+	assert.NotEmpty(t, idempotencyDB)
+}
+
+func TestIdempotencyDatabase_FindOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+	var srHelperErr databases.SingleResultHelper
+	var srHelperCorrect databases.SingleResultHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+	srHelperErr = &mocks.SingleResultHelper{}
+	srHelperCorrect = &mocks.SingleResultHelper{}
+
+	srHelperErr.(*mocks.SingleResultHelper).
+		On("Decode", mock.Anything).
+		Return(errors.New("mocked-error"))
+
+	srHelperCorrect.(*mocks.SingleResultHelper).
+		On("Decode", mock.Anything).
+		Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(0).(**models.IdempotencyRecord)
+		(*arg).ID = "mocked-record"
+	})
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("FindOne", context.Background(), bson.M{"error": true}).
+		Return(srHelperErr)
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("FindOne", context.Background(), bson.M{"error": false}).
+		Return(srHelperCorrect)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "idempotencyKeys").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	idempotencyDba := databases.NewIdempotencyDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	record, err := idempotencyDba.FindOne(context.Background(), bson.M{"error": true})
+
+	assert.Empty(t, record)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	record, err = idempotencyDba.FindOne(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, &models.IdempotencyRecord{ID: "mocked-record"}, record)
+	assert.NoError(t, err)
+}
+
+func TestIdempotencyDatabase_UpdateOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": true}, bson.M{}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": false}, bson.M{}).
+		Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "idempotencyKeys").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	idempotencyDba := databases.NewIdempotencyDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	result, err := idempotencyDba.UpdateOne(context.Background(), bson.M{"error": true}, bson.M{})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	result, err = idempotencyDba.UpdateOne(context.Background(), bson.M{"error": false}, bson.M{})
+
+	assert.Equal(t, &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, result)
+	assert.NoError(t, err)
+}
+
+func TestIdempotencyDatabase_DeleteMany(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("DeleteMany", context.Background(), bson.M{"error": true}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("DeleteMany", context.Background(), bson.M{"error": false}).
+		Return(&mongo.DeleteResult{DeletedCount: 2}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "idempotencyKeys").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	idempotencyDba := databases.NewIdempotencyDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	result, err := idempotencyDba.DeleteMany(context.Background(), bson.M{"error": true})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	result, err = idempotencyDba.DeleteMany(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, &mongo.DeleteResult{DeletedCount: 2}, result)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,196 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// idempotencyKeyTTL matches how long the api replays an idempotent response
+const idempotencyKeyTTL = 24 * 60 * 60
+
+// communityIndexes back the community lookups by owner, slug and name search. Both owner key
+// spellings are indexed until the legacy ownerId documents are migrated.
+var communityIndexes = map[string][]mongo.IndexModel{
+	collectionName: {
+		{Keys: bson.D{{Key: "community.ownerID", Value: 1}}},
+		{Keys: bson.D{{Key: "community.ownerId", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "community.slug", Value: 1}},
+			// communities created by the legacy app have no slug until one is set
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"community.slug": bson.M{"$type": "string"}}),
+		},
+		{Keys: bson.D{{Key: "community.previousSlugs", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "community.name", Value: 1}},
+			// matches the collation of the name search so it can use the index
+			Options: options.Index().SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+		},
+	},
+	userName: {
+		{Keys: bson.D{{Key: "user.activeCommunity", Value: 1}}},
+		{Keys: bson.D{{Key: "user.isOnline", Value: 1}, {Key: "user.lastSeenAt", Value: 1}}},
+	},
+	callName: {
+		{Keys: bson.D{{Key: "call.communityID", Value: 1}, {Key: "call.status", Value: 1}}},
+	},
+}
+
+// civilianIndexes back the civilian records of a community and everything linked to a civilian
+var civilianIndexes = map[string][]mongo.IndexModel{
+	civilianName: {
+		{Keys: bson.D{{Key: "civilian.activeCommunityID", Value: 1}, {Key: "civilian.lastName", Value: 1}, {Key: "civilian.firstName", Value: 1}}},
+		{Keys: bson.D{{Key: "civilian.userID", Value: 1}}},
+	},
+	vehicleName: {
+		{Keys: bson.D{{Key: "vehicle.registeredOwnerID", Value: 1}}},
+		{Keys: bson.D{{Key: "vehicle.activeCommunityID", Value: 1}, {Key: "vehicle.plate", Value: 1}}},
+		{Keys: bson.D{{Key: "vehicle.userID", Value: 1}}},
+	},
+	firearmName: {
+		{Keys: bson.D{{Key: "firearm.registeredOwnerID", Value: 1}}},
+		{Keys: bson.D{{Key: "firearm.activeCommunityID", Value: 1}, {Key: "firearm.serialNumber", Value: 1}}},
+		{Keys: bson.D{{Key: "firearm.userID", Value: 1}}},
+	},
+	licenseName: {
+		{Keys: bson.D{{Key: "license.ownerID", Value: 1}}},
+		{Keys: bson.D{{Key: "license.userID", Value: 1}}},
+	},
+	warrantName: {
+		{Keys: bson.D{{Key: "warrant.accusedID", Value: 1}, {Key: "warrant.createdAt", Value: -1}}},
+	},
+	citationName: {
+		{Keys: bson.D{{Key: "communityId", Value: 1}, {Key: "civilianId", Value: 1}, {Key: "issuedAt", Value: -1}}},
+	},
+}
+
+// apiIndexes back the collections only this api writes to
+var apiIndexes = map[string][]mongo.IndexModel{
+	apiKeyName: {
+		{Keys: bson.D{{Key: "keyHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "communityId", Value: 1}}},
+	},
+	auditLogName: {
+		{Keys: bson.D{{Key: "communityId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	},
+	idempotencyName: {
+		// stored responses are removed once they can no longer be replayed
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(idempotencyKeyTTL)},
+	},
+}
+
+// Indexes returns the indexes every collection needs, keyed by collection name
+func Indexes() map[string][]mongo.IndexModel {
+	all := map[string][]mongo.IndexModel{}
+	for _, group := range []map[string][]mongo.IndexModel{communityIndexes, civilianIndexes, apiIndexes} {
+		for name, models := range group {
+			all[name] = append(all[name], models...)
+		}
+	}
+	return all
+}
+
+// EnsureIndexes creates any missing index from Indexes. Creating an index that already exists
+// is a no-op in mongo; one that exists with different options is logged and left alone, so a
+// hand-tuned index in production never blocks startup.
+func EnsureIndexes(ctx context.Context, db DatabaseHelper) error {
+	indexes := Indexes()
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		created, err := db.Collection(name).Indexes().CreateMany(ctx, indexes[name])
+		if isIndexConflict(err) {
+			zap.S().With(err).Warnw("index already exists with different options", "collection", name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create indexes on %s: %v", name, err)
+		}
+		zap.S().Infow("ensured indexes", "collection", name, "indexes", created)
+	}
+	return nil
+}
+
+// MissingIndexes lists the indexes from Indexes that do not exist yet, named like mongo names
+// them, e.g. civilians.civilian.userID_1. It only reads the index specifications and never
+// creates anything, so it is safe to run against a live database.
+func MissingIndexes(ctx context.Context, db DatabaseHelper) ([]string, error) {
+	indexes := Indexes()
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var missing []string
+	for _, name := range names {
+		specs, err := db.Collection(name).Indexes().ListSpecifications(ctx)
+		if err != nil && !isNamespaceNotFound(err) {
+			return nil, fmt.Errorf("failed to list indexes on %s: %v", name, err)
+		}
+		existing := map[string]bool{}
+		for _, spec := range specs {
+			existing[rawIndexName(spec.KeysDocument)] = true
+		}
+		for _, model := range indexes[name] {
+			if key := indexName(model.Keys.(bson.D)); !existing[key] {
+				missing = append(missing, name+"."+key)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// indexName is the name mongo gives an index with the given keys, e.g. lastName_1_firstName_-1
+func indexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		parts = append(parts, key.Key, fmt.Sprint(key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// rawIndexName is indexName for the keys of an existing index. Directions are compared as whole
+// numbers, since indexes created from the shell store them as doubles.
+func rawIndexName(keys bson.Raw) string {
+	elems, _ := keys.Elements()
+	parts := make([]string, 0, len(elems)*2)
+	for _, elem := range elems {
+		value := elem.Value()
+		direction := value.String()
+		if n, ok := value.AsInt64OK(); ok {
+			direction = fmt.Sprint(n)
+		} else if s, ok := value.StringValueOK(); ok {
+			direction = s
+		}
+		parts = append(parts, elem.Key(), direction)
+	}
+	return strings.Join(parts, "_")
+}
+
+// isNamespaceNotFound reports whether err means the collection does not exist yet
+func isNamespaceNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == 26
+}
+
+// isIndexConflict reports whether err means an index with the same name or keys already exists
+func isIndexConflict(err error) bool {
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	// IndexOptionsConflict and IndexKeySpecsConflict
+	return cmdErr.Code == 85 || cmdErr.Code == 86
+}
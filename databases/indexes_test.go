@@ -0,0 +1,135 @@
+package databases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+)
+
+// indexDB returns a database whose collections all share the given index view
+func indexDB(indexView *mocks.IndexViewHelper) *mocks.DatabaseHelper {
+	collectionHelper := &mocks.CollectionHelper{}
+	collectionHelper.On("Indexes").Return(indexView)
+	dbHelper := &mocks.DatabaseHelper{}
+	dbHelper.On("Collection", mock.Anything).Return(collectionHelper)
+	return dbHelper
+}
+
+func indexKeys(models []mongo.IndexModel) []bson.D {
+	keys := make([]bson.D, 0, len(models))
+	for _, m := range models {
+		keys = append(keys, m.Keys.(bson.D))
+	}
+	return keys
+}
+
+func TestIndexes(t *testing.T) {
+	indexes := databases.Indexes()
+
+	assert.Contains(t, indexKeys(indexes["communities"]), bson.D{{Key: "community.ownerID", Value: 1}})
+	assert.Contains(t, indexKeys(indexes["civilians"]), bson.D{{Key: "civilian.activeCommunityID", Value: 1}, {Key: "civilian.lastName", Value: 1}, {Key: "civilian.firstName", Value: 1}})
+	assert.Contains(t, indexKeys(indexes["warrants"]), bson.D{{Key: "warrant.accusedID", Value: 1}, {Key: "warrant.createdAt", Value: -1}})
+
+	unique := map[string]bool{}
+	for name, models := range indexes {
+		for _, m := range models {
+			if m.Options != nil && m.Options.Unique != nil && *m.Options.Unique {
+				unique[name+" "+m.Keys.(bson.D)[0].Key] = true
+			}
+		}
+	}
+	assert.Equal(t, map[string]bool{"apiKeys keyHash": true, "communities community.slug": true}, unique)
+
+	ttl := indexes["idempotencyKeys"][0]
+	assert.Equal(t, bson.D{{Key: "createdAt", Value: 1}}, ttl.Keys)
+	assert.Equal(t, int32(24*60*60), *ttl.Options.ExpireAfterSeconds)
+}
+
+func TestEnsureIndexes(t *testing.T) {
+	indexView := &mocks.IndexViewHelper{}
+	indexView.On("CreateMany", mock.Anything, mock.Anything).Return([]string{"index_1"}, nil)
+	dbHelper := indexDB(indexView)
+
+	err := databases.EnsureIndexes(context.Background(), dbHelper)
+
+	assert.NoError(t, err)
+	indexes := databases.Indexes()
+	dbHelper.AssertNumberOfCalls(t, "Collection", len(indexes))
+	for name, models := range indexes {
+		dbHelper.AssertCalled(t, "Collection", name)
+		indexView.AssertCalled(t, "CreateMany", mock.Anything, models)
+	}
+}
+
+func TestEnsureIndexesAlreadyExists(t *testing.T) {
+	indexView := &mocks.IndexViewHelper{}
+	indexView.On("CreateMany", mock.Anything, mock.Anything).Return(nil, mongo.CommandError{Code: 85, Name: "IndexOptionsConflict"})
+
+	err := databases.EnsureIndexes(context.Background(), indexDB(indexView))
+
+	assert.NoError(t, err)
+	indexView.AssertNumberOfCalls(t, "CreateMany", len(databases.Indexes()))
+}
+
+func TestEnsureIndexesFailure(t *testing.T) {
+	indexView := &mocks.IndexViewHelper{}
+	indexView.On("CreateMany", mock.Anything, mock.Anything).Return(nil, errors.New("not authorized"))
+
+	err := databases.EnsureIndexes(context.Background(), indexDB(indexView))
+
+	assert.EqualError(t, err, "failed to create indexes on apiKeys: not authorized")
+}
+
+func TestMissingIndexes(t *testing.T) {
+	// every collection has the civilian indexes, stored with double directions like the shell does
+	var specs []*mongo.IndexSpecification
+	for _, model := range databases.Indexes()["civilians"] {
+		keys := bson.D{}
+		for _, key := range model.Keys.(bson.D) {
+			keys = append(keys, bson.E{Key: key.Key, Value: float64(key.Value.(int))})
+		}
+		raw, _ := bson.Marshal(keys)
+		specs = append(specs, &mongo.IndexSpecification{KeysDocument: raw})
+	}
+	indexView := &mocks.IndexViewHelper{}
+	indexView.On("ListSpecifications", mock.Anything).Return(specs, nil)
+
+	missing, err := databases.MissingIndexes(context.Background(), indexDB(indexView))
+
+	assert.NoError(t, err)
+	assert.Contains(t, missing, "communities.community.ownerID_1")
+	assert.Contains(t, missing, "warrants.warrant.accusedID_1_warrant.createdAt_-1")
+	assert.NotContains(t, missing, "civilians.civilian.userID_1")
+	indexView.AssertNotCalled(t, "CreateMany", mock.Anything, mock.Anything)
+}
+
+func TestMissingIndexesNoCollection(t *testing.T) {
+	indexView := &mocks.IndexViewHelper{}
+	indexView.On("ListSpecifications", mock.Anything).Return(nil, mongo.CommandError{Code: 26, Name: "NamespaceNotFound"})
+
+	missing, err := databases.MissingIndexes(context.Background(), indexDB(indexView))
+
+	assert.NoError(t, err)
+	total := 0
+	for _, models := range databases.Indexes() {
+		total += len(models)
+	}
+	assert.Len(t, missing, total)
+}
+
+func TestMissingIndexesError(t *testing.T) {
+	indexView := &mocks.IndexViewHelper{}
+	indexView.On("ListSpecifications", mock.Anything).Return(nil, errors.New("not authorized"))
+
+	_, err := databases.MissingIndexes(context.Background(), indexDB(indexView))
+
+	assert.Error(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 
 	"github.com/linesmerrill/police-cad-api/models"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -15,6 +16,7 @@ const licenseName = "licenses"
 type LicenseDatabase interface {
 	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (*models.License, error)
 	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.License, error)
+	DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
 }
 
 type licenseDatabase struct {
@@ -45,3 +47,7 @@ func (c *licenseDatabase) Find(ctx context.Context, filter interface{}, opts ...
 	}
 	return licenses, nil
 }
+
+func (c *licenseDatabase) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return c.db.Collection(licenseName).DeleteMany(ctx, filter, opts...)
+}
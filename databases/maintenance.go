@@ -0,0 +1,44 @@
+package databases
+
+// go generate: mockery --name MaintenanceDatabase
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/linesmerrill/police-cad-api/models"
+)
+
+const maintenanceName = "maintenance"
+
+// MaintenanceDatabase contains the methods to use with the maintenance database
+type MaintenanceDatabase interface {
+	FindOne(ctx context.Context, filter interface{}) (*models.Maintenance, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+}
+
+type maintenanceDatabase struct {
+	db DatabaseHelper
+}
+
+// NewMaintenanceDatabase initializes a new instance of maintenance database with the provided db connection
+func NewMaintenanceDatabase(db DatabaseHelper) MaintenanceDatabase {
+	return &maintenanceDatabase{
+		db: db,
+	}
+}
+
+func (m *maintenanceDatabase) FindOne(ctx context.Context, filter interface{}) (*models.Maintenance, error) {
+	maintenance := &models.Maintenance{}
+	err := m.db.Collection(maintenanceName).FindOne(ctx, filter).Decode(&maintenance)
+	if err != nil {
+		return nil, err
+	}
+	return maintenance, nil
+}
+
+func (m *maintenanceDatabase) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return m.db.Collection(maintenanceName).UpdateOne(ctx, filter, update, opts...)
+}
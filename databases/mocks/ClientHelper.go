@@ -3,9 +3,10 @@
 package mocks
 
 import (
+	context "context"
+
 	databases "github.com/linesmerrill/police-cad-api/databases"
 	mock "github.com/stretchr/testify/mock"
-
 	mongo "go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -44,6 +45,20 @@ func (_m *ClientHelper) Database(_a0 string) databases.DatabaseHelper {
 	return r0
 }
 
+// Ping provides a mock function with given fields: ctx
+func (_m *ClientHelper) Ping(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // StartSession provides a mock function with given fields:
 func (_m *ClientHelper) StartSession() (mongo.Session, error) {
 	ret := _m.Called()
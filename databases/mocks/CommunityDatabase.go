@@ -5,9 +5,10 @@ package mocks
 import (
 	context "context"
 
-	mock "github.com/stretchr/testify/mock"
-
 	models "github.com/linesmerrill/police-cad-api/models"
+	mock "github.com/stretchr/testify/mock"
+	mongo "go.mongodb.org/mongo-driver/mongo"
+	options "go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // CommunityDatabase is an autogenerated mock type for the CommunityDatabase type
@@ -15,13 +16,20 @@ type CommunityDatabase struct {
 	mock.Mock
 }
 
-// Find provides a mock function with given fields: ctx, filter
-func (_m *CommunityDatabase) Find(ctx context.Context, filter interface{}) ([]models.Community, error) {
-	ret := _m.Called(ctx, filter)
+// Find provides a mock function with given fields: ctx, filter, opts
+func (_m *CommunityDatabase) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Community, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, filter)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 []models.Community
-	if rf, ok := ret.Get(0).(func(context.Context, interface{}) []models.Community); ok {
-		r0 = rf(ctx, filter)
+	if rf, ok := ret.Get(0).(func(context.Context, interface{}, ...*options.FindOptions) []models.Community); ok {
+		r0 = rf(ctx, filter, opts...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Community)
@@ -29,8 +37,8 @@ func (_m *CommunityDatabase) Find(ctx context.Context, filter interface{}) ([]mo
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, interface{}) error); ok {
-		r1 = rf(ctx, filter)
+	if rf, ok := ret.Get(1).(func(context.Context, interface{}, ...*options.FindOptions) error); ok {
+		r1 = rf(ctx, filter, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -60,3 +68,63 @@ func (_m *CommunityDatabase) FindOne(ctx context.Context, filter interface{}) (*
 
 	return r0, r1
 }
+
+// UpdateMany provides a mock function with given fields: ctx, filter, update, opts
+func (_m *CommunityDatabase) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, filter, update)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *mongo.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, interface{}, interface{}, ...*options.UpdateOptions) *mongo.UpdateResult); ok {
+		r0 = rf(ctx, filter, update, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mongo.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, interface{}, interface{}, ...*options.UpdateOptions) error); ok {
+		r1 = rf(ctx, filter, update, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateOne provides a mock function with given fields: ctx, filter, update, opts
+func (_m *CommunityDatabase) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, filter, update)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *mongo.UpdateResult
+	if rf, ok := ret.Get(0).(func(context.Context, interface{}, interface{}, ...*options.UpdateOptions) *mongo.UpdateResult); ok {
+		r0 = rf(ctx, filter, update, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mongo.UpdateResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, interface{}, interface{}, ...*options.UpdateOptions) error); ok {
+		r1 = rf(ctx, filter, update, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	databases "github.com/linesmerrill/police-cad-api/databases"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -43,3 +45,26 @@ func (_m *DatabaseHelper) Collection(name string) databases.CollectionHelper {
 
 	return r0
 }
+
+// ListCollectionNames provides a mock function with given fields: ctx, filter
+func (_m *DatabaseHelper) ListCollectionNames(ctx context.Context, filter interface{}) ([]string, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, interface{}) []string); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, interface{}) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
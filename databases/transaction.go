@@ -0,0 +1,62 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// illegalOperationCode is returned by a standalone mongo when a transaction is started
+const illegalOperationCode = 20
+
+// Transactioner runs a set of writes atomically
+type Transactioner interface {
+	// WithTransaction runs fn in a transaction, committing when it returns nil and aborting when
+	// it returns an error. fn must use the context it is given for every query.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type mongoTransactioner struct {
+	db DatabaseHelper
+	// unsupported is set once the deployment has rejected a transaction
+	unsupported int32
+}
+
+// NewTransactioner returns a Transactioner for the database's client. On a standalone mongo,
+// which has no transactions, fn is run without one, so a failure part way through can leave
+// some of its writes behind.
+func NewTransactioner(db DatabaseHelper) Transactioner {
+	return &mongoTransactioner{db: db}
+}
+
+func (t *mongoTransactioner) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if atomic.LoadInt32(&t.unsupported) == 1 {
+		return fn(ctx)
+	}
+	session, err := t.db.Client().StartSession()
+	if err != nil {
+		zap.S().With(err).Warn("failed to start session, running without a transaction")
+		return fn(ctx)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	if isTransactionUnsupported(err) {
+		// nothing was written, the first operation of the transaction is the one rejected
+		zap.S().Warn("mongo deployment does not support transactions, running without them")
+		atomic.StoreInt32(&t.unsupported, 1)
+		return fn(ctx)
+	}
+	return err
+}
+
+// isTransactionUnsupported reports whether err means the deployment is a standalone mongo
+func isTransactionUnsupported(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == illegalOperationCode
+}
@@ -0,0 +1,34 @@
+package databases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/linesmerrill/police-cad-api/databases"
+	"github.com/linesmerrill/police-cad-api/databases/mocks"
+)
+
+func TestTransactioner_WithTransactionWithoutSession(t *testing.T) {
+	client := &mocks.ClientHelper{}
+	client.On("StartSession").Return(nil, errors.New("no session"))
+	dbHelper := &mocks.DatabaseHelper{}
+	dbHelper.On("Client").Return(client)
+	tx := databases.NewTransactioner(dbHelper)
+
+	// without a session fn still runs, just not atomically
+	calls := 0
+	err := tx.WithTransaction(context.Background(), func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	err = tx.WithTransaction(context.Background(), func(ctx context.Context) error {
+		return errors.New("mocked-error")
+	})
+	assert.EqualError(t, err, "mocked-error")
+}
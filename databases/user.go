@@ -5,6 +5,9 @@ package databases
 import (
 	"context"
 
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
 	"github.com/linesmerrill/police-cad-api/models"
 )
 
@@ -13,7 +16,10 @@ const userName = "users"
 // UserDatabase contains the methods to use with the user database
 type UserDatabase interface {
 	FindOne(ctx context.Context, filter interface{}) (*models.User, error)
-	Find(ctx context.Context, filter interface{}) ([]models.User, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.User, error)
+	Each(ctx context.Context, filter interface{}, fn func(models.User) error) error
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 }
 
 type userDatabase struct {
@@ -36,11 +42,50 @@ func (u *userDatabase) FindOne(ctx context.Context, filter interface{}) (*models
 	return user, nil
 }
 
-func (u *userDatabase) Find(ctx context.Context, filter interface{}) ([]models.User, error) {
+func (u *userDatabase) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.User, error) {
 	var users []models.User
-	err := u.db.Collection(userName).Find(ctx, filter).Decode(&users)
+	err := u.db.Collection(userName).Find(ctx, filter, opts...).Decode(&users)
 	if err != nil {
 		return nil, err
 	}
 	return users, nil
 }
+
+// Each calls fn with every user matching filter as they are read from the cursor, so a large
+// result is never held in memory at once. It stops at the first error fn returns.
+func (u *userDatabase) Each(ctx context.Context, filter interface{}, fn func(models.User) error) error {
+	cursor := u.db.Collection(userName).Find(ctx, filter)
+	it, ok := cursor.(CursorIterator)
+	if !ok {
+		// the cursor can only be decoded whole
+		var users []models.User
+		if err := cursor.Decode(&users); err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	defer it.Close(ctx)
+	for it.Next(ctx) {
+		var user models.User
+		if err := it.DecodeCurrent(&user); err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+func (u *userDatabase) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return u.db.Collection(userName).UpdateOne(ctx, filter, update, opts...)
+}
+
+func (u *userDatabase) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return u.db.Collection(userName).UpdateMany(ctx, filter, update, opts...)
+}
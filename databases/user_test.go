@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/linesmerrill/police-cad-api/config"
 	"github.com/linesmerrill/police-cad-api/databases"
@@ -138,3 +139,140 @@ func TestUserDatabase_Find(t *testing.T) {
 	assert.Equal(t, []models.User{{ID: "mocked-user"}}, user)
 	assert.NoError(t, err)
 }
+
+func TestUserDatabase_UpdateOne(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": true}, bson.M{}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateOne", context.Background(), bson.M{"error": false}, bson.M{}).
+		Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "users").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	userDba := databases.NewUserDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	result, err := userDba.UpdateOne(context.Background(), bson.M{"error": true}, bson.M{})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	result, err = userDba.UpdateOne(context.Background(), bson.M{"error": false}, bson.M{})
+
+	assert.Equal(t, &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, result)
+	assert.NoError(t, err)
+}
+
+func TestUserDatabase_UpdateMany(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateMany", context.Background(), bson.M{"error": true}, bson.M{}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("UpdateMany", context.Background(), bson.M{"error": false}, bson.M{}).
+		Return(&mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "users").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	userDba := databases.NewUserDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	result, err := userDba.UpdateMany(context.Background(), bson.M{"error": true}, bson.M{})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	result, err = userDba.UpdateMany(context.Background(), bson.M{"error": false}, bson.M{})
+
+	assert.Equal(t, &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, result)
+	assert.NoError(t, err)
+}
+
+// iterableCursor is a cursor that can be decoded whole or read one document at a time
+type iterableCursor struct {
+	*mocks.CursorHelper
+	*mocks.CursorIterator
+}
+
+func newIterableCursor(users []models.User) iterableCursor {
+	it := &mocks.CursorIterator{}
+	it.On("Next", mock.Anything).Return(true).Times(len(users))
+	it.On("Next", mock.Anything).Return(false)
+	next := 0
+	it.On("DecodeCurrent", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args.Get(0).(*models.User) = users[next]
+		next++
+	})
+	it.On("Err").Return(nil)
+	it.On("Close", mock.Anything).Return(nil)
+	return iterableCursor{&mocks.CursorHelper{}, it}
+}
+
+func TestUserDatabase_Each(t *testing.T) {
+	users := []models.User{{ID: "user-1"}, {ID: "user-2"}}
+	streamed := newIterableCursor(users)
+	stopped := newIterableCursor(users)
+	whole := &mocks.CursorHelper{}
+	whole.On("Decode", mock.Anything).Return(errors.New("mocked-error"))
+
+	collectionHelper := &mocks.CollectionHelper{}
+	collectionHelper.On("Find", context.Background(), bson.M{"case": "streamed"}).Return(streamed)
+	collectionHelper.On("Find", context.Background(), bson.M{"case": "stopped"}).Return(stopped)
+	collectionHelper.On("Find", context.Background(), bson.M{"case": "whole"}).Return(whole)
+	dbHelper := &mocks.DatabaseHelper{}
+	dbHelper.On("Collection", "users").Return(collectionHelper)
+	userDba := databases.NewUserDatabase(dbHelper)
+
+	// a cursor that can be iterated is read one user at a time
+	var got []models.User
+	err := userDba.Each(context.Background(), bson.M{"case": "streamed"}, func(user models.User) error {
+		got = append(got, user)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, users, got)
+	streamed.CursorHelper.AssertNotCalled(t, "Decode", mock.Anything)
+	streamed.CursorIterator.AssertCalled(t, "Close", mock.Anything)
+
+	// an error from fn stops the iteration
+	err = userDba.Each(context.Background(), bson.M{"case": "stopped"}, func(user models.User) error {
+		return errors.New("client went away")
+	})
+	assert.EqualError(t, err, "client went away")
+	stopped.CursorIterator.AssertNumberOfCalls(t, "DecodeCurrent", 1)
+	stopped.CursorIterator.AssertCalled(t, "Close", mock.Anything)
+
+	// other cursors are decoded whole
+	err = userDba.Each(context.Background(), bson.M{"case": "whole"}, func(user models.User) error { return nil })
+	assert.EqualError(t, err, "mocked-error")
+}
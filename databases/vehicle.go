@@ -6,6 +6,7 @@ import (
 	"context"
 
 	"github.com/linesmerrill/police-cad-api/models"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -15,6 +16,8 @@ const vehicleName = "vehicles"
 type VehicleDatabase interface {
 	FindOne(context.Context, interface{}, ...*options.FindOneOptions) (*models.Vehicle, error)
 	Find(context.Context, interface{}, ...*options.FindOptions) ([]models.Vehicle, error)
+	CountDocuments(context.Context, interface{}, ...*options.CountOptions) (int64, error)
+	DeleteMany(context.Context, interface{}, ...*options.DeleteOptions) (*mongo.DeleteResult, error)
 }
 
 type vehicleDatabase struct {
@@ -45,3 +48,11 @@ func (c *vehicleDatabase) Find(ctx context.Context, filter interface{}, opts ...
 	}
 	return vehicles, nil
 }
+
+func (c *vehicleDatabase) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	return c.db.Collection(vehicleName).CountDocuments(ctx, filter, opts...)
+}
+
+func (c *vehicleDatabase) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return c.db.Collection(vehicleName).DeleteMany(ctx, filter, opts...)
+}
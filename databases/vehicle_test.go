@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/linesmerrill/police-cad-api/config"
 	"github.com/linesmerrill/police-cad-api/databases"
@@ -138,3 +139,42 @@ func TestVehicleDatabase_Find(t *testing.T) {
 	assert.Equal(t, []models.Vehicle{{ID: "mocked-user"}}, user)
 	assert.NoError(t, err)
 }
+
+func TestVehicleDatabase_DeleteMany(t *testing.T) {
+
+	// define variables for interfaces
+	var dbHelper databases.DatabaseHelper
+	var collectionHelper databases.CollectionHelper
+
+	// set interfaces implementation to mocked structures
+	dbHelper = &mocks.DatabaseHelper{}
+	collectionHelper = &mocks.CollectionHelper{}
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("DeleteMany", context.Background(), bson.M{"error": true}).
+		Return(nil, errors.New("mocked-error"))
+
+	collectionHelper.(*mocks.CollectionHelper).
+		On("DeleteMany", context.Background(), bson.M{"error": false}).
+		Return(&mongo.DeleteResult{DeletedCount: 2}, nil)
+
+	dbHelper.(*mocks.DatabaseHelper).
+		On("Collection", "vehicles").Return(collectionHelper)
+
+	// Create new database with mocked Database interface
+	vehicleDba := databases.NewVehicleDatabase(dbHelper)
+
+	// Call method with defined filter, that in our mocked function returns
+	// mocked-error
+	result, err := vehicleDba.DeleteMany(context.Background(), bson.M{"error": true})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "mocked-error")
+
+	// Now call the same function with different filter for correct
+	// result
+	result, err = vehicleDba.DeleteMany(context.Background(), bson.M{"error": false})
+
+	assert.Equal(t, &mongo.DeleteResult{DeletedCount: 2}, result)
+	assert.NoError(t, err)
+}
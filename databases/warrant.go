@@ -6,6 +6,7 @@ import (
 	"context"
 
 	"github.com/linesmerrill/police-cad-api/models"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -15,6 +16,9 @@ const warrantName = "warrants"
 type WarrantDatabase interface {
 	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (*models.Warrant, error)
 	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Warrant, error)
+	InsertOne(ctx context.Context, document interface{}) (*mongo.InsertOneResult, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 }
 
 type warrantDatabase struct {
@@ -45,3 +49,15 @@ func (c *warrantDatabase) Find(ctx context.Context, filter interface{}, opts ...
 	}
 	return warrants, nil
 }
+
+func (c *warrantDatabase) InsertOne(ctx context.Context, document interface{}) (*mongo.InsertOneResult, error) {
+	return c.db.Collection(warrantName).InsertOne(ctx, document)
+}
+
+func (c *warrantDatabase) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.db.Collection(warrantName).UpdateOne(ctx, filter, update, opts...)
+}
+
+func (c *warrantDatabase) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.db.Collection(warrantName).UpdateMany(ctx, filter, update, opts...)
+}
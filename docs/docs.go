@@ -24,6 +24,8 @@
 package docs
 
 import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
 	"github.com/linesmerrill/police-cad-api/models"
 )
 
@@ -32,17 +34,111 @@ import (
 // responses:
 //   200: healthResponse
 
-// Shows the current health of the api. true means it is alive, false means it is not.
+// Shows the current health of the api. true means it is alive, false means it is not. Also
+// includes the build version and how long the process has been up.
 // swagger:response healthResponse
 type healthResponseWrapper struct {
 	// in:body
 	Body models.HealthCheckResponse
 }
 
+// swagger:route GET /ready health readyEndpointID
+// Lists the readiness of the web service api, including its maintenance state. Mongo is pinged
+// with a 2 second timeout and the api reports 503 when it or any other dependency is unavailable.
+// responses:
+//   200: readyResponse
+//   503: readyResponse
+
+// Shows whether the api is ready to serve traffic and whether maintenance mode is enabled.
+// swagger:response readyResponse
+type readyResponseWrapper struct {
+	// in:body
+	Body models.ReadyResponse
+}
+
+// swagger:route GET /metrics health metrics
+// Exposes request counts, request durations and mongo errors in the Prometheus text format.
+// Requests are labelled with their route template, method and status class.
+// produces:
+// - text/plain
+// responses:
+//   200: description: Prometheus text exposition
+
+// swagger:route GET /api/v1/openapi.json health openapi
+// Serves this spec as JSON so clients can fetch it at runtime. No token is required.
+// responses:
+//   200: description: the swagger 2.0 spec of the api
+//   304: description: the spec has not changed since the ETag sent in If-None-Match
+
+// swagger:route POST /api/v1/admin/maintenance admin maintenance
+// Turns maintenance mode on or off. While enabled, POST/PUT/PATCH/DELETE requests return 503,
+// and reads do too when allowReads is false. Every instance converges within the poll interval.
+// Only the users listed in ADMIN_USER_IDS may call it.
+// responses:
+//   200: maintenanceResponse
+//   400: errorMessageResponse
+//   403: description: the caller is not an admin
+
+// Shows the maintenance state that was applied
+// swagger:response maintenanceResponse
+type maintenanceResponseWrapper struct {
+	// in:body
+	Body models.Maintenance
+}
+
+// swagger:parameters maintenance
+type maintenanceParamsWrapper struct {
+	// in:body
+	Body struct {
+		Enabled    bool   `json:"enabled"`
+		Message    string `json:"message"`
+		AllowReads bool   `json:"allowReads"`
+	}
+}
+
+// swagger:route POST /api/v1/admin/migrations/community-owner-id admin migrateCommunityOwnerID
+// Rewrites communities stored with the legacy community.ownerId key to community.ownerID.
+// Runs a bounded number of batches per call, call again until done is true. Communities whose
+// _id is not an ObjectID are skipped and counted. Only the users listed in ADMIN_USER_IDS may call it.
+// responses:
+//   200: migrationProgressResponse
+//   400: errorMessageResponse
+//   403: description: the caller is not an admin
+
+// Shows how many batches ran, how many documents were migrated and whether anything is left
+// swagger:response migrationProgressResponse
+type migrationProgressResponseWrapper struct {
+	// in:body
+	Body models.MigrationProgress
+}
+
+// swagger:parameters migrateCommunityOwnerID
+type migrateCommunityOwnerIDParamsWrapper struct {
+	// in:query
+	BatchSize int `json:"batch_size"`
+}
+
+// swagger:route POST /api/v1/admin/selfcheck admin selfCheck
+// Runs the non-destructive startup self checks (mongo connectivity, collections and indexes, config)
+// and reports each one as pass, warn or fail with its timing. Responds with 503 when any check fails.
+// Only the users listed in ADMIN_USER_IDS may call it.
+// responses:
+//   200: selfCheckResponse
+//   403: description: the caller is not an admin
+//   503: selfCheckResponse
+
+// Shows the result of every self check
+// swagger:response selfCheckResponse
+type selfCheckResponseWrapper struct {
+	// in:body
+	Body models.SelfCheckReport
+}
+
 // swagger:route GET /api/v1/community/{community_id} community communityByID
-// Gets a single community by ID.
+// Gets a single community by ID or slug. Sends an ETag, a request with a matching If-None-Match gets a 304.
 // responses:
 //   200: communityByIDResponse
+//   304: notModifiedResponse
 //   404: errorMessageResponse
 
 // Shows a single community by the given {community_id}
@@ -56,6 +152,7 @@ type communityByIDResponseWrapper struct {
 // Gets a single community by community ID and owner ID.
 // responses:
 //   200: communityByCommunityIDAndOwnerIDResponse
+//   304: notModifiedResponse
 //   404: errorMessageResponse
 
 // Shows a single community by the given {community_id} and by owner {owner_id}
@@ -78,6 +175,359 @@ type communitiesByOwnerIDResponseWrapper struct {
 	Body []models.Community
 }
 
+// swagger:route GET /api/v1/communities/search community communitySearch
+// Searches communities by name, case insensitively, in alphabetical order. q must be at least 2 characters.
+// responses:
+//   200: communitySearchResponse
+//   400: errorMessageResponse
+
+// Shows the communities whose name matches q
+// swagger:response communitySearchResponse
+type communitySearchResponseWrapper struct {
+	// in:body
+	Body []models.CommunityLite
+}
+
+// swagger:parameters communitySearch
+type communitySearchParamsWrapper struct {
+	// in:query
+	Q string `json:"q"`
+	// in:query
+	Page int `json:"page"`
+	// in:query
+	Limit int `json:"limit"`
+}
+
+// swagger:route GET /api/v1/community/by-slug/{slug} community communityBySlug
+// Gets a single community by slug or ID. Previous slugs resolve to the community's current slug.
+// responses:
+//   200: communityBySlugResponse
+//   304: notModifiedResponse
+//   404: errorMessageResponse
+
+// Shows a single community by the given {slug}
+// swagger:response communityBySlugResponse
+type communityBySlugResponseWrapper struct {
+	// in:body
+	Body models.Community
+}
+
+// swagger:route PUT /api/v1/community/{community_id}/slug community updateCommunitySlug
+// Changes the slug of a community. Only the owner may change it, at most once every 30 days.
+// responses:
+//   200: communityBySlugResponse
+//   400: errorMessageResponse
+//   403: errorMessageResponse
+//   409: errorMessageResponse
+
+// swagger:parameters updateCommunitySlug
+type updateCommunitySlugParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+	// in:body
+	Body struct {
+		Slug string `json:"slug"`
+	}
+}
+
+// swagger:route POST /api/v1/community/{community_id}/api-keys community createAPIKey
+// Creates a read-only api key for a community. Only the owner may create keys, at most 10 per community
+// (best-effort: concurrent requests can end up one or two over).
+// The key is only returned once; send it as "Authorization: ApiKey <key>". read:members grants the
+// community user list, read:civilians the community civilian, vehicle and firearm searches and a
+// civilian's warrants and citations.
+// responses:
+//   201: apiKeyCreatedResponse
+//   400: errorMessageResponse
+//   403: errorMessageResponse
+//   409: errorMessageResponse
+
+// Shows the newly created api key, including the plaintext key
+// swagger:response apiKeyCreatedResponse
+type apiKeyCreatedResponseWrapper struct {
+	// in:body
+	Body models.APIKeyCreatedResponse
+}
+
+// swagger:parameters createAPIKey
+type createAPIKeyParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+	// in:body
+	Body struct {
+		Name string `json:"name"`
+		// one or more of read:members, read:civilians
+		Scopes []string `json:"scopes"`
+	}
+}
+
+// swagger:route GET /api/v1/community/{community_id}/api-keys community apiKeys
+// Lists the api keys of a community with their usage. Only the owner may list keys.
+// responses:
+//   200: apiKeysResponse
+//   403: errorMessageResponse
+
+// Shows all api keys of the given {community_id}
+// swagger:response apiKeysResponse
+type apiKeysResponseWrapper struct {
+	// in:body
+	Body []models.APIKey
+}
+
+// swagger:route DELETE /api/v1/community/{community_id}/api-keys/{api_key_id} community revokeAPIKey
+// Revokes an api key. Only the owner may revoke keys.
+// responses:
+//   204: description: api key revoked
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+
+// swagger:parameters apiKeys revokeAPIKey
+type apiKeysParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+}
+
+// swagger:route POST /api/v1/community/{community_id}/broadcast community createBroadcast
+// Pushes an urgent banner to the members of a community. Only the owner may broadcast. A new broadcast
+// replaces the active one. level is info (default), warning or critical and expiresInMinutes defaults
+// to 60, at most 1440.
+// responses:
+//   201: broadcastResponse
+//   400: errorMessageResponse
+//   403: errorMessageResponse
+
+// swagger:parameters createBroadcast
+type createBroadcastParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+	// in:body
+	Body struct {
+		Message          string `json:"message"`
+		Level            string `json:"level"`
+		ExpiresInMinutes int    `json:"expiresInMinutes"`
+	}
+}
+
+// swagger:route GET /api/v1/community/{community_id}/broadcast community broadcast
+// Gets the active broadcast of a community, or 204 when there is none or it has expired.
+// responses:
+//   200: broadcastResponse
+//   204: description: no active broadcast
+
+// Shows the broadcast of the given {community_id}
+// swagger:response broadcastResponse
+type broadcastResponseWrapper struct {
+	// in:body
+	Body models.Broadcast
+}
+
+// swagger:route DELETE /api/v1/community/{community_id}/broadcast community deleteBroadcast
+// Clears the broadcast of a community before it expires. Only the owner may clear it.
+// responses:
+//   204: description: the broadcast was cleared
+//   403: errorMessageResponse
+
+// swagger:parameters deleteBroadcast
+type deleteBroadcastParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+}
+
+// swagger:route GET /api/v1/community/{community_id}/audit-log community auditLog
+// Gets the administrative actions taken on a community, newest first. Only the owner may read it.
+// Actions are community.slug.update, apikey.create, apikey.revoke, civilian.bundle.delete, community.export,
+// community.broadcast.create and community.broadcast.delete.
+// responses:
+//   200: auditLogResponse
+//   403: errorMessageResponse
+
+// Shows the audit log of the given {community_id}
+// swagger:response auditLogResponse
+type auditLogResponseWrapper struct {
+	// in:body
+	Body []models.AuditLogEntry
+}
+
+// swagger:parameters auditLog
+type auditLogParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+	// in:query
+	Action string `json:"action"`
+	// in:query
+	ActorUserID string `json:"actorUserId"`
+	// in:query
+	Page int `json:"page"`
+	// in:query
+	Limit int `json:"limit"`
+}
+
+// swagger:route GET /api/v1/community/{community_id}/export community communityExport
+// Downloads the community and its member list. format=json (default) returns a single document,
+// format=csv returns a zip with community.csv and members.csv. Only the owner may export.
+// responses:
+//   200: communityExportResponse
+//   400: errorMessageResponse
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+
+// The export of the given {community_id}, sent as an attachment
+// swagger:response communityExportResponse
+type communityExportResponseWrapper struct {
+	// in:body
+	Body communityExport
+}
+
+// communityExport is the json document the export streams, member by member
+type communityExport struct {
+	Community  models.Community               `json:"community"`
+	ExportedAt primitive.DateTime             `json:"exportedAt"`
+	Members    []models.CommunityExportMember `json:"members"`
+}
+
+// swagger:parameters communityExport
+type communityExportParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+	// in:query
+	Format string `json:"format"`
+}
+
+// swagger:route GET /api/v1/community/{community_id}/civilians/{civilian_id}/bundle civilian civilianBundle
+// Gets a civilian together with every record linked to it, with per-section counts.
+// Only the civilian's owner and the community owner may request it.
+// responses:
+//   200: civilianBundleResponse
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+
+// Shows every record linked to the given {civilian_id}
+// swagger:response civilianBundleResponse
+type civilianBundleResponseWrapper struct {
+	// in:body
+	Body models.CivilianBundle
+}
+
+// swagger:route DELETE /api/v1/community/{community_id}/civilians/{civilian_id}/bundle civilian deleteCivilianBundle
+// Erases a civilian and every record linked to it. Only the civilian's owner may do this.
+// Without confirm_token a 428 is returned with a token, valid for 10 minutes, that confirms the deletion.
+// The erasure runs in a transaction, so a 500 leaves every record in place. On a standalone mongo
+// without transactions a 500 may leave part of it done, and the request can be retried.
+// responses:
+//   200: civilianBundleDeletionReceiptResponse
+//   400: errorMessageResponse
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+//   428: civilianBundleDeleteConfirmationResponse
+//   500: errorMessageResponse
+
+// Shows what was deleted, anonymized or unlinked
+// swagger:response civilianBundleDeletionReceiptResponse
+type civilianBundleDeletionReceiptResponseWrapper struct {
+	// in:body
+	Body models.CivilianBundleDeletionReceipt
+}
+
+// Shows the token needed to confirm the deletion and what will be deleted
+// swagger:response civilianBundleDeleteConfirmationResponse
+type civilianBundleDeleteConfirmationResponseWrapper struct {
+	// in:body
+	Body models.CivilianBundleDeleteConfirmation
+}
+
+// swagger:parameters civilianBundle deleteCivilianBundle
+type civilianBundleParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+	// in:query
+	ConfirmToken string `json:"confirm_token"`
+}
+
+// swagger:route POST /api/v1/community/{community_id}/citations citation createCitation
+// Issues a citation against a civilian of the community. The civilian must be active in the community,
+// and only the owner and members of the community can issue citations.
+// responses:
+//   201: citationResponse
+//   400: errorMessageResponse
+//   401: errorMessageResponse
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+//   409: errorMessageResponse
+
+// Shows a single citation
+// swagger:response citationResponse
+type citationResponseWrapper struct {
+	// in:body
+	Body models.Citation
+}
+
+// swagger:parameters createCitation
+type createCitationParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+	// in:body
+	Body struct {
+		CivilianID string  `json:"civilianId"`
+		Name       string  `json:"name"`
+		Amount     float64 `json:"amount"`
+		Notes      string  `json:"notes"`
+	}
+}
+
+// swagger:parameters createCitation createCivilianWarrant
+type idempotencyKeyParamsWrapper struct {
+	// Optional key, at most 128 characters. A retry with the same key within 24 hours replays the
+	// first successful response with an Idempotent-Replayed header instead of creating a duplicate.
+	// A retry sent while the first request is still running gets a 409.
+	// in:header
+	IdempotencyKey string `json:"Idempotency-Key"`
+}
+
+// swagger:route GET /api/v1/community/{community_id}/civilian/{civilian_id}/citations citation civilianCitations
+// Gets the citations of a civilian within a community, newest first.
+// responses:
+//   200: citationsResponse
+
+// Shows the citations of the given {civilian_id}
+// swagger:response citationsResponse
+type citationsResponseWrapper struct {
+	// in:body
+	Body []models.Citation
+}
+
+// swagger:parameters civilianCitations
+type civilianCitationsParamsWrapper struct {
+	// in:query
+	Page int `json:"page"`
+	// in:query
+	Limit int `json:"limit"`
+}
+
+// swagger:route PATCH /api/v1/citations/{citation_id}/status citation updateCitationStatus
+// Marks a citation as unpaid, paid or dismissed. Only the issuing user and the community owner may change it.
+// responses:
+//   200: citationResponse
+//   400: errorMessageResponse
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+
+// swagger:parameters updateCitationStatus
+type updateCitationStatusParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+	// in:body
+	Body struct {
+		Status string `json:"status"`
+	}
+}
+
+// Returned when the If-None-Match header matches the current ETag, the body is empty
+// swagger:response notModifiedResponse
+type notModifiedResponseWrapper struct {
+	// in:header
+	ETag string `json:"ETag"`
+}
+
 // Error message response
 // swagger:response errorMessageResponse
 type errorMessageResponseWrapper struct {
@@ -97,7 +547,50 @@ type userByIDResponseWrapper struct {
 	Body models.User
 }
 
-// swagger:route GET /api/v1/users/{community_id} user userByCommunityID
+// swagger:route POST /api/v1/users/{user_id}/heartbeat user userHeartbeat
+// Marks the user as online. Clients should send a heartbeat more often than PRESENCE_TIMEOUT
+// (5 minutes by default), after which the user is marked offline. Users can only send their own heartbeat.
+// responses:
+//   204: description: user marked online
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+
+// swagger:route POST /api/v1/users/{user_id}/offline user userOffline
+// Marks the user as offline straight away. Users can only mark themselves offline.
+// responses:
+//   204: description: user marked offline
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+
+// swagger:parameters userHeartbeat userOffline
+type userPresenceParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+}
+
+// swagger:route POST /api/v1/users/batch user usersBatch
+// Gets the public profile and online status of up to 200 users in one request, in the order they were requested.
+// IDs that are invalid or do not match a user are listed in missing.
+// responses:
+//   200: usersBatchResponse
+//   400: errorMessageResponse
+
+// Shows the users found for the requested ids
+// swagger:response usersBatchResponse
+type usersBatchResponseWrapper struct {
+	// in:body
+	Body models.UsersBatchResponse
+}
+
+// swagger:parameters usersBatch
+type usersBatchParamsWrapper struct {
+	// in:body
+	Body struct {
+		IDs []string `json:"ids"`
+	}
+}
+
+// swagger:route GET /api/v1/users/{active_community_id} user userByCommunityID
 // Get all users by community ID.
 // responses:
 //   200: usersByCommunityIDResponse
@@ -173,6 +666,135 @@ type civiliansByNameSearchParamsWrapper struct {
 	ActiveCommunityID string `json:"active_community_id"`
 }
 
+// swagger:route POST /api/v1/community/{community_id}/civilians/{civilian_id}/warrants warrant createCivilianWarrant
+// Issues an active warrant against a civilian of the community. Only the owner and members of the
+// community can issue warrants.
+// responses:
+//   201: civilianWarrantResponse
+//   400: errorMessageResponse
+//   401: errorMessageResponse
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+//   409: errorMessageResponse
+
+// swagger:route GET /api/v1/community/{community_id}/civilians/{civilian_id}/warrants warrant civilianWarrants
+// Lists the warrants of a civilian of the community, newest first.
+// responses:
+//   200: civilianWarrantsResponse
+//   400: errorMessageResponse
+//   404: errorMessageResponse
+
+// swagger:route PATCH /api/v1/community/{community_id}/civilians/{civilian_id}/warrants/{warrant_id} warrant updateCivilianWarrantStatus
+// Sets a warrant to active, served or recalled. Only the reporting officer and the community owner may change it.
+// Served and recalled warrants keep status false and record why in resolution.
+// responses:
+//   200: civilianWarrantResponse
+//   400: errorMessageResponse
+//   403: errorMessageResponse
+//   404: errorMessageResponse
+
+// Shows a single warrant
+// swagger:response civilianWarrantResponse
+type civilianWarrantResponseWrapper struct {
+	// in:body
+	Body models.Warrant
+}
+
+// Shows the warrants of the given {civilian_id}
+// swagger:response civilianWarrantsResponse
+type civilianWarrantsResponseWrapper struct {
+	// in:body
+	Body []models.Warrant
+}
+
+// swagger:parameters createCivilianWarrant updateCivilianWarrantStatus
+type civilianWarrantParamsWrapper struct {
+	// in:header
+	UserID string `json:"X-User-ID"`
+	// reasons when creating, status (active, served or recalled) when updating
+	// in:body
+	Body struct {
+		Reasons []string `json:"reasons"`
+		Status  string   `json:"status"`
+	}
+}
+
+// swagger:route GET /api/v1/community/{community_id}/civilians/search civilian communityCivilianSearch
+// Searches the civilians of a community. field=name (default) matches the start of the first and
+// last name, field=license matches a license ID and field=plate a registered vehicle's plate,
+// returning the civilian who owns it. q must be at least 2 characters. Each civilian is flagged
+// with hasActiveWarrant.
+// responses:
+//   200: communityCivilianSearchResponse
+//   400: errorMessageResponse
+
+// Shows a page of matching civilians and the total number of matches
+// swagger:response communityCivilianSearchResponse
+type communityCivilianSearchResponseWrapper struct {
+	// in:body
+	Body models.CivilianSearchResponse
+}
+
+// swagger:parameters communityCivilianSearch
+type communityCivilianSearchParamsWrapper struct {
+	// in:query
+	Q string `json:"q"`
+	// in:query
+	Field string `json:"field"`
+	// in:query
+	Page int `json:"page"`
+	// in:query
+	Limit int `json:"limit"`
+}
+
+// swagger:route GET /api/v1/community/{community_id}/vehicles/search vehicle communityVehicleSearch
+// Looks up the vehicles of a community by plate, an exact case insensitive match. Each vehicle
+// carries its registered owner's name and hasActiveWarrant. No match is an empty list.
+// responses:
+//   200: communityVehicleSearchResponse
+//   400: errorMessageResponse
+
+// Shows a page of vehicles with the given plate
+// swagger:response communityVehicleSearchResponse
+type communityVehicleSearchResponseWrapper struct {
+	// in:body
+	Body models.VehicleSearchResponse
+}
+
+// swagger:parameters communityVehicleSearch
+type communityVehicleSearchParamsWrapper struct {
+	// in:query
+	Plate string `json:"plate"`
+	// in:query
+	Page int `json:"page"`
+	// in:query
+	Limit int `json:"limit"`
+}
+
+// swagger:route GET /api/v1/community/{community_id}/firearms/search firearm communityFirearmSearch
+// Looks up the firearms of a community by serial number, an exact case insensitive match. Each
+// firearm carries its registered owner's name and hasActiveWarrant. No match is an empty list.
+// responses:
+//   200: communityFirearmSearchResponse
+//   400: errorMessageResponse
+
+// Shows a page of firearms with the given serial number
+// swagger:response communityFirearmSearchResponse
+type communityFirearmSearchResponseWrapper struct {
+	// in:body
+	Body models.FirearmSearchResponse
+}
+
+// swagger:parameters communityFirearmSearch
+type communityFirearmSearchParamsWrapper struct {
+	// in:query
+	Serial string `json:"serial"`
+	// in:query
+	Page int `json:"page"`
+	// in:query
+	Limit int `json:"limit"`
+}
+
 // swagger:route GET /api/v1/vehicle/{vehicle_id} vehicle vehicleByID
 // Get a vehicle by ID.
 // responses:
@@ -0,0 +1,12 @@
+package docs
+
+import (
+	// embed is needed for the go:embed directive below
+	_ "embed"
+)
+
+// SwaggerYAML is the generated spec, embedded so the api can serve it without reading ./docs
+// at runtime. Regenerate it with `make swagger` after changing any of the annotations here.
+//
+//go:embed swagger.yaml
+var SwaggerYAML []byte
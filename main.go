@@ -1,24 +1,63 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"go.uber.org/zap"
 
 	"github.com/linesmerrill/police-cad-api/api/handlers"
 	"github.com/linesmerrill/police-cad-api/config"
+	"github.com/linesmerrill/police-cad-api/models"
 
 	_ "github.com/linesmerrill/police-cad-api/docs" // This line is necessary for go-swagger to find the docs
 )
 
+// version is set at build time with -ldflags "-X main.version=<version>"
+var version = "dev"
+
 func main() {
-	a := handlers.App{}
+	selfCheck := flag.Bool("selfcheck", false, "run the startup self checks, print the report and exit non-zero if any check fails")
+	flag.Parse()
+
+	a := handlers.App{Version: version}
 	a.Config = *config.New()
 
-	err := a.Initialize() //initialize database and router
+	zap.S().Infow("effective config", "config", a.Config.Redacted())
+	if err := a.Config.Validate(); err != nil {
+		if a.Config.StrictConfig {
+			zap.S().With(err).Error("refusing to start with an invalid config, STRICT_CONFIG is enabled")
+			os.Exit(1)
+		}
+		zap.S().With(err).Warn("config is invalid, starting anyway since STRICT_CONFIG is disabled")
+	}
+
+	initialize := a.Initialize //initialize database and router
+	if *selfCheck {
+		// the self check must not create indexes or start background work
+		initialize = a.InitializeSelfCheck
+	}
+	err := initialize()
 	if err != nil {
 		zap.S().With(err).Error("error calling initialize")
+		if *selfCheck {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *selfCheck {
+		report := a.RunSelfCheck(context.Background())
+		b, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(b))
+		if report.Status == models.SelfCheckFail {
+			os.Exit(1)
+		}
 		return
 	}
 
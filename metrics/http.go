@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	requestsTotal = DefaultRegistry.NewCounterVec("police_cad_api_http_requests_total",
+		"Requests handled, by route template, method and status class.", "route", "method", "code")
+	requestDuration = DefaultRegistry.NewHistogramVec("police_cad_api_http_request_duration_seconds",
+		"Time taken to handle a request, by route template and method.", DefaultBuckets, "route", "method")
+	mongoErrorsTotal = DefaultRegistry.NewCounterVec("police_cad_api_mongo_errors_total",
+		"Mongo errors returned to clients, by kind.", "kind")
+)
+
+// statusRecorder remembers the status a handler wrote
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming handlers streaming through the recorder
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Middleware records the count and duration of every request. Requests are labelled with the
+// route template rather than the path, so IDs in the url do not create a series each.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		requestsTotal.Inc(route, r.Method, strconv.Itoa(status/100)+"xx")
+		requestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+// ObserveError counts err if it came from mongo. Other errors, like a bad ID in the url, are ignored.
+func ObserveError(err error) {
+	if kind := mongoErrorKind(err); kind != "" {
+		mongoErrorsTotal.Inc(kind)
+	}
+}
+
+// mongoErrorKind classifies a mongo error, returning "" for errors that are not from mongo
+func mongoErrorKind(err error) string {
+	var serverErr mongo.ServerError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return "no_documents"
+	case mongo.IsTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case mongo.IsNetworkError(err):
+		return "network"
+	case errors.As(err, &serverErr):
+		return "server"
+	}
+	return ""
+}
@@ -0,0 +1,214 @@
+// Package metrics keeps request and database error metrics in memory and serves them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the request duration histogram
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds every registered metric family
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// family is a metric that can write itself out
+type family interface {
+	write(w io.Writer)
+}
+
+// DefaultRegistry is the registry the api exposes on /metrics
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]family{}}
+}
+
+// CounterVec is a counter partitioned by a fixed set of labels
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// HistogramVec is a histogram partitioned by a fixed set of labels
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewCounterVec registers a counter. Registering the same name again returns the existing
+// counter, so tests and repeated router setup can call it freely.
+func (reg *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if f, ok := reg.families[name]; ok {
+		if c, ok := f.(*CounterVec); ok {
+			return c
+		}
+		panic(fmt.Sprintf("metric %s is already registered with a different type", name))
+	}
+	c := &CounterVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+	reg.families[name] = c
+	return c
+}
+
+// NewHistogramVec registers a histogram, returning the existing one if the name is taken
+func (reg *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if f, ok := reg.families[name]; ok {
+		if h, ok := f.(*HistogramVec); ok {
+			return h
+		}
+		panic(fmt.Sprintf("metric %s is already registered with a different type", name))
+	}
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogram{}}
+	reg.families[name] = h
+	return h
+}
+
+// Inc adds one to the series with the given label values, in the order the labels were registered
+func (c *CounterVec) Inc(values ...string) {
+	key := seriesKey(c.labels, values)
+	c.mu.Lock()
+	c.values[key]++
+	c.mu.Unlock()
+}
+
+// Observe records v in the series with the given label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := seriesKey(h.labels, values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// seriesKey renders label values as the {name="value",...} part of a series
+func seriesKey(labels, values []string) string {
+	if len(labels) != len(values) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(labels), len(values)))
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = label + `="` + escapeLabel(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// withLabel appends one more label to a series key
+func withLabel(key, label string) string {
+	if key == "" {
+		return label
+	}
+	return key + "," + label
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s} %s\n", c.name, k, formatFloat(c.values[k]))
+	}
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := h.series[k]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s} %d\n", h.name, withLabel(k, `le="`+formatFloat(bound)+`"`), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s} %d\n", h.name, withLabel(k, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, k, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, k, s.count)
+	}
+}
+
+// WriteText writes every metric in the Prometheus text format, sorted by name
+func (reg *Registry) WriteText(w io.Writer) {
+	reg.mu.Lock()
+	names := make([]string, 0, len(reg.families))
+	for name := range reg.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	families := make([]family, 0, len(names))
+	for _, name := range names {
+		families = append(families, reg.families[name])
+	}
+	reg.mu.Unlock()
+
+	for _, f := range families {
+		f.write(w)
+	}
+}
+
+// Handler serves the registry for Prometheus to scrape
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		reg.WriteText(w)
+	})
+}
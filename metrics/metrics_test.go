@@ -0,0 +1,72 @@
+package metrics_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/linesmerrill/police-cad-api/metrics"
+)
+
+func scrape(t *testing.T, r *mux.Router) string {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	return rr.Body.String()
+}
+
+func TestMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(metrics.Middleware)
+	r.Handle("/metrics", metrics.DefaultRegistry.Handler())
+	r.HandleFunc("/api/v1/community/{community_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	r.HandleFunc("/api/v1/user/{user_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/api/v1/community/1", "/api/v1/community/2", "/api/v1/user/3"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	out := scrape(t, r)
+	assert.Contains(t, out, "# TYPE police_cad_api_http_requests_total counter")
+	assert.Contains(t, out, `police_cad_api_http_requests_total{route="/api/v1/community/{community_id}",method="GET",code="2xx"} 2`)
+	assert.Contains(t, out, `police_cad_api_http_requests_total{route="/api/v1/user/{user_id}",method="GET",code="4xx"} 1`)
+	assert.Contains(t, out, `police_cad_api_http_request_duration_seconds_count{route="/api/v1/community/{community_id}",method="GET"} 2`)
+	assert.Contains(t, out, `police_cad_api_http_request_duration_seconds_bucket{route="/api/v1/user/{user_id}",method="GET",le="+Inf"} 1`)
+	assert.NotContains(t, out, "/api/v1/community/1")
+}
+
+func TestObserveError(t *testing.T) {
+	metrics.ObserveError(mongo.ErrNoDocuments)
+	metrics.ObserveError(mongo.CommandError{Code: 11000, Message: "duplicate key"})
+	metrics.ObserveError(errors.New("the provided hex string is not a valid ObjectID"))
+
+	r := mux.NewRouter()
+	r.Handle("/metrics", metrics.DefaultRegistry.Handler())
+	out := scrape(t, r)
+	assert.Contains(t, out, `police_cad_api_mongo_errors_total{kind="no_documents"} 1`)
+	assert.Contains(t, out, `police_cad_api_mongo_errors_total{kind="server"} 1`)
+}
+
+func TestRegistryIdempotentRegistration(t *testing.T) {
+	reg := metrics.NewRegistry()
+	first := reg.NewCounterVec("jobs_total", "Jobs run.", "kind")
+	second := reg.NewCounterVec("jobs_total", "Jobs run.", "kind")
+	assert.Same(t, first, second)
+
+	first.Inc("export")
+	second.Inc("export")
+	r := mux.NewRouter()
+	r.Handle("/metrics", reg.Handler())
+	assert.Contains(t, scrape(t, r), `jobs_total{kind="export"} 2`)
+}
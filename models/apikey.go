@@ -0,0 +1,29 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey holds the structure for the apiKeys collection in mongo. Only a hash of the
+// key is ever stored, the key itself is returned once when it is created.
+type APIKey struct {
+	ID           primitive.ObjectID `json:"_id" bson:"_id"`
+	CommunityID  string             `json:"communityId" bson:"communityId"`
+	Name         string             `json:"name" bson:"name"`
+	Prefix       string             `json:"prefix" bson:"prefix"`
+	KeyHash      string             `json:"-" bson:"keyHash"`
+	Scopes       []string           `json:"scopes" bson:"scopes"`
+	CreatedBy    string             `json:"createdBy" bson:"createdBy"`
+	CreatedAt    primitive.DateTime `json:"createdAt" bson:"createdAt"`
+	LastUsedAt   primitive.DateTime `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+	RequestCount int64              `json:"requestCount" bson:"requestCount"`
+	Revoked      bool               `json:"revoked" bson:"revoked"`
+	RevokedAt    primitive.DateTime `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+}
+
+// APIKeyCreatedResponse is returned when a key is created. It is the only
+// time the plaintext key is ever available.
+type APIKeyCreatedResponse struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"apiKey"`
+}
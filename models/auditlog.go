@@ -0,0 +1,18 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditLogEntry holds the structure for the auditLog collection in mongo. One entry
+// is written for every administrative action taken on a community.
+type AuditLogEntry struct {
+	ID          primitive.ObjectID     `json:"_id" bson:"_id"`
+	CommunityID string                 `json:"communityId" bson:"communityId"`
+	ActorUserID string                 `json:"actorUserId" bson:"actorUserId"`
+	Action      string                 `json:"action" bson:"action"`
+	TargetType  string                 `json:"targetType" bson:"targetType"`
+	TargetID    string                 `json:"targetId" bson:"targetId"`
+	Details     map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	CreatedAt   primitive.DateTime     `json:"createdAt" bson:"createdAt"`
+}
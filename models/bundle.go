@@ -0,0 +1,39 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CivilianBundle holds every record linked to a civilian, one section per collection.
+// Sections for features a community does not use are empty rather than missing.
+type CivilianBundle struct {
+	Civilian    Civilian           `json:"civilian"`
+	Vehicles    []Vehicle          `json:"vehicles"`
+	Firearms    []Firearm          `json:"firearms"`
+	Licenses    []License          `json:"licenses"`
+	Warrants    []Warrant          `json:"warrants"`
+	Citations   []Citation         `json:"citations"`
+	Reports     []interface{}      `json:"reports"`
+	Photos      []string           `json:"photos"`
+	Counts      map[string]int     `json:"counts"`
+	GeneratedAt primitive.DateTime `json:"generatedAt"`
+}
+
+// CivilianBundleDeleteConfirmation is returned when a bundle deletion is requested without
+// a confirm token. Sending the token back within its lifetime performs the deletion.
+type CivilianBundleDeleteConfirmation struct {
+	ConfirmToken string             `json:"confirmToken"`
+	ExpiresAt    primitive.DateTime `json:"expiresAt"`
+	Counts       map[string]int     `json:"counts"`
+}
+
+// CivilianBundleDeletionReceipt records what was removed when a civilian bundle was erased.
+// Unlinked counts references, like photos, that were cleared from the civilian while the
+// files they point to are hosted elsewhere and left in place.
+type CivilianBundleDeletionReceipt struct {
+	CivilianID string             `json:"civilianID"`
+	Deleted    map[string]int64   `json:"deleted"`
+	Anonymized map[string]int64   `json:"anonymized"`
+	Unlinked   map[string]int64   `json:"unlinked"`
+	DeletedAt  primitive.DateTime `json:"deletedAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Citation statuses
+const (
+	CitationUnpaid    = "unpaid"
+	CitationPaid      = "paid"
+	CitationDismissed = "dismissed"
+)
+
+// Citation holds the structure for the citations collection in mongo. A citation is a
+// fine issued against a civilian within a community.
+type Citation struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	CommunityID    string             `json:"communityId" bson:"communityId"`
+	CivilianID     string             `json:"civilianId" bson:"civilianId"`
+	IssuedByUserID string             `json:"issuedByUserId" bson:"issuedByUserId"`
+	Name           string             `json:"name" bson:"name"`
+	Amount         float64            `json:"amount" bson:"amount"`
+	Notes          string             `json:"notes,omitempty" bson:"notes,omitempty"`
+	Status         string             `json:"status" bson:"status"`
+	IssuedAt       primitive.DateTime `json:"issuedAt" bson:"issuedAt"`
+	ResolvedAt     primitive.DateTime `json:"resolvedAt,omitempty" bson:"resolvedAt,omitempty"`
+}
@@ -35,4 +35,30 @@ type CivilianDetails struct {
 	UserID               string        `json:"userID" bson:"userID"`
 	CreatedAt            interface{}   `json:"createdAt" bson:"createdAt"`
 	UpdatedAt            interface{}   `json:"updatedAt" bson:"updatedAt"`
+	// DeletedAt is set when the civilian's owner erased their record bundle
+	DeletedAt interface{} `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+}
+
+// CivilianSearchResponse holds a page of civilian search results and how many civilians
+// matched in total. TotalCount predates Pagination and is kept for existing clients.
+type CivilianSearchResponse struct {
+	Civilians  []CivilianSearchResult `json:"civilians"`
+	TotalCount int64                  `json:"totalCount"`
+	Pagination Pagination             `json:"pagination"`
+}
+
+// CivilianSearchResult is a civilian returned by search, flagged when they have an active warrant
+type CivilianSearchResult struct {
+	Civilian
+	HasActiveWarrant bool `json:"hasActiveWarrant"`
+}
+
+// RegisteredOwner is the civilian a vehicle or firearm is registered to. Found is false when
+// the owner no longer exists or was erased.
+type RegisteredOwner struct {
+	ID               string `json:"_id"`
+	FirstName        string `json:"firstName,omitempty"`
+	LastName         string `json:"lastName,omitempty"`
+	Found            bool   `json:"found"`
+	HasActiveWarrant bool   `json:"hasActiveWarrant"`
 }
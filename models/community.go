@@ -13,11 +13,43 @@ type Community struct {
 
 // CommunityDetails holds the structure for the inner community collection in mongo
 type CommunityDetails struct {
-	Name            string                 `json:"name"`
-	OwnerID         string                 `json:"ownerID"`
-	Code            string                 `json:"code"`
-	ActivePanics    map[string]interface{} `json:"activePanics"`
-	ActiveSignal100 bool                   `json:"activeSignal100"`
-	CreatedAt       primitive.DateTime     `json:"createdAt"`
-	UpdatedAt       primitive.DateTime     `json:"updatedAt"`
+	Name            string                 `json:"name" bson:"name"`
+	OwnerID         string                 `json:"ownerID" bson:"ownerID"`
+	Code            string                 `json:"code" bson:"code"`
+	ActivePanics    map[string]interface{} `json:"activePanics" bson:"activePanics"`
+	ActiveSignal100 bool                   `json:"activeSignal100" bson:"activeSignal100"`
+	Slug            string                 `json:"slug" bson:"slug"`
+	PreviousSlugs   []string               `json:"previousSlugs" bson:"previousSlugs"`
+	SlugUpdatedAt   primitive.DateTime     `json:"slugUpdatedAt" bson:"slugUpdatedAt"`
+	Broadcast       *Broadcast             `json:"broadcast,omitempty" bson:"broadcast,omitempty"`
+	CreatedAt       primitive.DateTime     `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       primitive.DateTime     `json:"updatedAt" bson:"updatedAt"`
+
+	// LegacyOwnerID is only ever read. The legacy Node writer stored the owner under
+	// "ownerId", which is folded into OwnerID on read and never written back.
+	LegacyOwnerID string `json:"-" bson:"ownerId,omitempty"`
+}
+
+// Broadcast levels, critical is for messages every member must act on
+const (
+	BroadcastInfo     = "info"
+	BroadcastWarning  = "warning"
+	BroadcastCritical = "critical"
+)
+
+// Broadcast is the urgent banner shown to every member of a community until it expires or
+// is cleared. A community has at most one, a new broadcast replaces the old one.
+type Broadcast struct {
+	Message   string             `json:"message" bson:"message"`
+	Level     string             `json:"level" bson:"level"`
+	CreatedBy string             `json:"createdBy" bson:"createdBy"`
+	CreatedAt primitive.DateTime `json:"createdAt" bson:"createdAt"`
+	ExpiresAt primitive.DateTime `json:"expiresAt" bson:"expiresAt"`
+}
+
+// CommunityLite is the small representation of a community returned by search
+type CommunityLite struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+	Slug string `json:"slug,omitempty"`
 }
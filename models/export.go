@@ -0,0 +1,9 @@
+package models
+
+// CommunityExportMember is a member as it appears in a community export. Only public
+// profile fields are exported, never emails or credentials.
+type CommunityExportMember struct {
+	ID       string `json:"_id"`
+	Username string `json:"username"`
+	CallSign string `json:"callSign"`
+}
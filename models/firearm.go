@@ -20,3 +20,15 @@ type FirearmDetails struct {
 	CreatedAt         interface{} `json:"createdAt" bson:"createdAt"`
 	UpdatedAt         interface{} `json:"updatedAt" bson:"updatedAt"`
 }
+
+// FirearmSearchResponse holds a page of firearms matching a serial number lookup
+type FirearmSearchResponse struct {
+	Firearms   []FirearmSearchResult `json:"firearms"`
+	Pagination Pagination            `json:"pagination"`
+}
+
+// FirearmSearchResult is a firearm returned by a serial number lookup with its registered owner
+type FirearmSearchResult struct {
+	Firearm
+	Owner RegisteredOwner `json:"owner"`
+}
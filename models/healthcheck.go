@@ -2,5 +2,15 @@ package models
 
 // HealthCheckResponse returns the health check response duh
 type HealthCheckResponse struct {
-	Alive bool `json:"alive"`
+	Alive   bool   `json:"alive"`
+	Version string `json:"version"`
+	Uptime  string `json:"uptime"`
+}
+
+// ReadyResponse returns the readiness of the api along with its maintenance state
+type ReadyResponse struct {
+	Ready bool `json:"ready"`
+	// Checks holds the status of each dependency, "ok" or the reason it failed
+	Checks      map[string]string `json:"checks"`
+	Maintenance Maintenance       `json:"maintenance"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IdempotencyRecord holds the structure for the idempotencyKeys collection in mongo. It stores
+// the response of a create request so a retry with the same Idempotency-Key can replay it.
+type IdempotencyRecord struct {
+	ID          string             `json:"-" bson:"_id"`
+	Key         string             `json:"key" bson:"key"`
+	Route       string             `json:"route" bson:"route"`
+	CommunityID string             `json:"communityId" bson:"communityId"`
+	Status      int                `json:"status" bson:"status"`
+	Body        []byte             `json:"body" bson:"body"`
+	CreatedAt   primitive.DateTime `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Maintenance holds the structure for the maintenance collection in mongo. There is only
+// ever a single document which every instance polls to converge on the same state.
+type Maintenance struct {
+	ID         string             `json:"-" bson:"_id"`
+	Enabled    bool               `json:"enabled" bson:"enabled"`
+	Message    string             `json:"message" bson:"message"`
+	AllowReads bool               `json:"allowReads" bson:"allowReads"`
+	UpdatedAt  primitive.DateTime `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// MigrationProgress reports how far a batched data migration got
+type MigrationProgress struct {
+	Batches  int   `json:"batches"`
+	Migrated int64 `json:"migrated"`
+	// Skipped counts communities whose _id is not an ObjectID, they have to be fixed by hand
+	Skipped int `json:"skipped"`
+	// Done is false when the request stopped at its batch limit and should be called again
+	Done bool `json:"done"`
+}
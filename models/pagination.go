@@ -0,0 +1,9 @@
+package models
+
+// Pagination describes the page of a list response and how many items there are in total
+type Pagination struct {
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	TotalCount int64 `json:"totalCount"`
+	HasMore    bool  `json:"hasMore"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// Self check statuses, from best to worst
+const (
+	SelfCheckPass = "pass"
+	SelfCheckWarn = "warn"
+	SelfCheckFail = "fail"
+)
+
+// SelfCheckResult is the outcome of a single self check
+type SelfCheckResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// SelfCheckReport holds the outcome of every registered self check. Status is
+// the worst status of all the results.
+type SelfCheckReport struct {
+	Status  string            `json:"status"`
+	Results []SelfCheckResult `json:"results"`
+}
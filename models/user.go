@@ -1,5 +1,9 @@
 package models
 
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 // User holds the structure for the user collection in mongo
 type User struct {
 	ID      string      `json:"_id" bson:"_id"`
@@ -22,4 +26,22 @@ type UserDetails struct {
 	ResetPasswordExpires interface{} `json:"resetPasswordExpires" bson:"resetPasswordExpires"`
 	CreatedAt            interface{} `json:"createdAt" bson:"createdAt"`
 	UpdatedAt            interface{} `json:"updatedAt" bson:"updatedAt"`
+	// IsOnline is set by heartbeats and cleared by the presence sweeper once LastSeenAt is too old
+	IsOnline   bool               `json:"isOnline" bson:"isOnline"`
+	LastSeenAt primitive.DateTime `json:"lastSeenAt" bson:"lastSeenAt"`
+}
+
+// UserLite is the public profile of a user, safe to show to any other user
+type UserLite struct {
+	ID       string `json:"_id"`
+	Username string `json:"username"`
+	CallSign string `json:"callSign"`
+	IsOnline bool   `json:"isOnline"`
+}
+
+// UsersBatchResponse holds the users found by a batch lookup, in the order they were
+// requested, and the requested IDs that did not match a user
+type UsersBatchResponse struct {
+	Users   []UserLite `json:"users"`
+	Missing []string   `json:"missing"`
 }
@@ -25,3 +25,15 @@ type VehicleDetails struct {
 	CreatedAt         interface{} `json:"createdAt" bson:"createdAt"`
 	UpdatedAt         interface{} `json:"updatedAt" bson:"updatedAt"`
 }
+
+// VehicleSearchResponse holds a page of vehicles matching a plate lookup
+type VehicleSearchResponse struct {
+	Vehicles   []VehicleSearchResult `json:"vehicles"`
+	Pagination Pagination            `json:"pagination"`
+}
+
+// VehicleSearchResult is a vehicle returned by a plate lookup with its registered owner
+type VehicleSearchResult struct {
+	Vehicle
+	Owner RegisteredOwner `json:"owner"`
+}
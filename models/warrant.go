@@ -1,5 +1,13 @@
 package models
 
+// Warrant statuses accepted by the api. Status stays a bool in mongo for the legacy app,
+// active warrants have Status true and cleared ones record why in Resolution.
+const (
+	WarrantActive   = "active"
+	WarrantServed   = "served"
+	WarrantRecalled = "recalled"
+)
+
 // Warrant holds the structure for the warrant collection in mongo
 type Warrant struct {
 	ID      string         `json:"_id" bson:"_id"`
@@ -19,4 +27,8 @@ type WarrantDetails struct {
 	ClearingOfficerID  string      `json:"clearingOfficerID" bson:"clearingOfficerID"`
 	CreatedAt          interface{} `json:"createdAt" bson:"createdAt"`
 	UpdatedAt          interface{} `json:"updatedAt" bson:"updatedAt"`
+	// ActiveCommunityID is only set on warrants issued through the api
+	ActiveCommunityID string `json:"activeCommunityID,omitempty" bson:"activeCommunityID,omitempty"`
+	// Resolution is served or recalled once the warrant is no longer active
+	Resolution string `json:"resolution,omitempty" bson:"resolution,omitempty"`
 }